@@ -0,0 +1,142 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// How long each color has to make their first move before the game is
+// aborted. Colors left out get the default (see
+// Config.defaultFirstMoveTimeout). Their clocks don't run until then.
+type FirstMoveTimeouts map[AgentColor]time.Duration
+
+func (t FirstMoveTimeouts) MarshalJSON() ([]byte, error) {
+	tmp := make(map[string]time.Duration)
+	for color, d := range t {
+		tmp[color.String()] = d
+	}
+	return json.Marshal(tmp)
+}
+
+func (t *FirstMoveTimeouts) UnmarshalJSON(raw []byte) error {
+	var tmp map[string]time.Duration
+	if err := json.Unmarshal(raw, &tmp); err != nil {
+		return err
+	}
+	*t = make(FirstMoveTimeouts)
+	for s, d := range tmp {
+		color, ok := agentColorFromString(s)
+		if !ok {
+			return errors.New("invalid first move timeout color " + s)
+		}
+		(*t)[color] = d
+	}
+	return nil
+}
+
+func (c *Config) validateFirstMoveTimeouts() error {
+	// Correspondence players may take as long as any other move.
+	limit := 10 * time.Minute
+	if c.IsCorrespondence() {
+		limit = c.timePerMove()
+	}
+	for color, d := range c.FirstMoveTimeouts {
+		if !c.Variant.isSeated(color) {
+			return errors.New(
+				"first move timeout given for " + color.String() +
+					", who isn't playing")
+		}
+		if d < 10*time.Second || d > limit {
+			return errors.New(
+				"first move timeout should be >= 10s and <= " + limit.String())
+		}
+	}
+	return nil
+}
+
+func (c Config) firstMoveTimeoutFor(
+	color AgentColor, fallback time.Duration) time.Duration {
+	if d, ok := c.FirstMoveTimeouts[color]; ok {
+		return d
+	}
+	return fallback
+}
+
+// Why a game was aborted.
+type AbortReason int
+
+const (
+	abortNil AbortReason = iota
+	// The player didn't make their first move in time.
+	AbortNoFirstMove
+	// The player aborted the game before everyone had moved.
+	AbortRequested
+	// The game was paused before everyone had moved, and never resumed.
+	AbortAdjourned
+	// The player aborted the game after their opponents disconnected.
+	AbortAbandoned
+)
+
+func (r AbortReason) String() string {
+	if r == abortNil {
+		return ""
+	} else if r == AbortNoFirstMove {
+		return "NO_FIRST_MOVE"
+	} else if r == AbortRequested {
+		return "REQUESTED"
+	} else if r == AbortAdjourned {
+		return "ADJOURNED"
+	} else if r == AbortAbandoned {
+		return "ABANDONED"
+	} else {
+		panic("invalid abort reason!")
+	}
+}
+
+func abortReasonFromString(s string) (AbortReason, error) {
+	for _, r := range []AbortReason{
+		abortNil, AbortNoFirstMove, AbortRequested, AbortAdjourned,
+		AbortAbandoned} {
+		if s == r.String() {
+			return r, nil
+		}
+	}
+	return abortNil, errors.New("invalid abort reason " + s)
+}
+
+// Players can only abort while somebody has yet to make their first move.
+func (gs *gameState) awaitingFirstMoves() bool {
+	return len(gs.history)-1 < gs.config.NumPlayers()
+}
+
+// Gives the player to move until their first move timeout to move.
+func (gs *gameState) startFirstMoveTimer() {
+	color := gs.lastSnapshot().whoseTurn
+	timeout := gs.config.firstMoveTimeoutFor(color, gs.firstMoveTimeout)
+	deadline := gs.wallClock.Now().Add(timeout)
+	gs.firstMoveDeadline = &deadline
+	gs.firstMoveTimer =
+		gs.wallClock.AfterFunc(timeout, gs.firstMoveTimeoutCallback)
+}
+
+func (gs *gameState) abort(agent AgentColor) bool {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	if gs.status != statusOngoing || !gs.awaitingFirstMoves() {
+		return false
+	}
+	gs.status = statusAborted
+	gs.abortedBy = agent
+	gs.abortReason = AbortRequested
+	gs.terminate(TerminationAborted)
+	gs.firstMoveDeadline = nil
+
+	if gs.onGameOver != nil {
+		gs.onGameOver()
+	}
+
+	gs.teardown()
+	return true
+}
@@ -0,0 +1,89 @@
+package game
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFirstMoveTimeoutsJSON(t *testing.T) {
+	raw := `{"timeControlNs": 60000000000,
+		"firstMoveTimeoutsNs": {"BLACK": 120000000000}}`
+	var config Config
+	if err := json.Unmarshal([]byte(raw), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.FirstMoveTimeouts[agentBlack] != 2*time.Minute {
+		t.Errorf("unexpected timeouts %v", config.FirstMoveTimeouts)
+	}
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInvalidFirstMoveTimeouts(t *testing.T) {
+	for _, timeouts := range []FirstMoveTimeouts{
+		FirstMoveTimeouts{agentWhite: time.Second},
+		FirstMoveTimeouts{agentBlack: time.Hour},
+		FirstMoveTimeouts{agentYellow: time.Minute},
+	} {
+		config := Config{TimeControl: time.Minute, FirstMoveTimeouts: timeouts}
+		if err := config.Validate(); err == nil {
+			t.Errorf("expected error for %v", timeouts)
+		}
+	}
+}
+
+func TestBlackFirstMoveTimeout(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	config := Config{
+		TimeControl:       time.Minute,
+		FirstMoveTimeouts: FirstMoveTimeouts{agentWhite: time.Minute},
+	}
+	gs, err := newGameState(clock, config, nil, nil, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gs.until(*gs.firstMoveDeadline) != time.Minute {
+		t.Error("expected white to get their own first move timeout")
+	}
+	if err := gs.ExecuteMove(Move{X: 0, Y: 0, D: DirDown}); err != nil {
+		t.Fatal(err)
+	}
+	if gs.agents[agentBlack].deadline != nil {
+		t.Error("expected black's clock not to run before their first move")
+	}
+
+	clock.Advance(time.Millisecond)
+	if gs.status != statusAborted || gs.abortedBy != agentBlack ||
+		gs.abortReason != AbortNoFirstMove {
+		t.Errorf(
+			"expected black not to have moved, got %s by %s (%s)", gs.status,
+			gs.abortedBy, gs.abortReason)
+	}
+}
+
+func TestTryAbort(t *testing.T) {
+	gm, err := NewGameManager(
+		RealClock, Config{TimeControl: time.Minute}, fakePlayers(), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gm.state.teardown()
+	err = gm.TryMove(Move{X: 0, Y: 0, D: DirDown}, gm.GetWhiteCookie())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gm.TryMove(Move{X: 6, Y: 0, D: DirDown}, gm.GetBlackCookie())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both players have moved.
+	if gm.TryAbort(gm.GetWhiteCookie()) {
+		t.Error("expected abort to fail once both players moved")
+	}
+	if gm.state.status != statusOngoing {
+		t.Error("expected the game to go on")
+	}
+}
@@ -10,13 +10,40 @@ const (
 	agentNil AgentColor = iota
 	agentWhite
 	agentBlack
+	// Only used in team games, where yellow partners white and green partners
+	// black.
+	agentYellow
+	agentGreen
 )
 
+var allAgentColors = []AgentColor{
+	agentWhite, agentBlack, agentYellow, agentGreen,
+}
+
 type agent struct {
 	score    int
 	time     time.Duration
 	deadline *time.Time
-	timer    *time.Timer
+	timer    Timer
+	// Where the time comes from; not to be confused with clock.
+	wallClock Clock
+	// From the config; see ClockType.
+	clock     ClockType
+	increment time.Duration
+	// From the config; see OvertimeType.
+	overtime    OvertimeType
+	periodTime  time.Duration
+	periodMoves int
+	// Overtime state. Periods counts down the byo-yomi periods left and
+	// movesLeft the moves left in the current Canadian period.
+	inOvertime bool
+	periods    int
+	movesLeft  int
+	// Correspondence games only: the clock is reset to timePerMove after every
+	// move, and vacation counts down the vacation days left.
+	timePerMove time.Duration
+	vacation    int
+	lag         lagCompensator
 }
 
 func (ac AgentColor) String() string {
@@ -26,21 +53,80 @@ func (ac AgentColor) String() string {
 		return "WHITE"
 	} else if ac == agentBlack {
 		return "BLACK"
+	} else if ac == agentYellow {
+		return "YELLOW"
+	} else if ac == agentGreen {
+		return "GREEN"
 	} else {
 		panic("invalid AgentColor!")
 	}
 }
 
+func agentColorFromString(s string) (AgentColor, bool) {
+	for _, ac := range allAgentColors {
+		if s == ac.String() {
+			return ac, true
+		}
+	}
+	return agentNil, false
+}
+
 func (ac AgentColor) marble() Marble {
+	if ac == agentYellow {
+		return marbleYellow
+	} else if ac == agentGreen {
+		return marbleGreen
+	}
 	return Marble(ac)
 }
 
+// Only meaningful for white & black (which double as the two team names).
 func (ac AgentColor) otherAgent() AgentColor {
 	return ac%2 + 1
 }
 
+// Teams are named after their white or black member. In two player games
+// every agent is their own team.
+func (ac AgentColor) team() AgentColor {
+	if ac == agentYellow {
+		return agentWhite
+	} else if ac == agentGreen {
+		return agentBlack
+	}
+	return ac
+}
+
+// Called once the player's clock has run out. Reports whether they have
+// overtime left, in which case their clock is restarted.
+func (a *agent) startNextPeriod(timeoutCb func()) bool {
+	a.timer = nil
+	a.deadline = nil
+	a.time = 0
+	if a.vacation > 0 {
+		a.vacation--
+		a.time = 24 * time.Hour
+		return a.startTurn(timeoutCb)
+	}
+	if a.overtime == OvertimeNone {
+		return false
+	}
+	if !a.inOvertime {
+		a.inOvertime = true
+		a.movesLeft = a.periodMoves
+	} else if a.overtime == OvertimeCanadian {
+		return false
+	} else {
+		a.periods--
+		if a.periods == 0 {
+			return false
+		}
+	}
+	a.time = a.periodTime
+	return a.startTurn(timeoutCb)
+}
+
 func (ac AgentColor) winStatus() Status {
-	return Status(ac)
+	return Status(ac.team())
 }
 
 func (a *agent) startTurn(timeoutCb func()) bool {
@@ -50,13 +136,28 @@ func (a *agent) startTurn(timeoutCb func()) bool {
 	if a.timer != nil || a.deadline != nil {
 		return false
 	}
-	tmp := time.Now().Add(a.time)
+	// The delay is spent before any of the player's own time is.
+	total := a.time
+	if a.clock == ClockSimpleDelay {
+		total += a.increment
+	}
+	tmp := a.wallClock.Now().Add(total)
 	a.deadline = &tmp
-	a.timer = time.AfterFunc(a.time, timeoutCb)
+	a.timer = a.wallClock.AfterFunc(total, timeoutCb)
 	return true
 }
 
-func (a *agent) endTurn() bool {
+// The time left on the player's clock as of now.
+func (a *agent) remaining(now time.Time) time.Duration {
+	if a.deadline == nil {
+		return a.time
+	}
+	return max(a.deadline.Sub(now), 0)
+}
+
+// Stops the player's clock. Players who moved (rather than e.g. resigned) get
+// their increment.
+func (a *agent) endTurn(moved bool) bool {
 	if a == nil {
 		return true
 	}
@@ -64,9 +165,57 @@ func (a *agent) endTurn() bool {
 		return false
 	}
 	a.timer = nil
-	if a.deadline != nil {
-		a.time = time.Until(*a.deadline)
-		a.deadline = nil
+	if a.deadline == nil {
+		return true
+	}
+	remaining := a.deadline.Sub(a.wallClock.Now())
+	a.deadline = nil
+	if a.clock == ClockSimpleDelay && remaining > a.time {
+		// Moved before the delay ran out.
+		remaining = a.time
+	}
+	// Byo-yomi periods and correspondence clocks are reset below anyway.
+	if moved && a.timePerMove == 0 &&
+		!(a.inOvertime && a.overtime == OvertimeByoYomi) {
+		remaining += a.lag.refund(a.time-remaining, a.wallClock.Now())
 	}
+	if moved {
+		remaining = a.creditMove(remaining)
+	}
+	a.time = remaining
 	return true
 }
+
+// Premoves are played without the player's clock running, but otherwise count
+// like any other move.
+func (a *agent) premove() {
+	if a == nil {
+		return
+	}
+	a.time = a.creditMove(a.time)
+}
+
+// The time left after a move which left remaining on the clock, once any
+// increment or overtime rules are applied.
+func (a *agent) creditMove(remaining time.Duration) time.Duration {
+	if a.timePerMove > 0 {
+		remaining = a.timePerMove
+	} else if a.inOvertime && a.overtime == OvertimeByoYomi {
+		remaining = a.periodTime
+	} else if a.inOvertime && a.overtime == OvertimeCanadian {
+		a.movesLeft--
+		if a.movesLeft == 0 {
+			remaining = a.periodTime
+			a.movesLeft = a.periodMoves
+		}
+	} else if a.clock == ClockFischer {
+		remaining += a.increment
+	} else if a.clock == ClockBronstein {
+		used := a.time - remaining
+		if used > a.increment {
+			used = a.increment
+		}
+		remaining += used
+	}
+	return remaining
+}
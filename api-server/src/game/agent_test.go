@@ -36,38 +36,228 @@ func TestAgentToWinStatus(t *testing.T) {
 }
 
 func TestStartTurnCallback(t *testing.T) {
+	clock := NewFakeClock(time.Now())
 	a := agent{
-		time: time.Nanosecond,
+		time:      time.Second,
+		wallClock: clock,
 	}
 
-	done := make(chan bool)
-	cb := func() {
-		close(done)
+	fired := false
+	a.startTurn(func() {
+		fired = true
+	})
+	clock.Advance(time.Second - time.Nanosecond)
+	if fired {
+		t.Error("The callback fired before the time ran out")
+	}
+	clock.Advance(time.Nanosecond)
+	if !fired {
+		t.Error("The callback didn't fire when the time ran out")
 	}
-
-	a.startTurn(cb)
-	<-done
 }
 
 func TestEndTurn(t *testing.T) {
-	totalTime := time.Millisecond * 100
+	clock := NewFakeClock(time.Now())
 	a := agent{
-		time: totalTime,
+		time:      time.Millisecond * 100,
+		wallClock: clock,
 	}
 
-	cbDone := make(chan bool)
-	cb := func() {
-		close(cbDone)
+	fired := false
+	a.startTurn(func() {
+		fired = true
+	})
+	clock.Advance(time.Millisecond * 40)
+	a.endTurn(true)
+	clock.Advance(time.Second)
+
+	if fired {
+		t.Error("The callback fired even though we ended the turn")
 	}
+	if a.time != time.Millisecond*60 {
+		t.Errorf("expected 60ms left, got %s", a.time)
+	}
+}
 
-	timerDone := time.NewTimer(totalTime + time.Millisecond*100)
+func TestTeams(t *testing.T) {
+	if agentYellow.team() != agentWhite || agentWhite.team() != agentWhite {
+		t.Error("expected white & yellow to be on white's team")
+	}
+	if agentGreen.team() != agentBlack || agentBlack.team() != agentBlack {
+		t.Error("expected black & green to be on black's team")
+	}
+	if agentYellow.winStatus() != statusWhiteWon ||
+		agentGreen.winStatus() != statusBlackWon {
+		t.Error("expected partners to share their team's win status")
+	}
+	if agentYellow.marble() != marbleYellow ||
+		agentGreen.marble() != marbleGreen {
+		t.Error("partner marbles did not match expectation")
+	}
+	for _, ac := range []AgentColor{
+		agentWhite, agentBlack, agentYellow, agentGreen} {
+		if ac.marble().agent() != ac {
+			t.Errorf("marble of %s does not belong to %s", ac, ac)
+		}
+	}
+}
 
-	a.startTurn(cb)
-	a.endTurn()
+func TestFischerIncrement(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	a := agent{
+		time:      time.Second,
+		clock:     ClockFischer,
+		increment: 2 * time.Second,
+		wallClock: clock,
+	}
+	a.startTurn(func() {})
+	clock.Advance(100 * time.Millisecond)
+	a.endTurn(true)
+	if a.time != 2900*time.Millisecond {
+		t.Errorf("expected 2.9s after the increment, got %s", a.time)
+	}
 
-	select {
-	case <-cbDone:
-		t.Error("The callback fired even though we ended the turn")
-	case <-timerDone.C:
+	// No increment for turns which didn't end in a move
+	a.startTurn(func() {})
+	clock.Advance(100 * time.Millisecond)
+	a.endTurn(false)
+	if a.time != 2800*time.Millisecond {
+		t.Errorf("expected no increment, got %s", a.time)
+	}
+}
+
+func TestBronsteinDelay(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	a := agent{
+		time:      time.Second,
+		clock:     ClockBronstein,
+		increment: 50 * time.Millisecond,
+		wallClock: clock,
+	}
+	// Moves faster than the delay cost nothing...
+	a.startTurn(func() {})
+	clock.Advance(10 * time.Millisecond)
+	a.endTurn(true)
+	if a.time != time.Second {
+		t.Errorf("expected the move to be free, got %s left", a.time)
+	}
+
+	// ...and the time given back is capped at the delay.
+	a.startTurn(func() {})
+	clock.Advance(100 * time.Millisecond)
+	a.endTurn(true)
+	if a.time != 950*time.Millisecond {
+		t.Errorf("expected 950ms left, got %s", a.time)
+	}
+}
+
+func TestSimpleDelay(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	a := agent{
+		time:      time.Second,
+		clock:     ClockSimpleDelay,
+		increment: 50 * time.Millisecond,
+		wallClock: clock,
+	}
+	a.startTurn(func() {})
+	if a.deadline.Sub(clock.Now()) != 1050*time.Millisecond {
+		t.Error("expected the deadline to include the delay")
+	}
+	clock.Advance(10 * time.Millisecond)
+	a.endTurn(true)
+	if a.time != time.Second {
+		t.Errorf("expected the clock not to run during the delay, got %s",
+			a.time)
+	}
+
+	a.startTurn(func() {})
+	clock.Advance(100 * time.Millisecond)
+	a.endTurn(true)
+	if a.time != 950*time.Millisecond {
+		t.Errorf("expected 950ms left, got %s", a.time)
+	}
+}
+
+func TestByoYomi(t *testing.T) {
+	a := agent{
+		time:       0,
+		overtime:   OvertimeByoYomi,
+		periodTime: time.Hour,
+		periods:    2,
+		wallClock:  RealClock,
+	}
+	if !a.startNextPeriod(func() {}) || !a.inOvertime || a.periods != 2 {
+		t.Fatal("expected main time running out to start the first period")
+	}
+	// Moving in time doesn't use up the period
+	a.endTurn(true)
+	if a.time != time.Hour || a.periods != 2 {
+		t.Errorf("expected a fresh period, got %s with %d periods left",
+			a.time, a.periods)
+	}
+
+	a.startTurn(func() {})
+	a.timer.Stop()
+	if !a.startNextPeriod(func() {}) || a.periods != 1 {
+		t.Error("expected the second period to start")
+	}
+	a.timer.Stop()
+	if a.startNextPeriod(func() {}) {
+		t.Error("expected the player to run out of periods")
+	}
+}
+
+func TestCanadianOvertime(t *testing.T) {
+	a := agent{
+		time:        0,
+		overtime:    OvertimeCanadian,
+		periodTime:  time.Hour,
+		periodMoves: 2,
+		wallClock:   RealClock,
+	}
+	if !a.startNextPeriod(func() {}) || a.movesLeft != 2 {
+		t.Fatal("expected main time running out to start overtime")
+	}
+	a.endTurn(true)
+	if a.movesLeft != 1 || a.time >= time.Hour {
+		t.Errorf("expected the period to keep running, got %s for %d moves",
+			a.time, a.movesLeft)
+	}
+	a.startTurn(func() {})
+	a.endTurn(true)
+	if a.movesLeft != 2 || a.time != time.Hour {
+		t.Errorf("expected the period to start over, got %s for %d moves",
+			a.time, a.movesLeft)
+	}
+
+	a.startTurn(func() {})
+	a.timer.Stop()
+	if a.startNextPeriod(func() {}) {
+		t.Error("expected the player to lose when the period runs out")
+	}
+}
+
+func TestCorrespondenceClock(t *testing.T) {
+	a := agent{
+		time:        time.Hour,
+		timePerMove: time.Hour,
+		vacation:    1,
+		wallClock:   RealClock,
+	}
+	a.startTurn(func() {})
+	a.endTurn(true)
+	if a.time != time.Hour {
+		t.Errorf("expected the clock to be reset after the move, got %s", a.time)
+	}
+
+	a.startTurn(func() {})
+	a.timer.Stop()
+	if !a.startNextPeriod(func() {}) || a.vacation != 0 ||
+		time.Until(*a.deadline) <= 23*time.Hour {
+		t.Error("expected a vacation day to be used up")
+	}
+	a.timer.Stop()
+	if a.startNextPeriod(func() {}) {
+		t.Error("expected the player to lose once out of vacation days")
 	}
 }
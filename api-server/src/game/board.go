@@ -12,6 +12,14 @@ const (
 	marbleWhite
 	marbleBlack
 	marbleRed
+	marbleYellow
+	marbleGreen
+	// Marks spaces which are not part of the board, e.g. the cells of the
+	// underlying grid which fall outside a hexagonal board.
+	marbleVoid
+	// Marks spaces the viewer can't see. Only ever appears in redacted views
+	// sent to players of hidden information variants.
+	marbleHidden
 )
 
 func (m Marble) String() string {
@@ -23,23 +31,40 @@ func (m Marble) String() string {
 		return "B"
 	} else if m == marbleRed {
 		return "R"
+	} else if m == marbleYellow {
+		return "Y"
+	} else if m == marbleGreen {
+		return "G"
+	} else if m == marbleVoid {
+		return "#"
+	} else if m == marbleHidden {
+		return "?"
 	} else {
 		panic("invalid marble!")
 	}
 }
 
 func marbleFromString(s string) (Marble, bool) {
-	if s == marbleNil.String() {
-		return marbleNil, true
-	} else if s == marbleWhite.String() {
-		return marbleWhite, true
-	} else if s == marbleBlack.String() {
-		return marbleBlack, true
-	} else if s == marbleRed.String() {
-		return marbleRed, true
-	} else {
-		return marbleNil, false
+	for _, m := range []Marble{
+		marbleNil, marbleWhite, marbleBlack, marbleRed, marbleYellow,
+		marbleGreen, marbleVoid, marbleHidden} {
+		if s == m.String() {
+			return m, true
+		}
+	}
+	return marbleNil, false
+}
+
+// The agent who owns this marble (agentNil for reds & empty spaces).
+func (m Marble) agent() AgentColor {
+	if m == marbleWhite || m == marbleBlack {
+		return AgentColor(m)
+	} else if m == marbleYellow {
+		return agentYellow
+	} else if m == marbleGreen {
+		return agentGreen
 	}
+	return agentNil
 }
 
 type BoardT [][]Marble
@@ -89,3 +114,105 @@ func (b *BoardT) UnmarshalJSON(raw []byte) error {
 	}
 	return nil
 }
+
+const defaultBoardSize = 7
+
+// Builds the standard start position for a square board with an odd side
+// length: a 2x2 block in each corner (white on one diagonal, black on the
+// other) and a diamond of reds in the center which stops one space short of
+// the edge.
+func newStartBoard(size int) BoardT {
+	board := make(BoardT, size)
+	center := size / 2
+	radius := center - 1
+	for y := 0; y < size; y++ {
+		board[y] = make([]Marble, size)
+		for x := 0; x < size; x++ {
+			top, bottom := y < 2, y >= size-2
+			left, right := x < 2, x >= size-2
+			if (top && left) || (bottom && right) {
+				board[y][x] = marbleWhite
+			} else if (top && right) || (bottom && left) {
+				board[y][x] = marbleBlack
+			} else if abs(x-center)+abs(y-center) <= radius {
+				board[y][x] = marbleRed
+			}
+		}
+	}
+	return board
+}
+
+func (b BoardT) count(m Marble) int {
+	n := 0
+	for _, row := range b {
+		for _, el := range row {
+			if el == m {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// A player wins by capturing a strict majority of the reds on the board.
+func winThresholdFor(b BoardT) int {
+	return b.count(marbleRed)/2 + 1
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+const defaultHexBoardSize = 9
+
+// Builds the start position for a hexagonal board, stored in axial
+// coordinates: x runs left to right and y runs from the top left edge to the
+// bottom right edge, so the board is a size x size grid with the cells outside
+// the hexagon marked void. The six corners alternate between white and black,
+// each holding the corner marble and its two neighbors along the edge, and a
+// hexagon of reds sits in the center, leaving one empty ring between it and
+// the edge.
+func newHexStartBoard(size int) BoardT {
+	radius := size / 2
+	board := make(BoardT, size)
+	for y := 0; y < size; y++ {
+		board[y] = make([]Marble, size)
+		for x := 0; x < size; x++ {
+			if hexDistance(x-radius, y-radius) > radius {
+				board[y][x] = marbleVoid
+			} else if hexDistance(x-radius, y-radius) <= radius-2 {
+				board[y][x] = marbleRed
+			}
+		}
+	}
+
+	// Corners (relative to the center) in clockwise order, starting from the
+	// top left.
+	corners := [][2]int{
+		{0, -radius}, {radius, -radius}, {radius, 0},
+		{0, radius}, {-radius, radius}, {-radius, 0},
+	}
+	for i, c := range corners {
+		m := marbleWhite
+		if i%2 == 1 {
+			m = marbleBlack
+		}
+		prev, next := corners[(i+5)%6], corners[(i+1)%6]
+		for _, step := range [][2]int{
+			{0, 0},
+			{(prev[0] - c[0]) / radius, (prev[1] - c[1]) / radius},
+			{(next[0] - c[0]) / radius, (next[1] - c[1]) / radius},
+		} {
+			board[radius+c[1]+step[1]][radius+c[0]+step[0]] = m
+		}
+	}
+	return board
+}
+
+// Distance from the center of a hex board in axial coordinates.
+func hexDistance(dx, dy int) int {
+	return (abs(dx) + abs(dy) + abs(dx+dy)) / 2
+}
@@ -0,0 +1,164 @@
+package game
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Where games (and the server) get the time from. Tests use a FakeClock, so
+// that timeouts can be tested without sleeping.
+type Clock interface {
+	Now() time.Time
+	// Calls f once d has passed.
+	AfterFunc(d time.Duration, f func()) Timer
+	Sleep(d time.Duration)
+}
+
+// The part of *time.Timer that's used.
+type Timer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+type realClock struct{}
+
+// The system clock.
+var RealClock Clock = realClock{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// A Clock which only moves when Advance is called. Timers fire (and sleepers
+// wake up) from within Advance, in the order of their deadlines.
+type FakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+	// Signalled whenever a timer is started.
+	started *sync.Cond
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	deadline time.Time
+	f        func()
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.started = sync.NewCond(&c.mutex)
+	return c
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	t := &fakeTimer{clock: c, f: f}
+	c.schedule(t, d)
+	return t
+}
+
+// Blocks until another goroutine advances the clock by d.
+func (c *FakeClock) Sleep(d time.Duration) {
+	done := make(chan struct{})
+	c.AfterFunc(d, func() {
+		close(done)
+	})
+	<-done
+}
+
+// Moves the clock forward by d, firing the timers that come due on the way.
+// Timers fire synchronously, so their callbacks have run once Advance
+// returns. Callbacks may start new timers, which fire too if they come due
+// before the end of d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	end := c.now.Add(d)
+	for len(c.timers) > 0 && !c.timers[0].deadline.After(end) {
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		if t.deadline.After(c.now) {
+			c.now = t.deadline
+		}
+		c.mutex.Unlock()
+		t.f()
+		c.mutex.Lock()
+	}
+	c.now = end
+	c.mutex.Unlock()
+}
+
+// The number of timers (and sleepers) waiting on the clock.
+func (c *FakeClock) Waiting() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.timers)
+}
+
+// Blocks until at least n timers (or sleepers) are waiting on the clock.
+// Lets tests wait for a goroutine to go back to sleep before advancing.
+func (c *FakeClock) BlockUntilWaiting(n int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for len(c.timers) < n {
+		c.started.Wait()
+	}
+}
+
+// Must hold c.mutex.
+func (c *FakeClock) schedule(t *fakeTimer, d time.Duration) {
+	t.deadline = c.now.Add(d)
+	c.timers = append(c.timers, t)
+	// Stable, so timers with the same deadline fire in the order they were
+	// started in.
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].deadline.Before(c.timers[j].deadline)
+	})
+	c.started.Broadcast()
+}
+
+// Must hold c.mutex. Returns whether t was waiting.
+func (c *FakeClock) unschedule(t *fakeTimer) bool {
+	for idx, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:idx], c.timers[idx+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	return t.clock.unschedule(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	waiting := t.clock.unschedule(t)
+	t.clock.schedule(t, d)
+	return waiting
+}
+
+func (gs *gameState) until(t time.Time) time.Duration {
+	return t.Sub(gs.wallClock.Now())
+}
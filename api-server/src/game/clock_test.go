@@ -0,0 +1,85 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClockFiresTimersInOrder(t *testing.T) {
+	start := time.Now()
+	clock := NewFakeClock(start)
+
+	var fired []string
+	clock.AfterFunc(2*time.Second, func() {
+		fired = append(fired, "second")
+		if !clock.Now().Equal(start.Add(2 * time.Second)) {
+			t.Errorf("expected the timer to fire at its deadline, got %s",
+				clock.Now().Sub(start))
+		}
+	})
+	clock.AfterFunc(time.Second, func() {
+		fired = append(fired, "first")
+		// Timers started by callbacks fire too, if they come due in time.
+		clock.AfterFunc(500*time.Millisecond, func() {
+			fired = append(fired, "nested")
+		})
+	})
+	clock.AfterFunc(time.Hour, func() {
+		fired = append(fired, "late")
+	})
+
+	clock.Advance(3 * time.Second)
+	expected := []string{"first", "nested", "second"}
+	if len(fired) != len(expected) {
+		t.Fatalf("expected %v to fire, got %v", expected, fired)
+	}
+	for idx := range expected {
+		if fired[idx] != expected[idx] {
+			t.Fatalf("expected %v to fire, got %v", expected, fired)
+		}
+	}
+	if !clock.Now().Equal(start.Add(3 * time.Second)) {
+		t.Errorf("expected the clock to end up 3s later, got %s",
+			clock.Now().Sub(start))
+	}
+	if clock.Waiting() != 1 {
+		t.Errorf("expected 1 timer left, got %d", clock.Waiting())
+	}
+}
+
+func TestFakeClockStopAndReset(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+
+	fired := 0
+	timer := clock.AfterFunc(time.Second, func() {
+		fired++
+	})
+	if !timer.Stop() || timer.Stop() {
+		t.Error("expected only the first Stop to find the timer waiting")
+	}
+	clock.Advance(time.Minute)
+	if fired != 0 {
+		t.Error("expected a stopped timer not to fire")
+	}
+
+	if timer.Reset(time.Second) {
+		t.Error("expected Reset not to find the stopped timer waiting")
+	}
+	clock.Advance(time.Second)
+	if fired != 1 {
+		t.Errorf("expected the reset timer to fire once, fired %d times", fired)
+	}
+}
+
+func TestFakeClockSleep(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+
+	done := make(chan struct{})
+	go func() {
+		clock.Sleep(time.Minute)
+		close(done)
+	}()
+	clock.BlockUntilWaiting(1)
+	clock.Advance(time.Minute)
+	<-done
+}
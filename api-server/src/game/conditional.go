@@ -0,0 +1,198 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// The most branches a player's conditional moves can have in all.
+const maxConditionalMoves = 100
+
+// A reply to play should the opponent make a given move, and what to do after
+// that. Players of correspondence games register a tree of these while
+// waiting for their opponent, which the server then walks as moves come in.
+// Trees the game leaves are cancelled.
+type ConditionalMove struct {
+	If    Move              `json:"if"`
+	Reply Move              `json:"reply"`
+	Then  []ConditionalMove `json:"then,omitempty"`
+}
+
+func countConditionalMoves(tree []ConditionalMove) int {
+	n := len(tree)
+	for _, branch := range tree {
+		n += countConditionalMoves(branch.Then)
+	}
+	return n
+}
+
+func describeMove(m Move) string {
+	return fmt.Sprintf("(%d,%d) %s", m.X, m.Y, m.D)
+}
+
+// The owner of c's conditional moves, which only they get to see.
+func (gm *GameManager) GetConditionalMoves(
+	c *http.Cookie) ([]ConditionalMove, error) {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	user, ok := gm.cookieToUser[getKeyFromCookie(c)]
+	if !ok {
+		return nil, errors.New("Cookie not found.")
+	}
+	return gm.state.conditionalMovesFor(user.color), nil
+}
+
+// Replaces the owner of c's conditional moves with tree, once every branch of
+// it checks out. An empty tree clears them.
+func (gm *GameManager) TrySetConditionalMoves(
+	c *http.Cookie, tree []ConditionalMove) error {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	user, ok := gm.cookieToUser[getKeyFromCookie(c)]
+	if !ok {
+		return errors.New("Cookie not found.")
+	}
+	return gm.state.setConditionalMoves(user.color, tree)
+}
+
+func (gs *gameState) conditionalMovesFor(agent AgentColor) []ConditionalMove {
+	gs.mutex.RLock()
+	defer gs.mutex.RUnlock()
+
+	return gs.conditionals[agent]
+}
+
+func (gs *gameState) setConditionalMoves(
+	agent AgentColor, tree []ConditionalMove) error {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	if len(tree) == 0 {
+		delete(gs.conditionals, agent)
+		return nil
+	}
+	if !gs.config.IsCorrespondence() {
+		return errors.New(
+			"Conditional moves are only for correspondence games.")
+	}
+	// Checking the opponent's moves would tell the player what's hidden.
+	if gs.config.Variant.hidesInformation() {
+		return errors.New(
+			"Conditional moves aren't allowed in hidden information variants.")
+	}
+	if gs.config.NumPlayers() != 2 {
+		return errors.New("Conditional moves are only for two player games.")
+	}
+	if gs.status != statusOngoing {
+		return errors.New("Game is not ongoing.")
+	}
+	if gs.lastSnapshot().whoseTurn == agent {
+		return errors.New(
+			"Conditional moves start from your opponent's move; move first.")
+	}
+	if countConditionalMoves(tree) > maxConditionalMoves {
+		return errors.New("Too many conditional moves.")
+	}
+	if err := gs.validateConditionalMoves(tree); err != nil {
+		return err
+	}
+	if gs.conditionals == nil {
+		gs.conditionals = make(map[AgentColor][]ConditionalMove)
+	}
+	gs.conditionals[agent] = tree
+	return nil
+}
+
+// Checks every branch of tree by playing it out on a copy of the game, whose
+// opponent is to move.
+func (gs *gameState) validateConditionalMoves(tree []ConditionalMove) error {
+	seen := make(map[Move]bool)
+	for _, branch := range tree {
+		if seen[branch.If] {
+			return errors.New(
+				describeMove(branch.If) + " is answered more than once.")
+		}
+		seen[branch.If] = true
+
+		sim := gs.simulation()
+		for _, m := range []Move{branch.If, branch.Reply} {
+			if sim.status != statusOngoing {
+				return errors.New(
+					"The game is over before " + describeMove(m) + ".")
+			}
+			if _, err := sim.ValidateMove(m); err != nil {
+				return errors.New(describeMove(m) + ": " + err.Error())
+			}
+			sim.applyMove(m)
+		}
+		if len(branch.Then) == 0 {
+			continue
+		}
+		if sim.status != statusOngoing {
+			return errors.New("The game is over after " +
+				describeMove(branch.Reply) + ".")
+		}
+		if err := sim.validateConditionalMoves(branch.Then); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A copy of the game to try moves out on, with no timers or callbacks.
+func (gs *gameState) simulation() *gameState {
+	agents := make(map[AgentColor]*agent)
+	for color, a := range gs.agents {
+		tmp := *a
+		tmp.timer = nil
+		tmp.deadline = nil
+		agents[color] = &tmp
+	}
+	posToCount := make(map[string]int)
+	for pos, count := range gs.posToCount {
+		posToCount[pos] = count
+	}
+	return &gameState{
+		history:      append([]snapshot{}, gs.history...),
+		agents:       agents,
+		ko:           gs.ko,
+		winThreshold: gs.winThreshold,
+		config:       gs.config,
+		status:       gs.status,
+		posToCount:   posToCount,
+		validMoves:   gs.validMoves,
+		start:        gs.start,
+		wallClock:    gs.wallClock,
+		sinceCapture: gs.sinceCapture,
+	}
+}
+
+// Answers the opponent's last move from the conditional moves of whoever is
+// to move, if they have any. Returns whether a reply was played; if the move
+// isn't covered the conditional moves are cancelled instead.
+func (gs *gameState) playConditionalMove() bool {
+	owner := gs.lastSnapshot().whoseTurn
+	tree, ok := gs.conditionals[owner]
+	last := gs.lastSnapshot().lastMove
+	if !ok || last == nil {
+		return false
+	}
+	delete(gs.conditionals, owner)
+	for _, branch := range tree {
+		if branch.If != (Move{X: last.X, Y: last.Y, D: last.D}) {
+			continue
+		}
+		if _, err := gs.ValidateMove(branch.Reply); err != nil {
+			return false
+		}
+		if len(branch.Then) > 0 {
+			gs.conditionals[owner] = branch.Then
+		}
+		gs.playValidMove(branch.Reply, true)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package game
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func blackConditionalMoves() []ConditionalMove {
+	return []ConditionalMove{
+		ConditionalMove{
+			If:    Move{X: 1, Y: 0, D: DirRight},
+			Reply: Move{X: 5, Y: 0, D: DirLeft},
+			Then: []ConditionalMove{
+				ConditionalMove{
+					If:    Move{X: 2, Y: 0, D: DirLeft},
+					Reply: Move{X: 4, Y: 0, D: DirRight},
+				},
+			},
+		},
+		ConditionalMove{
+			If:    Move{X: 1, Y: 0, D: DirDown},
+			Reply: Move{X: 5, Y: 0, D: DirDown},
+		},
+	}
+}
+
+func TestConditionalMoves(t *testing.T) {
+	gm := newTestGame(t, RealClock, Config{DaysPerMove: 3})
+	playMoves(t, gm, firstMoves)
+	defer gm.state.teardown()
+	white, black := gm.GetWhiteCookie(), gm.GetBlackCookie()
+
+	err := gm.TrySetConditionalMoves(black, blackConditionalMoves())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view := gm.GetClientViewFor(black); len(view.ConditionalMoves) != 2 {
+		t.Errorf("expected black to see their conditional moves, got %+v",
+			view.ConditionalMoves)
+	}
+	for _, c := range []*http.Cookie{white, nil} {
+		if view := gm.GetClientViewFor(c); view.ConditionalMoves != nil {
+			t.Errorf("expected %v not to see black's conditional moves", c)
+		}
+	}
+
+	playMoves(t, gm, []Move{Move{X: 1, Y: 0, D: DirRight}})
+	if len(gm.state.history) != 5 {
+		t.Fatal("expected black's reply to be played")
+	}
+	moves, _ := gm.GetConditionalMoves(black)
+	if len(moves) != 1 || moves[0].If != (Move{X: 2, Y: 0, D: DirLeft}) {
+		t.Errorf("expected the tree to be walked, got %+v", moves)
+	}
+
+	playMoves(t, gm, []Move{Move{X: 2, Y: 0, D: DirLeft}})
+	if len(gm.state.history) != 7 {
+		t.Fatal("expected black's second reply to be played")
+	}
+	if moves, _ := gm.GetConditionalMoves(black); moves != nil {
+		t.Errorf("expected the tree to be used up, got %+v", moves)
+	}
+	if gm.state.agents[agentBlack].time != 3*24*time.Hour ||
+		gm.state.agents[agentWhite].deadline == nil {
+		t.Error("expected white's clock to run and black's to be reset")
+	}
+}
+
+func TestConditionalMovesCancelled(t *testing.T) {
+	gm := newTestGame(t, RealClock, Config{DaysPerMove: 3})
+	playMoves(t, gm, firstMoves)
+	defer gm.state.teardown()
+	black := gm.GetBlackCookie()
+
+	tree := blackConditionalMoves()[:1]
+	if err := gm.TrySetConditionalMoves(black, tree); err != nil {
+		t.Fatal(err)
+	}
+	playMoves(t, gm, []Move{Move{X: 1, Y: 0, D: DirDown}})
+	if gm.state.lastSnapshot().whoseTurn != agentBlack ||
+		gm.state.agents[agentBlack].deadline == nil {
+		t.Fatal("expected black to be left to move with their clock running")
+	}
+	if moves, _ := gm.GetConditionalMoves(black); moves != nil {
+		t.Errorf("expected the tree to be cancelled, got %+v", moves)
+	}
+}
+
+func TestInvalidConditionalMoves(t *testing.T) {
+	gm := newTestGame(t, RealClock, Config{DaysPerMove: 3})
+	playMoves(t, gm, firstMoves)
+	defer gm.state.teardown()
+	white, black := gm.GetWhiteCookie(), gm.GetBlackCookie()
+
+	illegalReply := blackConditionalMoves()
+	illegalReply[0].Then[0].Reply = Move{X: 2, Y: 0, D: DirDown}
+	duplicate := blackConditionalMoves()
+	duplicate[1].If = duplicate[0].If
+	for name, tree := range map[string][]ConditionalMove{
+		"illegal reply": illegalReply,
+		"duplicate":     duplicate,
+	} {
+		if err := gm.TrySetConditionalMoves(black, tree); err == nil {
+			t.Errorf("expected the %s tree to be refused", name)
+		}
+	}
+	if err := gm.TrySetConditionalMoves(
+		white, blackConditionalMoves()); err == nil {
+		t.Error("expected white to have to move first")
+	}
+
+	dark := newTestGame(
+		t, RealClock, Config{DaysPerMove: 3, Variant: VariantDark})
+	playMoves(t, dark, firstMoves)
+	defer dark.state.teardown()
+	if err := dark.TrySetConditionalMoves(
+		dark.GetBlackCookie(), blackConditionalMoves()); err == nil {
+		t.Error("expected conditional moves not to give away hidden cells")
+	}
+
+	live := newTestGame(t, RealClock, Config{TimeControl: time.Minute})
+	playMoves(t, live, firstMoves)
+	defer live.state.teardown()
+	if err := live.TrySetConditionalMoves(
+		live.GetBlackCookie(), blackConditionalMoves()); err == nil {
+		t.Error("expected conditional moves to be for correspondence games")
+	}
+}
+
+func TestSaveAndRestoreConditionalMoves(t *testing.T) {
+	gm := newTestGame(t, RealClock, Config{DaysPerMove: 3})
+	playMoves(t, gm, firstMoves)
+	gm.TrySetConditionalMoves(gm.GetBlackCookie(), blackConditionalMoves())
+
+	restored := saveAndRestore(t, gm)
+	defer restored.state.teardown()
+	playMoves(t, restored, []Move{Move{X: 1, Y: 0, D: DirDown}})
+	if len(restored.state.history) != 5 {
+		t.Error("expected the restored tree to answer white's move")
+	}
+}
@@ -2,17 +2,177 @@ package game
 
 import (
 	"errors"
+	"fmt"
 	"time"
 )
 
 type Config struct {
+	// Each player's starting time. Must be zero for correspondence games.
 	TimeControl time.Duration `json:"timeControlNs"`
+	// If set, this is a correspondence game: players get this many days for
+	// every move instead of a time control.
+	DaysPerMove int `json:"daysPerMove"`
+	// Only used by correspondence games. Once a player runs out of time for a
+	// move, their vacation days are used up one at a time before they lose.
+	VacationDays int `json:"vacationDays"`
+	Clock       ClockType     `json:"clock"`
+	// Increment or delay, depending on the clock. Must be zero for sudden
+	// death.
+	Increment time.Duration `json:"incrementNs"`
+	// What happens once the main time runs out; see OvertimeType. Periods is
+	// only used for byo-yomi and PeriodMoves only for Canadian overtime.
+	Overtime    OvertimeType  `json:"overtime"`
+	Periods     int           `json:"periods"`
+	PeriodTime  time.Duration `json:"periodTimeNs"`
+	PeriodMoves int           `json:"periodMoves"`
+	// Side length of the board (or of its underlying grid for hex boards). Zero
+	// means the variant's standard size.
+	BoardSize int     `json:"boardSize"`
+	Variant   Variant `json:"variant"`
+	// Only used by variants with a generated start position. Zero means a seed
+	// is picked at random when the game is created.
+	Seed int64 `json:"seed"`
+	// Only used by team games. If set, partners pool the reds they capture;
+	// otherwise one of them has to reach the win threshold alone.
+	SharedTeamScore bool `json:"sharedTeamScore"`
+	// Start from this position instead of the variant's start position.
+	StartPosition *Position `json:"startPosition,omitempty"`
+	Handicap      *Handicap `json:"handicap,omitempty"`
+	// Per color; see FirstMoveTimeouts.
+	FirstMoveTimeouts FirstMoveTimeouts `json:"firstMoveTimeoutsNs,omitempty"`
+	// How long the game may be paused for. Zero means a day.
+	MaxAdjournment time.Duration `json:"maxAdjournmentNs"`
+	// Whether players may ask to take back moves. Casual and teaching games
+	// may want this; serious games shouldn't.
+	AllowTakebacks bool `json:"allowTakebacks"`
+	// If set, the game is drawn once this many moves in a row have been
+	// played without a marble being pushed off. With NoCaptureDrawClaim, a
+	// player has to claim the draw instead.
+	NoCaptureDrawMoves int  `json:"noCaptureDrawMoves"`
+	NoCaptureDrawClaim bool `json:"noCaptureDrawClaim"`
+	// If set, the game is the first of a match; see MatchConfig.
+	Match *MatchConfig `json:"match,omitempty"`
 	// More config can go here in the future.
 }
 
 func (c *Config) Validate() error {
-	if c.TimeControl <= 0 || c.TimeControl > time.Hour {
+	if c.IsCorrespondence() {
+		if err := c.validateCorrespondence(); err != nil {
+			return err
+		}
+	} else if c.TimeControl <= 0 || c.TimeControl > time.Hour {
 		return errors.New("time control should be > 0s and <= 1hr")
+	} else if c.VacationDays != 0 {
+		return errors.New("only correspondence games have vacation days")
+	}
+	if err := validateClock(c.Clock, c.Increment); err != nil {
+		return err
+	}
+	if err := c.validateOvertime(); err != nil {
+		return err
+	}
+	if !c.Variant.isValid() {
+		return errors.New("invalid variant")
+	}
+	if c.StartPosition != nil && c.BoardSize != 0 &&
+		c.BoardSize != len(c.StartPosition.Board) {
+		return errors.New("board size doesn't match the start position")
+	}
+	if size := c.boardSize(); size < c.Variant.minBoardSize() ||
+		size > 11 || size%2 == 0 {
+		return fmt.Errorf(
+			"board size should be odd and between %d and 11",
+			c.Variant.minBoardSize())
+	}
+	if c.Seed < 0 || (c.Seed != 0 && !c.Variant.usesSeed()) {
+		return errors.New("seed should be >= 0 and only set for RANDOM games")
+	}
+	if c.SharedTeamScore && c.Variant != VariantTeams {
+		return errors.New("shared team score is only valid for TEAMS games")
+	}
+	if err := c.validateFirstMoveTimeouts(); err != nil {
+		return err
+	}
+	if err := c.validateMaxAdjournment(); err != nil {
+		return err
+	}
+	if err := c.validateNoCaptureDraw(); err != nil {
+		return err
+	}
+	if c.Match != nil {
+		if err := c.Match.validate(); err != nil {
+			return err
+		}
+	}
+	if c.StartPosition != nil {
+		if c.Variant.usesSeed() {
+			return errors.New("RANDOM games can't have a start position")
+		}
+		if c.Handicap != nil {
+			return errors.New("handicaps can't be used with a start position")
+		}
+		return c.StartPosition.validate(c.Variant, c.SharedTeamScore)
+	}
+	if c.Handicap != nil {
+		// Random start positions have as many marbles whatever the seed, which
+		// may not have been generated yet.
+		return c.Handicap.validate(
+			c.Variant, c.Variant.startBoard(c.boardSize(), c.Seed))
 	}
 	return nil
 }
+
+func (c Config) IsCorrespondence() bool {
+	return c.DaysPerMove != 0
+}
+
+func (c *Config) validateCorrespondence() error {
+	if c.DaysPerMove < 1 || c.DaysPerMove > 14 {
+		return errors.New("days per move should be between 1 and 14")
+	}
+	if c.TimeControl != 0 || c.Clock != ClockSuddenDeath ||
+		c.Overtime != OvertimeNone {
+		return errors.New(
+			"correspondence games can't have a time control or overtime")
+	}
+	if c.VacationDays < 0 || c.VacationDays > 30 {
+		return errors.New("vacation days should be between 0 and 30")
+	}
+	return nil
+}
+
+// The time players get for the whole game (or, in correspondence games, for
+// every move).
+func (c Config) startTime() time.Duration {
+	if c.IsCorrespondence() {
+		return c.timePerMove()
+	}
+	return c.TimeControl
+}
+
+func (c Config) timePerMove() time.Duration {
+	return time.Duration(c.DaysPerMove) * 24 * time.Hour
+}
+
+// How long each player has to make their first move before the game is
+// aborted, unless FirstMoveTimeouts says otherwise.
+func (c Config) defaultFirstMoveTimeout() time.Duration {
+	if c.IsCorrespondence() {
+		return c.timePerMove()
+	}
+	return time.Minute
+}
+
+func (c Config) NumPlayers() int {
+	return len(c.Variant.turnOrder())
+}
+
+func (c Config) boardSize() int {
+	if c.StartPosition != nil {
+		return len(c.StartPosition.Board)
+	}
+	if c.BoardSize == 0 {
+		return c.Variant.defaultBoardSize()
+	}
+	return c.BoardSize
+}
@@ -0,0 +1,179 @@
+package game
+
+import (
+	"errors"
+)
+
+// How many times each team may offer a draw over the course of a game.
+const maxDrawOffers = 3
+
+// Why a game was drawn.
+type DrawReason int
+
+const (
+	drawNil DrawReason = iota
+	// The same position came up three times.
+	DrawRepetition
+	// The players agreed to a draw.
+	DrawAgreement
+	// The game was adjourned for too long with the scores level.
+	DrawAdjudicated
+	// Too many moves were played without a capture; see Config.
+	DrawNoCapture
+	// A player claimed a draw after their opponents disconnected.
+	DrawAbandoned
+)
+
+func (r DrawReason) String() string {
+	if r == drawNil {
+		return ""
+	} else if r == DrawRepetition {
+		return "REPETITION"
+	} else if r == DrawAgreement {
+		return "AGREEMENT"
+	} else if r == DrawAdjudicated {
+		return "ADJUDICATED"
+	} else if r == DrawNoCapture {
+		return "NO_CAPTURE"
+	} else if r == DrawAbandoned {
+		return "ABANDONED"
+	} else {
+		panic("invalid draw reason!")
+	}
+}
+
+func drawReasonFromString(s string) (DrawReason, error) {
+	for _, r := range []DrawReason{
+		drawNil, DrawRepetition, DrawAgreement, DrawAdjudicated,
+		DrawNoCapture, DrawAbandoned} {
+		if s == r.String() {
+			return r, nil
+		}
+	}
+	return drawNil, errors.New("invalid draw reason " + s)
+}
+
+// Draws are offered by one team and accepted or declined by the other. An
+// offer stands until it's answered or the team which made it moves.
+type drawOffers struct {
+	// The color of whoever has an open offer, agentNil if nobody does.
+	offeredBy AgentColor
+	// The number of offers each team has made, and the ply of their last one.
+	// Teams can't offer again before another move has been played.
+	made    map[AgentColor]int
+	lastPly map[AgentColor]int
+}
+
+func (d *drawOffers) left(team AgentColor) int {
+	return maxDrawOffers - d.made[team]
+}
+
+// Offers a draw, or accepts the other team's offer. Returns whether the game
+// was drawn.
+func (gs *gameState) offerDraw(agent AgentColor) (bool, error) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	if gs.status != statusOngoing {
+		return false, errors.New("Only ongoing games can be drawn.")
+	}
+	d := &gs.draw
+	if d.offeredBy != agentNil && d.offeredBy.team() != agent.team() {
+		gs.drawBy(DrawAgreement, TerminationAgreement)
+		return true, nil
+	}
+	if d.offeredBy != agentNil {
+		return false, errors.New("Draw already offered.")
+	}
+
+	team := agent.team()
+	ply := len(gs.history) - 1
+	if d.left(team) <= 0 {
+		return false, errors.New("No draw offers left.")
+	}
+	if last, ok := d.lastPly[team]; ok && last == ply {
+		return false, errors.New(
+			"A draw was already offered this move; wait for the next one.")
+	}
+	if d.made == nil {
+		d.made = make(map[AgentColor]int)
+		d.lastPly = make(map[AgentColor]int)
+	}
+	d.offeredBy = agent
+	d.made[team]++
+	d.lastPly[team] = ply
+	return false, nil
+}
+
+func (gs *gameState) acceptDraw(agent AgentColor) error {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	if err := gs.checkDrawAnswer(agent); err != nil {
+		return err
+	}
+	gs.drawBy(DrawAgreement, TerminationAgreement)
+	return nil
+}
+
+func (gs *gameState) declineDraw(agent AgentColor) error {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	if err := gs.checkDrawAnswer(agent); err != nil {
+		return err
+	}
+	gs.draw.offeredBy = agentNil
+	return nil
+}
+
+func (gs *gameState) checkDrawAnswer(agent AgentColor) error {
+	if gs.status != statusOngoing || gs.draw.offeredBy == agentNil {
+		return errors.New("No draw offered.")
+	}
+	if gs.draw.offeredBy.team() == agent.team() {
+		return errors.New("Can't answer your own team's draw offer.")
+	}
+	return nil
+}
+
+// Ends the game in a draw outside of a move, e.g. by agreement.
+func (gs *gameState) drawBy(reason DrawReason, t Termination) {
+	gs.status = statusDraw
+	gs.drawReason = reason
+	gs.terminate(t)
+	gs.draw.offeredBy = agentNil
+	gs.firstMoveDeadline = nil
+	gs.teardown()
+	gs.pause = pauseState{}
+
+	if gs.onGameOver != nil {
+		gs.onGameOver()
+	}
+}
+
+// Offers are withdrawn when the team which made them moves.
+func (gs *gameState) withdrawDrawOffer(mover AgentColor) {
+	if gs.draw.offeredBy != agentNil &&
+		gs.draw.offeredBy.team() == mover.team() {
+		gs.draw.offeredBy = agentNil
+	}
+}
+
+type clientViewDrawOffer struct {
+	// The color with an open offer to draw.
+	OfferedBy string `json:"offeredBy,omitempty"`
+	// Offers each team has left, by team color.
+	OffersLeft map[string]int `json:"offersLeft"`
+}
+
+func (gs *gameState) drawOfferView() clientViewDrawOffer {
+	view := clientViewDrawOffer{
+		OfferedBy:  gs.draw.offeredBy.String(),
+		OffersLeft: make(map[string]int),
+	}
+	for color := range gs.agents {
+		view.OffersLeft[color.team().String()] = gs.draw.left(color.team())
+	}
+	return view
+}
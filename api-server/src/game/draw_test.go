@@ -0,0 +1,206 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDrawByAgreement(t *testing.T) {
+	gameOver := false
+	gm, err := NewGameManager(
+		RealClock, Config{TimeControl: time.Minute}, fakePlayers(), nil,
+		func() { gameOver = true }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	white, black := gm.GetWhiteCookie(), gm.GetBlackCookie()
+
+	if drawn, err := gm.TryOfferDraw(white); drawn || err != nil {
+		t.Fatalf("expected an open offer, got %t, %v", drawn, err)
+	}
+	if _, err := gm.TryOfferDraw(white); err == nil {
+		t.Error("expected offering twice to fail")
+	}
+	if err := gm.TryAcceptDraw(white); err == nil {
+		t.Error("expected white not to be able to accept their own offer")
+	}
+	view := gm.GetClientView()
+	if view.DrawOffer.OfferedBy != "WHITE" ||
+		view.DrawOffer.OffersLeft["WHITE"] != maxDrawOffers-1 ||
+		view.DrawOffer.OffersLeft["BLACK"] != maxDrawOffers {
+		t.Errorf("unexpected draw offer view %+v", view.DrawOffer)
+	}
+
+	if err := gm.TryAcceptDraw(black); err != nil {
+		t.Fatal(err)
+	}
+	if gm.state.status != statusDraw || gm.state.drawReason != DrawAgreement ||
+		!gameOver {
+		t.Errorf("expected a draw by agreement, got %s (%s)", gm.state.status,
+			gm.state.drawReason)
+	}
+	if gm.state.firstMoveTimer != nil {
+		t.Error("expected the game's timers to be stopped")
+	}
+	if view := gm.GetClientView(); view.DrawReason != "AGREEMENT" {
+		t.Errorf("expected the draw reason in the view, got %q",
+			view.DrawReason)
+	}
+}
+
+func TestCrossedDrawOffersAgree(t *testing.T) {
+	gm := newTestGame(t, RealClock, Config{TimeControl: time.Minute})
+	playMoves(t, gm, firstMoves)
+	defer gm.state.teardown()
+
+	gm.TryOfferDraw(gm.GetWhiteCookie())
+	if drawn, err := gm.TryOfferDraw(gm.GetBlackCookie()); !drawn || err != nil {
+		t.Fatalf("expected black's offer to accept white's, got %t, %v", drawn,
+			err)
+	}
+}
+
+func TestDrawOfferWithdrawnOnMove(t *testing.T) {
+	gm := newTestGame(t, RealClock, Config{TimeControl: time.Minute})
+	playMoves(t, gm, firstMoves)
+	defer gm.state.teardown()
+	white, black := gm.GetWhiteCookie(), gm.GetBlackCookie()
+
+	gm.TryOfferDraw(white)
+	if err := gm.TryMove(Move{X: 1, Y: 0, D: DirDown}, white); err != nil {
+		t.Fatal(err)
+	}
+	if gm.state.draw.offeredBy != agentNil {
+		t.Error("expected white's offer to be withdrawn when they moved")
+	}
+	if err := gm.TryAcceptDraw(black); err == nil {
+		t.Error("expected a withdrawn offer not to be accepted")
+	}
+
+	if err := gm.TryMove(Move{X: 5, Y: 0, D: DirDown}, black); err != nil {
+		t.Fatal(err)
+	}
+	// Offers stand while the other team moves.
+	gm.TryOfferDraw(black)
+	if err := gm.TryMove(Move{X: 1, Y: 1, D: DirDown}, white); err != nil {
+		t.Fatal(err)
+	}
+	if gm.state.draw.offeredBy != agentBlack {
+		t.Error("expected black's offer to stand when white moved")
+	}
+	if err := gm.TryMove(Move{X: 5, Y: 1, D: DirDown}, black); err != nil {
+		t.Fatal(err)
+	}
+	if gm.state.draw.offeredBy != agentNil {
+		t.Error("expected black's offer to be withdrawn when they moved")
+	}
+}
+
+func TestDrawOfferLimits(t *testing.T) {
+	gm := newTestGame(t, RealClock, Config{TimeControl: time.Minute})
+	playMoves(t, gm, firstMoves)
+	defer gm.state.teardown()
+	white, black := gm.GetWhiteCookie(), gm.GetBlackCookie()
+
+	gm.TryOfferDraw(white)
+	if err := gm.TryDeclineDraw(black); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gm.TryOfferDraw(white); err == nil {
+		t.Error("expected white not to offer again before the next move")
+	}
+
+	moves := []Move{
+		Move{X: 1, Y: 0, D: DirRight},
+		Move{X: 5, Y: 0, D: DirLeft},
+		Move{X: 2, Y: 0, D: DirDown},
+		Move{X: 4, Y: 0, D: DirDown},
+	}
+	for idx, m := range moves {
+		c := white
+		if idx%2 == 1 {
+			c = black
+		}
+		if err := gm.TryMove(m, c); err != nil {
+			t.Fatal(err)
+		}
+		if idx%2 == 1 {
+			continue
+		}
+		// Black declines between their moves.
+		if _, err := gm.TryOfferDraw(white); err != nil {
+			t.Fatalf("expected offer %d to be allowed, got %v", idx/2+2, err)
+		}
+		gm.TryDeclineDraw(black)
+	}
+	if _, err := gm.TryOfferDraw(white); err == nil {
+		t.Errorf("expected white to run out of offers after %d", maxDrawOffers)
+	}
+	if err := gm.TryDeclineDraw(black); err == nil {
+		t.Error("expected declining without an offer to fail")
+	}
+}
+
+func TestDrawByRepetitionReason(t *testing.T) {
+	gm := newTestGame(t, RealClock, Config{TimeControl: time.Minute})
+	playMoves(t, gm, firstMoves)
+	defer gm.state.teardown()
+	white, black := gm.GetWhiteCookie(), gm.GetBlackCookie()
+
+	// Shuffle back and forth until the position after black's first move
+	// comes up a third time.
+	for i := 0; i < 2; i++ {
+		for _, m := range []struct {
+			move Move
+			c    bool
+		}{
+			{Move{X: 1, Y: 0, D: DirRight}, true},
+			{Move{X: 5, Y: 0, D: DirLeft}, false},
+			{Move{X: 2, Y: 0, D: DirLeft}, true},
+			{Move{X: 4, Y: 0, D: DirRight}, false},
+		} {
+			c := black
+			if m.c {
+				c = white
+			}
+			if err := gm.TryMove(m.move, c); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if gm.state.status != statusDraw ||
+		gm.state.drawReason != DrawRepetition {
+		t.Errorf("expected a draw by repetition, got %s (%s)", gm.state.status,
+			gm.state.drawReason)
+	}
+}
+
+func TestSaveAndRestoreDrawOffers(t *testing.T) {
+	config := Config{DaysPerMove: 3}
+	gm, err := NewGameManager(RealClock, config, fakePlayers(), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gm.TryOfferDraw(gm.GetWhiteCookie())
+
+	restored := saveAndRestore(t, gm)
+	defer restored.state.teardown()
+	if restored.state.draw.offeredBy != agentWhite ||
+		restored.state.draw.left(agentWhite) != maxDrawOffers-1 {
+		t.Errorf("expected white's offer to be restored, got %+v",
+			restored.state.draw)
+	}
+	if _, err := restored.TryOfferDraw(restored.GetWhiteCookie()); err == nil {
+		t.Error("expected the restored offer to count for this move")
+	}
+	if err := restored.TryAcceptDraw(restored.GetBlackCookie()); err != nil {
+		t.Fatal(err)
+	}
+
+	restored = saveAndRestore(t, restored)
+	if restored.state.status != statusDraw ||
+		restored.state.drawReason != DrawAgreement {
+		t.Errorf("expected the draw to be restored, got %s (%s)",
+			restored.state.status, restored.state.drawReason)
+	}
+}
@@ -0,0 +1,59 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+)
+
+// Returned for moves sent from a position the game has since moved on from,
+// say by a double click or a retry after the move already went through.
+var ErrPositionChanged = errors.New(
+	"The position has changed since the move was made.")
+
+// The position the client was looking at when it sent a move. Either part can
+// be left out.
+type MoveExpectation struct {
+	// Moves played so far; see ClientView.Ply.
+	Ply *int `json:"expectedPly,omitempty"`
+	// See ClientView.PositionHash.
+	PositionHash string `json:"expectedPositionHash,omitempty"`
+}
+
+// Identifies the board and whose turn it is, without giving either away.
+func (gs *gameState) positionHash() string {
+	h := fnv.New64a()
+	h.Write([]byte(gs.getPositionString()))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+func (gs *gameState) checkExpectation(expected MoveExpectation) error {
+	if expected.Ply != nil && *expected.Ply != len(gs.history)-1 {
+		return ErrPositionChanged
+	}
+	// Players never get the hash of hidden positions, and checking it would
+	// let them guess at what's hidden.
+	if expected.PositionHash != "" &&
+		!gs.config.Variant.hidesInformation() &&
+		expected.PositionHash != gs.positionHash() {
+		return ErrPositionChanged
+	}
+	return nil
+}
+
+// Like ExecuteMove, but only if the game is still where the mover expected it
+// to be. Illegal moves are refused as such first, so that the expectation
+// only ever tells the mover about positions they could move in.
+func (gs *gameState) ExecuteExpectedMove(
+	move Move, expected MoveExpectation) error {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	if _, err := gs.ValidateMove(move); err != nil {
+		return err
+	}
+	if err := gs.checkExpectation(expected); err != nil {
+		return err
+	}
+	return gs.executeMove(move)
+}
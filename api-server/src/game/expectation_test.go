@@ -0,0 +1,71 @@
+package game
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStaleMovesAreRefused(t *testing.T) {
+	gm, err := NewGameManager(
+		RealClock, Config{TimeControl: time.Minute}, fakePlayers(), nil, nil,
+		nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gm.state.teardown()
+	white, black := gm.GetWhiteCookie(), gm.GetBlackCookie()
+
+	ply := gm.GetClientView().Ply
+	m := Move{X: 0, Y: 0, D: DirDown}
+	expected := MoveExpectation{Ply: &ply}
+	if err := gm.TryExpectedMove(m, expected, 0, white); err != nil {
+		t.Fatal(err)
+	}
+	// The same click again.
+	if err := gm.TryExpectedMove(m, expected, 0, white); err == nil {
+		t.Error("expected the double click to be refused")
+	}
+
+	view := gm.GetClientView()
+	m = Move{X: 6, Y: 0, D: DirDown}
+	err = gm.TryExpectedMove(m, MoveExpectation{Ply: &ply}, 0, black)
+	if err != ErrPositionChanged {
+		t.Errorf("expected the stale ply to be refused, got %v", err)
+	}
+	stale := MoveExpectation{PositionHash: "0123456789abcdef"}
+	err = gm.TryExpectedMove(m, stale, 0, black)
+	if err != ErrPositionChanged {
+		t.Errorf("expected the wrong position to be refused, got %v", err)
+	}
+	expected = MoveExpectation{PositionHash: view.PositionHash}
+	if err := gm.TryExpectedMove(m, expected, 0, black); err != nil {
+		t.Fatal(err)
+	}
+	if gm.GetClientView().PositionHash == view.PositionHash {
+		t.Error("expected the hash to change with the position")
+	}
+}
+
+func TestHiddenPositionsAreNotHashed(t *testing.T) {
+	gm, err := NewGameManager(
+		RealClock, Config{TimeControl: time.Minute, Variant: VariantDark},
+		fakePlayers(), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gm.state.teardown()
+
+	for _, c := range []*http.Cookie{gm.GetWhiteCookie(), nil} {
+		if view := gm.GetClientViewFor(c); view.PositionHash != "" {
+			t.Errorf("expected no position hash for %v", c)
+		}
+	}
+
+	// Guessed hashes are ignored rather than checked.
+	guess := MoveExpectation{PositionHash: "0123456789abcdef"}
+	m := Move{X: 0, Y: 0, D: DirDown}
+	if err := gm.TryExpectedMove(m, guess, 0, gm.GetWhiteCookie()); err != nil {
+		t.Errorf("expected the hash to be ignored, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package game
+
+// Number of plies spectators of a hidden information game lag behind the
+// players, so that they can't relay what they see to either side.
+const spectatorDelay = 6
+
+// Cells of board which players on ac's team can see: the cells holding their
+// own marbles and every cell on their push lines in dirs (up to and including
+// the empty cell a push would stop at). The first cell of each line is what
+// counts as adjacent on the variant's board, e.g. only six of the eight cells
+// around a marble on hex boards.
+func visibleCells(board BoardT, ac AgentColor, dirs []Direction) [][]bool {
+	inBounds := func(x, y int) bool {
+		return y >= 0 && y < len(board) && x >= 0 && x < len(board[y]) &&
+			board[y][x] != marbleVoid
+	}
+	visible := make([][]bool, len(board))
+	for y := range board {
+		visible[y] = make([]bool, len(board[y]))
+	}
+	for y := range board {
+		for x := range board[y] {
+			owner := board[y][x].agent()
+			if owner == agentNil || owner.team() != ac.team() {
+				continue
+			}
+			visible[y][x] = true
+			for _, d := range dirs {
+				lx, ly := x+d.dx(), y+d.dy()
+				for ; inBounds(lx, ly); lx, ly = lx+d.dx(), ly+d.dy() {
+					visible[ly][lx] = true
+					if board[ly][lx] == marbleNil {
+						break
+					}
+				}
+			}
+		}
+	}
+	return visible
+}
+
+// Copy of the board with every cell the viewer can't see hidden.
+func (b BoardT) redact(visible [][]bool) BoardT {
+	redacted := b.deepCopy()
+	for y := range redacted {
+		for x := range redacted[y] {
+			if !visible[y][x] && redacted[y][x] != marbleVoid {
+				redacted[y][x] = marbleHidden
+			}
+		}
+	}
+	return redacted
+}
+
+// The history as players on ac's team saw it: each board is redacted as of
+// that position, and the other team's moves are hidden.
+func (gs *gameState) historyFor(ac AgentColor) []snapshot {
+	history := make([]snapshot, len(gs.history))
+	for i, s := range gs.history {
+		visible := visibleCells(s.board, ac, gs.config.Variant.directions())
+		history[i] = snapshot{
+			board:     s.board.redact(visible),
+			whoseTurn: s.whoseTurn,
+		}
+		if i > 0 && gs.history[i-1].whoseTurn.team() == ac.team() {
+			history[i].lastMove = s.lastMove
+		}
+	}
+	return history
+}
+
+// The history spectators get to see while the game is ongoing.
+func (gs *gameState) delayedHistory() []snapshot {
+	n := len(gs.history) - spectatorDelay
+	if n < 1 {
+		n = 1
+	}
+	return gs.history[:n]
+}
@@ -0,0 +1,134 @@
+package game
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestVisibleCells(t *testing.T) {
+	n, w, b, r := marbleNil, marbleWhite, marbleBlack, marbleRed
+	board := BoardT{
+		{w, r, n, b, n},
+		{n, n, n, n, n},
+		{n, n, n, n, n},
+		{n, n, n, n, n},
+		{n, n, n, n, b},
+	}
+	redacted := board.redact(
+		visibleCells(board, agentWhite, VariantDark.directions()))
+
+	type testCase struct {
+		x, y     int
+		expected Marble
+	}
+	for _, tc := range []testCase{
+		testCase{0, 0, marbleWhite},
+		// Next to white & on its push line
+		testCase{1, 0, marbleRed},
+		// Where the push line ends
+		testCase{2, 0, marbleNil},
+		testCase{0, 1, marbleNil},
+		// Diagonals aren't adjacent on square boards
+		testCase{1, 1, marbleHidden},
+		testCase{3, 0, marbleHidden},
+		testCase{4, 4, marbleHidden},
+		testCase{2, 2, marbleHidden},
+	} {
+		if redacted[tc.y][tc.x] != tc.expected {
+			t.Errorf("(%d, %d): expected %q, got %q",
+				tc.x, tc.y, tc.expected, redacted[tc.y][tc.x])
+		}
+	}
+	// The original board is left alone
+	if board[0][3] != marbleBlack {
+		t.Error("redact modified the board")
+	}
+}
+
+func TestVisibleCellsHex(t *testing.T) {
+	n, w := marbleNil, marbleWhite
+	board := BoardT{
+		{n, n, n, n, n},
+		{n, n, n, n, n},
+		{n, n, w, n, n},
+		{n, n, n, n, n},
+		{n, n, n, n, n},
+	}
+	visible := visibleCells(board, agentWhite, VariantHex.directions())
+
+	// In axial coordinates, (1, 1) and (3, 3) aren't next to (2, 2).
+	type testCase struct {
+		x, y     int
+		expected bool
+	}
+	for _, tc := range []testCase{
+		testCase{2, 2, true},
+		testCase{2, 1, true},
+		testCase{3, 1, true},
+		testCase{1, 2, true},
+		testCase{3, 2, true},
+		testCase{1, 3, true},
+		testCase{2, 3, true},
+		testCase{1, 1, false},
+		testCase{3, 3, false},
+	} {
+		if visible[tc.y][tc.x] != tc.expected {
+			t.Errorf("(%d, %d): expected visible to be %v", tc.x, tc.y,
+				tc.expected)
+		}
+	}
+}
+
+func TestDarkClientViews(t *testing.T) {
+	white, black := fakeWhiteCookie(), fakeBlackCookie()
+	gm, err := NewGameManager(
+		RealClock, Config{TimeControl: time.Minute, Variant: VariantDark},
+		[]*http.Cookie{white, black}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastBoard := func(v ClientView) BoardT {
+		return v.History[len(v.History)-1].board
+	}
+
+	whiteView := gm.GetClientViewFor(white)
+	if lastBoard(whiteView).count(marbleBlack) != 0 {
+		t.Error("white can see black's marbles from the start")
+	}
+	if lastBoard(whiteView).count(marbleHidden) == 0 {
+		t.Error("expected some of the board to be hidden from white")
+	}
+	if len(whiteView.ValidMoves) == 0 {
+		t.Error("expected white to get their valid moves")
+	}
+	if gm.GetClientViewFor(black).ValidMoves != nil {
+		t.Error("black got valid moves on white's turn")
+	}
+	spectatorView := gm.GetClientViewFor(nil)
+	if lastBoard(spectatorView).count(marbleHidden) != 0 {
+		t.Error("expected spectators to see the whole board")
+	}
+
+	if err := gm.TryMove(Move{X: 0, Y: 0, D: DirRight}, white); err != nil {
+		t.Fatal(err)
+	}
+	if gm.GetClientViewFor(white).History[1].lastMove == nil {
+		t.Error("white can't see their own move")
+	}
+	if gm.GetClientViewFor(black).History[1].lastMove != nil {
+		t.Error("black can see white's move")
+	}
+	if len(gm.GetClientViewFor(nil).History) != 1 {
+		t.Error("expected spectators to see the game with a delay")
+	}
+
+	if !gm.TryResign(black) {
+		t.Fatal("couldn't resign")
+	}
+	blackView := gm.GetClientViewFor(black)
+	if len(blackView.History) != 2 || blackView.History[1].lastMove == nil ||
+		lastBoard(blackView).count(marbleHidden) != 0 {
+		t.Error("expected the whole game to be revealed once it's over")
+	}
+}
@@ -3,7 +3,9 @@ package game
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
   "sync"
 )
@@ -11,7 +13,9 @@ import (
 type User struct {
 	cookie *http.Cookie
 	color  AgentColor
-  wantsRematch bool
+	// See Ping.
+	lastSeen          time.Time
+	reportedConnected bool
 }
 
 type clientViewPlayer struct {
@@ -20,7 +24,23 @@ type clientViewPlayer struct {
 	ID       string     `json:"id"`
 	Color    string     `json:"color"`
 	Score    int        `json:"score"`
+	Team     string     `json:"team"`
   WantsRematch bool `json:"wantsRematch"`
+	// Overtime state; see OvertimeType. PeriodsLeft is only used for byo-yomi
+	// and MovesLeft only for Canadian overtime.
+	InOvertime  bool `json:"inOvertime"`
+	PeriodsLeft int  `json:"periodsLeft"`
+	MovesLeft   int  `json:"movesLeft"`
+	// Correspondence games only.
+	VacationDays int `json:"vacationDays"`
+	// Clock time given back for network lag so far; see lagCompensator.
+	LagRefundNs int64 `json:"lagRefundNs"`
+	// Time left on the clock as of the view's ServerTime. Unlike Deadline this
+	// doesn't depend on the client's clock being right. While a simple delay
+	// runs this includes the delay.
+	RemainingMs int64 `json:"remainingMs"`
+	// Whether the player has pinged within the grace period; see Ping.
+	Connected bool `json:"connected"`
 }
 
 type ClientView struct {
@@ -32,6 +52,47 @@ type ClientView struct {
 	ValidMoves        []MoveWMarblesMoved         `json:"validMoves"`
 	FirstMoveDeadline *time.Time                  `json:"firstMoveDeadline"`
 	TimeControl       time.Duration               `json:"timeControl"`
+	Config            Config                      `json:"config"`
+	TeamScores        map[string]int              `json:"teamScores"`
+	// Only set for aborted games: the color of the player who aborted it, or
+	// who didn't make their first move in time, and which of the two it was.
+	AbortedBy   string `json:"abortedBy,omitempty"`
+	AbortReason string `json:"abortReason,omitempty"`
+	// When the view was made, along with the time left for the first move as
+	// of then.
+	ServerTime           time.Time `json:"serverTime"`
+	FirstMoveRemainingMs *int64    `json:"firstMoveRemainingMs"`
+
+	// Open offers to pause or resume, and the state of the pause if paused.
+	Pause clientViewPause `json:"pause"`
+
+	// Only set for drawn games.
+	DrawReason string `json:"drawReason,omitempty"`
+	// The open draw offer, if any, and how many offers each team has left.
+	DrawOffer clientViewDrawOffer `json:"drawOffer"`
+	// The color with an open takeback request, if any.
+	TakebackRequestedBy string `json:"takebackRequestedBy,omitempty"`
+	// Moves played since a marble was last pushed off; see
+	// Config.NoCaptureDrawMoves.
+	MovesSinceCapture int `json:"movesSinceCapture"`
+
+	// Only set for finished games: how the game ended, and how many moves had
+	// been played by then.
+	Termination     string `json:"termination,omitempty"`
+	TerminationMove int    `json:"terminationMove,omitempty"`
+
+	// Only set for matches: the scoreboard.
+	Match *clientViewMatch `json:"match,omitempty"`
+	// Only set once somebody has proposed a rematch.
+	Rematch *clientViewRematch `json:"rematch,omitempty"`
+	// Only ever set in the view of the player they belong to.
+	ConditionalMoves []ConditionalMove `json:"conditionalMoves,omitempty"`
+
+	// Moves played so far and a hash of the current position, for clients to
+	// send along with their moves; see MoveExpectation. The hash is left out
+	// of redacted views.
+	Ply          int    `json:"ply"`
+	PositionHash string `json:"positionHash,omitempty"`
 }
 
 // Handles mapping cookie -> color (black / white) & ensuring players only move
@@ -46,20 +107,38 @@ type GameManager struct {
   onAsyncUpdate func()
   onGameOver func()
   onRematch func()
+	// Only used by matches: the games finished before the current one, oldest
+	// first.
+	matchGames []GameRecord
+	// The rematch being negotiated once the game is over.
+	rematch rematchNegotiation
 
   mutex sync.RWMutex
 }
 
+// players are seated in turn order (white, black, ...).
 func NewGameManager(
-	config Config, white, black *http.Cookie, onAsyncUpdate func(),
-	onGameOver func(), onRematch func()) (*GameManager, error) {
-	if white == nil {
-		return nil, errors.New("Missing white cookie")
+	wallClock Clock, config Config, players []*http.Cookie,
+	onAsyncUpdate func(), onGameOver func(),
+	onRematch func()) (*GameManager, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
 	}
-	if black == nil {
-		return nil, errors.New("Missing black cookie")
+	seats := config.Variant.turnOrder()
+	if len(players) != len(seats) {
+		return nil, fmt.Errorf(
+			"%s games need %d players, got %d",
+			config.Variant, len(seats), len(players))
+	}
+	for idx, c := range players {
+		if c == nil {
+			return nil, errors.New("Missing " + strings.ToLower(
+				seats[idx].String()) + " cookie")
+		}
 	}
-	state, err := newGameState(config, onAsyncUpdate, onGameOver, 1*time.Minute)
+	state, err := newGameState(
+		wallClock, config, onAsyncUpdate, onGameOver,
+		config.defaultFirstMoveTimeout())
 	if err != nil {
 		return nil, err
 	}
@@ -72,11 +151,38 @@ func NewGameManager(
     onGameOver: onGameOver,
     onRematch: onRematch,
 	}
-	gm.setUser(agentWhite, white)
-	gm.setUser(agentBlack, black)
+	for idx, color := range seats {
+		gm.setUser(color, players[idx])
+	}
 	return gm, nil
 }
 
+func (gm *GameManager) GetConfig() Config {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+	return gm.config
+}
+
+func (gm *GameManager) IsPlayer(c *http.Cookie) bool {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+	_, ok := gm.cookieToUser[getKeyFromCookie(c)]
+	return ok
+}
+
+// The ID of the player whose turn it is and the number of moves played so
+// far. ok is false once the game is over.
+func (gm *GameManager) ToMove() (playerID string, ply int, ok bool) {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	if gm.state.status != statusOngoing {
+		return "", 0, false
+	}
+	user := gm.colorToUser[gm.state.lastSnapshot().whoseTurn]
+	return user.cookie.Name, len(gm.state.history) - 1, true
+}
+
 // For tests
 func (gm *GameManager) GetWhiteCookie() *http.Cookie {
 	return gm.colorToUser[agentWhite].cookie
@@ -87,9 +193,12 @@ func (gm *GameManager) GetBlackCookie() *http.Cookie {
 }
 
 func (gm *GameManager) setUser(color AgentColor, cookie *http.Cookie) bool {
+	// Players get a grace period from the start of the game to connect.
 	u := User{
-		cookie: cookie,
-		color:  color,
+		cookie:            cookie,
+		color:             color,
+		lastSeen:          gm.state.wallClock.Now(),
+		reportedConnected: true,
 	}
 	gm.cookieToUser[getKeyFromCookie(cookie)] = &u
 	gm.colorToUser[color] = &u
@@ -97,6 +206,21 @@ func (gm *GameManager) setUser(color AgentColor, cookie *http.Cookie) bool {
 }
 
 func (gm *GameManager) TryMove(m Move, c *http.Cookie) error {
+	return gm.TryMoveWithLag(m, 0, c)
+}
+
+// rtt is the round trip time measured by the player's client (zero if
+// unknown), used to refund the time the move spent in transit.
+func (gm *GameManager) TryMoveWithLag(
+	m Move, rtt time.Duration, c *http.Cookie) error {
+	return gm.TryExpectedMove(m, MoveExpectation{}, rtt, c)
+}
+
+// Like TryMoveWithLag, but fails with ErrPositionChanged unless the game is
+// still in the position expected describes.
+func (gm *GameManager) TryExpectedMove(
+	m Move, expected MoveExpectation, rtt time.Duration,
+	c *http.Cookie) error {
   gm.mutex.RLock()
   defer gm.mutex.RUnlock()
 
@@ -107,7 +231,8 @@ func (gm *GameManager) TryMove(m Move, c *http.Cookie) error {
 	if user.color != gm.state.lastSnapshot().whoseTurn {
 		return errors.New("It is not your turn.")
 	}
-	if err := gm.state.ExecuteMove(m); err != nil {
+	gm.state.reportLag(user.color, rtt)
+	if err := gm.state.ExecuteExpectedMove(m, expected); err != nil {
 		return err
 	}
 	return nil
@@ -121,6 +246,133 @@ func (gm *GameManager) TryResign(c *http.Cookie) bool {
 	return gm.state.resign(user.color)
 }
 
+func (gm *GameManager) IsOver() bool {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+	return gm.state.status.isOver()
+}
+
+// Offers to pause the game, or accepts the other team's offer; see
+// gameState.offerPause. Returns whether the game was paused.
+func (gm *GameManager) TryOfferPause(
+	c *http.Cookie, resumeAt *time.Time) (bool, error) {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	user, ok := gm.cookieToUser[getKeyFromCookie(c)]
+	if !ok {
+		return false, errors.New("Cookie not found.")
+	}
+	return gm.state.offerPause(user.color, resumeAt)
+}
+
+// Returns whether the game was resumed.
+func (gm *GameManager) TryOfferResume(c *http.Cookie) (bool, error) {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	user, ok := gm.cookieToUser[getKeyFromCookie(c)]
+	if !ok {
+		return false, errors.New("Cookie not found.")
+	}
+	return gm.state.offerResume(user.color)
+}
+
+// Offers a draw, or accepts the other team's offer. Returns whether the game
+// was drawn.
+func (gm *GameManager) TryOfferDraw(c *http.Cookie) (bool, error) {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	user, ok := gm.cookieToUser[getKeyFromCookie(c)]
+	if !ok {
+		return false, errors.New("Cookie not found.")
+	}
+	return gm.state.offerDraw(user.color)
+}
+
+func (gm *GameManager) TryAcceptDraw(c *http.Cookie) error {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	user, ok := gm.cookieToUser[getKeyFromCookie(c)]
+	if !ok {
+		return errors.New("Cookie not found.")
+	}
+	return gm.state.acceptDraw(user.color)
+}
+
+func (gm *GameManager) TryDeclineDraw(c *http.Cookie) error {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	user, ok := gm.cookieToUser[getKeyFromCookie(c)]
+	if !ok {
+		return errors.New("Cookie not found.")
+	}
+	return gm.state.declineDraw(user.color)
+}
+
+// Only for games whose config has no capture draws claimed rather than
+// automatic.
+func (gm *GameManager) TryClaimNoCaptureDraw(c *http.Cookie) error {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	if _, ok := gm.cookieToUser[getKeyFromCookie(c)]; !ok {
+		return errors.New("Cookie not found.")
+	}
+	return gm.state.claimNoCaptureDraw()
+}
+
+// Asks the other team to let the player take back their last move.
+func (gm *GameManager) TryRequestTakeback(c *http.Cookie) error {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	user, ok := gm.cookieToUser[getKeyFromCookie(c)]
+	if !ok {
+		return errors.New("Cookie not found.")
+	}
+	return gm.state.requestTakeback(user.color)
+}
+
+func (gm *GameManager) TryAcceptTakeback(c *http.Cookie) error {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	user, ok := gm.cookieToUser[getKeyFromCookie(c)]
+	if !ok {
+		return errors.New("Cookie not found.")
+	}
+	return gm.state.acceptTakeback(user.color)
+}
+
+func (gm *GameManager) TryDeclineTakeback(c *http.Cookie) error {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	user, ok := gm.cookieToUser[getKeyFromCookie(c)]
+	if !ok {
+		return errors.New("Cookie not found.")
+	}
+	return gm.state.declineTakeback(user.color)
+}
+
+// Only possible until every player has made their first move.
+func (gm *GameManager) TryAbort(c *http.Cookie) bool {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	user, ok := gm.cookieToUser[getKeyFromCookie(c)]
+	if !ok {
+		return false
+	}
+	return gm.state.abort(user.color)
+}
+
+// Offers to play again with the same settings, or accepts the open rematch
+// proposal if there is one. Returns whether the rematch started.
 func (gm *GameManager) OfferRematch(c *http.Cookie) (bool, error) {
   err := gm.RematchOfferErrCheck(c)
   if err != nil {
@@ -131,32 +383,71 @@ func (gm *GameManager) OfferRematch(c *http.Cookie) (bool, error) {
   defer gm.mutex.Unlock()
 
 	user := gm.cookieToUser[getKeyFromCookie(c)]
-  user.wantsRematch = true
+	if gm.rematch.proposedBy == agentNil {
+		gm.proposeRematch(user.color, RematchOffer{})
+	} else {
+		gm.rematch.accepted[user.color] = true
+	}
+	return gm.maybeStartRematch()
+}
 
-  if !gm.colorToUser[user.color.otherAgent()].wantsRematch {
-    // Do nothing, don't start a new game.
-    return false, nil
+// Starts the rematch once everybody has accepted the proposal. Nothing
+// changes unless the rematch can be started; if it can't, the proposal is
+// dropped.
+func (gm *GameManager) maybeStartRematch() (bool, error) {
+  for color := range gm.colorToUser {
+    if !gm.rematch.accepted[color] {
+      // Do nothing, don't start a new game.
+      return false, nil
+    }
   }
+	offer := gm.rematch.offer
+
+	// A renegotiated config is given in terms of the rematch's colors.
+	// Otherwise the handicap stays with the weaker player.
+	config := gm.config
+	if offer.Config != nil {
+		config = *offer.Config
+	} else if h := config.Handicap; h != nil && !offer.KeepColors {
+		tmp := *h
+		tmp.Receiver = config.Variant.nextTurn(h.Receiver)
+		config.Handicap = &tmp
+	}
+	state, err := newGameState(
+		gm.state.wallClock, config, gm.onAsyncUpdate, gm.onGameOver,
+		config.defaultFirstMoveTimeout())
+	if err != nil {
+		gm.rematch = rematchNegotiation{}
+		return false, errors.New("Couldn't start the rematch: " + err.Error())
+	}
+	gm.rematch = rematchNegotiation{}
 
   if gm.onRematch != nil {
     gm.onRematch()
   }
 
-  // Swap colors
-  oldWhiteCookie := gm.colorToUser[agentWhite].cookie
-  oldBlackCookie := gm.colorToUser[agentBlack].cookie
-  gm.setUser(agentWhite, oldBlackCookie)
-  gm.setUser(agentBlack, oldWhiteCookie)
-
-  state, err :=
-    newGameState(gm.config, gm.onAsyncUpdate, gm.onGameOver, 1*time.Minute)
-  if err != nil {
-    panic("couldn't make a rematch game! "+err.Error())
-  }
+	// The next game of a match; the record is always there for finished
+	// games.
+	if gm.config.Match != nil {
+		record, _ := gm.record()
+		gm.matchGames = append(gm.matchGames, record)
+	}
 
-  gm.state = state
+  // Everyone moves one seat along (in two player games this swaps colors;
+  // in team games partners stay together and swap who moves first).
+	if !offer.KeepColors {
+		oldCookies := make(map[AgentColor]*http.Cookie)
+		for color, u := range gm.colorToUser {
+			oldCookies[color] = u.cookie
+		}
+		for color, c := range oldCookies {
+			gm.setUser(gm.config.Variant.nextTurn(color), c)
+		}
+	}
+	gm.config = config
+	gm.state = state
 
-  return true, nil
+	return true, nil
 }
 
 // In its own function to make the mutex easier to manage
@@ -164,23 +455,67 @@ func (gm *GameManager) RematchOfferErrCheck(c *http.Cookie) error {
   gm.mutex.RLock()
   defer gm.mutex.RUnlock()
 
-  if gm.state.status == statusOngoing {
+  if !gm.state.status.isOver() {
     return errors.New("Current game is not over.")
   }
 	user, ok := gm.cookieToUser[getKeyFromCookie(c)]
   if !ok {
     return errors.New("Cookie not found.")
   }
-  if user.wantsRematch {
+  if gm.rematch.accepted[user.color] {
     return errors.New("Rematch already requested.")
   }
+	if gm.matchOver() {
+		return errors.New("The match is over.")
+	}
   return nil
 }
 
+// The unredacted view of the game. Use GetClientViewFor when sending the view
+// to anybody.
 func (gm GameManager) GetClientView() ClientView {
   gm.mutex.RLock()
   defer gm.mutex.RUnlock()
 
+	return gm.clientView()
+}
+
+// The view of the game the owner of c is allowed to see. In hidden
+// information variants players only see what their marbles can see, and
+// spectators (any c which isn't a player's, including nil) see the game a few
+// plies late. Once the game is over everybody sees everything.
+func (gm *GameManager) GetClientViewFor(c *http.Cookie) ClientView {
+  gm.mutex.RLock()
+  defer gm.mutex.RUnlock()
+
+	view := gm.clientView()
+	var user *User
+	if c != nil {
+		user = gm.cookieToUser[getKeyFromCookie(c)]
+	}
+	if user != nil {
+		view.ConditionalMoves = gm.state.conditionalMovesFor(user.color)
+	}
+	if !gm.state.config.Variant.hidesInformation() ||
+		gm.state.status.isOver() {
+		return view
+	}
+
+	view.PositionHash = ""
+	if user == nil {
+		view.History = gm.state.delayedHistory()
+		view.ValidMoves = nil
+		return view
+	}
+	view.History = gm.state.historyFor(user.color)
+	if gm.state.lastSnapshot().whoseTurn.team() != user.color.team() {
+		view.ValidMoves = nil
+	}
+	return view
+}
+
+func (gm *GameManager) clientView() ClientView {
+	now := gm.state.wallClock.Now()
 	colorToPlayer := make(map[string]clientViewPlayer)
 	idToPlayer := make(map[string]clientViewPlayer)
 	for color, user := range gm.colorToUser {
@@ -191,14 +526,27 @@ func (gm GameManager) GetClientView() ClientView {
 			Color:    color.String(),
 			ID:       user.cookie.Name,
 			Score:    agent.score,
-      WantsRematch: user.wantsRematch,
+			Team:     color.team().String(),
+      WantsRematch: gm.rematch.accepted[color],
+			InOvertime:   agent.inOvertime,
+			PeriodsLeft:  agent.periods,
+			MovesLeft:    agent.movesLeft,
+			VacationDays: agent.vacation,
+			LagRefundNs:  agent.lag.total.Nanoseconds(),
+			RemainingMs:  agent.remaining(now).Milliseconds(),
+			Connected:    user.connected(now),
 		}
 
 		colorToPlayer[color.String()] = player
 		idToPlayer[user.cookie.Name] = player
 	}
 
-	return ClientView{
+	teamScores := make(map[string]int)
+	for team, score := range gm.state.teamScores() {
+		teamScores[team.String()] = score
+	}
+
+	view := ClientView{
 		History:           gm.state.history,
 		Status:            gm.state.status.String(),
 		WinThreshold:      gm.state.winThreshold,
@@ -207,11 +555,37 @@ func (gm GameManager) GetClientView() ClientView {
 		ValidMoves:        gm.state.validMoves,
 		FirstMoveDeadline: gm.state.firstMoveDeadline,
 		TimeControl:       gm.state.timeControl,
+		Config:            gm.state.config,
+		TeamScores:        teamScores,
+		AbortedBy:         gm.state.abortedBy.String(),
+		AbortReason:       gm.state.abortReason.String(),
+		ServerTime:        now,
+		Pause:             gm.state.pause.clientView(),
+		DrawReason:        gm.state.drawReason.String(),
+		DrawOffer:         gm.state.drawOfferView(),
+
+		TakebackRequestedBy: gm.state.takeback.requestedBy.String(),
+		MovesSinceCapture:   gm.state.sinceCapture,
+
+		Termination:     gm.state.termination.String(),
+		TerminationMove: gm.state.terminationMove,
+
+		Match:   gm.matchView(),
+		Rematch: gm.rematchView(),
+
+		Ply:          len(gm.state.history) - 1,
+		PositionHash: gm.state.positionHash(),
+	}
+	if d := gm.state.firstMoveDeadline; d != nil {
+		remaining := max(d.Sub(now), 0).Milliseconds()
+		view.FirstMoveRemainingMs = &remaining
 	}
+	return view
 }
 
+// Marshals the spectators' view, which is safe to send to anybody.
 func (gm GameManager) MarshalJSON() ([]byte, error) {
-	return json.Marshal(gm.GetClientView())
+	return json.Marshal(gm.GetClientViewFor(nil))
 }
 
 // This way we don't have to worry about what fields the client is / is not
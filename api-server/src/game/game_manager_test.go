@@ -24,10 +24,41 @@ func fakeBlackCookie() *http.Cookie {
 	return &c
 }
 
+func fakePlayers() []*http.Cookie {
+	return []*http.Cookie{fakeWhiteCookie(), fakeBlackCookie()}
+}
+
+// A game between fakePlayers() which nobody has moved in yet.
+func newTestGame(t *testing.T, clock Clock, config Config) *GameManager {
+	gm, err := NewGameManager(clock, config, fakePlayers(), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gm
+}
+
+// Both players make their first move, so white's clock is running.
+var firstMoves = []Move{
+	Move{X: 0, Y: 0, D: DirDown},
+	Move{X: 6, Y: 0, D: DirDown},
+}
+
+// Plays moves in turn, whoever's turn it is.
+func playMoves(t *testing.T, gm *GameManager, moves []Move) {
+	for _, m := range moves {
+		c := gm.GetWhiteCookie()
+		if gm.state.lastSnapshot().whoseTurn == agentBlack {
+			c = gm.GetBlackCookie()
+		}
+		if err := gm.TryMove(m, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 func TestNewGameManager(t *testing.T) {
 	gm, err := NewGameManager(
-		Config{TimeControl: time.Minute}, fakeWhiteCookie(), fakeBlackCookie(),
-		nil, nil, nil)
+		RealClock, Config{TimeControl: time.Minute}, fakePlayers(), nil, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -48,8 +79,7 @@ func TestNewGameManager(t *testing.T) {
 
 func TestTryMove(t *testing.T) {
 	gm, err := NewGameManager(
-		Config{TimeControl: time.Minute}, fakeWhiteCookie(), fakeBlackCookie(),
-		nil, nil, nil)
+		RealClock, Config{TimeControl: time.Minute}, fakePlayers(), nil, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -76,8 +106,8 @@ func TestTryMove(t *testing.T) {
 
 func TestTryResign(t *testing.T) {
 	gm, err := NewGameManager(
-		Config{TimeControl: 600 * time.Second}, fakeWhiteCookie(),
-		fakeBlackCookie(), nil, nil, nil)
+		RealClock, Config{TimeControl: 600 * time.Second}, fakePlayers(), nil,
+		nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -105,8 +135,8 @@ func TestRematchHappyPath(t *testing.T) {
   rematchCb := func() { rematchCbCalled = true }
 
 	gm, err := NewGameManager(
-		Config{TimeControl: 600 * time.Second}, fakeWhiteCookie(),
-		fakeBlackCookie(), nil, gameOverCb, rematchCb)
+		RealClock, Config{TimeControl: 600 * time.Second}, fakePlayers(), nil,
+		gameOverCb, rematchCb)
 
   success := gm.TryResign(fakeWhiteCookie())
   if !success {
@@ -125,7 +155,7 @@ func TestRematchHappyPath(t *testing.T) {
   if rematchStarted {
     t.Error("rematch started after only one player offered a rematch")
   }
-  if !gm.colorToUser[agentWhite].wantsRematch {
+  if !gm.rematch.accepted[agentWhite] {
     t.Error("expected white to want rematch")
   }
   // Try it again with the same cookie to make sure there's an error
@@ -165,20 +195,130 @@ func TestRematchHappyPath(t *testing.T) {
       "exactly 1")
   }
 
-  for _, user := range gm.colorToUser {
-    if user.wantsRematch {
-      t.Error("expected user.wantsRematch to be reset to false")
+  for color := range gm.colorToUser {
+    if gm.rematch.accepted[color] {
+      t.Error("expected the rematch negotiation to be reset")
     }
   }
 }
 
 func TestRematchOngoingGame(t *testing.T) {
 	gm, err := NewGameManager(
-		Config{TimeControl: 600 * time.Second}, fakeWhiteCookie(),
-		fakeBlackCookie(), nil, nil, nil)
+		RealClock, Config{TimeControl: 600 * time.Second}, fakePlayers(), nil,
+		nil, nil)
 
   _, err = gm.OfferRematch(fakeWhiteCookie())
   if err == nil {
     t.Error("expected error; rematch offered while game is still in play.")
   }
 }
+
+func TestNewTeamGameManager(t *testing.T) {
+	config := Config{TimeControl: time.Minute, Variant: VariantTeams}
+	_, err := NewGameManager(RealClock, config, fakePlayers(), nil, nil, nil)
+	if err == nil {
+		t.Error("expected error when seating two players in a team game")
+	}
+
+	players := []*http.Cookie{
+		fakeWhiteCookie(), fakeBlackCookie(),
+		&http.Cookie{Name: "yellow", Value: "9012", Path: "/"},
+		&http.Cookie{Name: "green", Value: "3456", Path: "/"},
+	}
+	gm, err := NewGameManager(RealClock, config, players, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for idx, color := range VariantTeams.turnOrder() {
+		if gm.colorToUser[color].cookie != players[idx] {
+			t.Errorf("expected players[%d] to play %s", idx, color)
+		}
+	}
+	view := gm.GetClientView()
+	if view.ColorToPlayer["YELLOW"].Team != "WHITE" {
+		t.Error("expected yellow to be on white's team")
+	}
+
+	// Black can't move on white's turn
+	err = gm.TryMove(Move{X: 6, Y: 0, D: DirDown}, players[1])
+	if err == nil {
+		t.Error("expected black to have to wait for white")
+	}
+	for idx, m := range []Move{
+		Move{X: 0, Y: 0, D: DirDown},
+		Move{X: 6, Y: 0, D: DirDown},
+		Move{X: 6, Y: 6, D: DirUp},
+		Move{X: 0, Y: 6, D: DirUp},
+	} {
+		if err := gm.TryMove(m, players[idx]); err != nil {
+			t.Errorf("players[%d]: %s", idx, err)
+		}
+	}
+
+	if !gm.TryResign(players[2]) {
+		t.Fatal("couldn't resign with yellow")
+	}
+	if gm.state.status != statusBlackWon {
+		t.Errorf("expected black's team to win, got %s", gm.state.status)
+	}
+
+	for idx, c := range players {
+		rematchStarted, err := gm.OfferRematch(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rematchStarted != (idx == len(players)-1) {
+			t.Errorf("players[%d]: unexpected rematch state %t", idx, rematchStarted)
+		}
+	}
+	// Everyone moves one seat along
+	for idx, c := range players {
+		expected := VariantTeams.nextTurn(VariantTeams.turnOrder()[idx])
+		if gm.cookieToUser[getKeyFromCookie(c)].color != expected {
+			t.Errorf("expected players[%d] to play %s in the rematch", idx, expected)
+		}
+	}
+}
+
+func TestClientViewRemainingTime(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	gm, err := NewGameManager(
+		clock, Config{TimeControl: time.Minute}, fakePlayers(), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gm.state.teardown()
+
+	view := gm.GetClientView()
+	if view.FirstMoveRemainingMs == nil ||
+		*view.FirstMoveRemainingMs != time.Minute.Milliseconds() {
+		t.Errorf("unexpected first move time left %v",
+			view.FirstMoveRemainingMs)
+	}
+	if !view.ServerTime.Equal(clock.Now()) {
+		t.Errorf("expected a current server time, got %s", view.ServerTime)
+	}
+
+	// Both first moves are made, so white's clock is running.
+	err = gm.TryMove(Move{X: 0, Y: 0, D: DirDown}, gm.GetWhiteCookie())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gm.TryMove(Move{X: 6, Y: 0, D: DirDown}, gm.GetBlackCookie())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(10 * time.Second)
+	view = gm.GetClientView()
+	if view.FirstMoveRemainingMs != nil {
+		t.Error("expected no first move time once both players moved")
+	}
+	white := view.ColorToPlayer["WHITE"].RemainingMs
+	if white != 50*time.Second.Milliseconds() {
+		t.Errorf("expected white's time to be running, has %dms", white)
+	}
+	black := view.ColorToPlayer["BLACK"].RemainingMs
+	if black != time.Minute.Milliseconds() {
+		t.Errorf("expected black to have a minute left, has %dms", black)
+	}
+}
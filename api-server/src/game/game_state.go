@@ -16,6 +16,8 @@ const (
 	statusBlackWon
 	statusDraw
 	statusAborted
+	// See pauseState.
+	statusPaused
 )
 
 func (s Status) String() string {
@@ -29,11 +31,29 @@ func (s Status) String() string {
 		return "DRAW"
 	} else if s == statusAborted {
 		return "ABORTED"
+	} else if s == statusPaused {
+		return "PAUSED"
 	} else {
 		panic(fmt.Sprintf("Invalid Status %d!", s))
 	}
 }
 
+func statusFromString(s string) (Status, error) {
+	for _, status := range []Status{
+		statusOngoing, statusWhiteWon, statusBlackWon, statusDraw,
+		statusAborted, statusPaused} {
+		if s == status.String() {
+			return status, nil
+		}
+	}
+	return statusOngoing, errors.New("invalid status " + s)
+}
+
+// Paused games aren't over, but they aren't ongoing either.
+func (s Status) isOver() bool {
+	return s != statusOngoing && s != statusPaused
+}
+
 type snapshot struct {
 	board     BoardT             `json:"board"`
 	lastMove  *MoveWMarblesMoved `json:"lastMove"`
@@ -58,70 +78,124 @@ type gameState struct {
 	ko                *Move
 	winThreshold      int
 	timeControl       time.Duration
+	config            Config // With any generated seed filled in
 	status            Status
 	posToCount        map[string]int
 	validMoves        []MoveWMarblesMoved
 	firstMoveDeadline *time.Time
-	firstMoveTimer    *time.Timer
+	firstMoveTimer    Timer
 	mutex             sync.RWMutex
 	onAsyncUpdate     func()
 	onGameOver        func()
+	// The position the game started from, handicap included.
+	start Position
+	// For colors without their own first move timeout.
+	firstMoveTimeout time.Duration
+	// Only set once the game is aborted.
+	abortedBy   AgentColor
+	abortReason AbortReason
+	pause       pauseState
+	wallClock   Clock
+	// Only set once the game is drawn.
+	drawReason DrawReason
+	draw       drawOffers
+	takeback   takebackRequests
+	// Moves played since a marble was last pushed off.
+	sinceCapture int
+	// Only set once the game is over.
+	termination     Termination
+	terminationMove int
+	// Queued by players waiting for their turn; see premove.go.
+	premoves map[AgentColor]premoveQueue
+	// Correspondence games only; see ConditionalMove.
+	conditionals map[AgentColor][]ConditionalMove
 }
 
 func newGameState(
-	config Config, onAsyncUpdate func(), onGameOver func(),
+	wallClock Clock, config Config, onAsyncUpdate func(), onGameOver func(),
 	firstMoveTimeout time.Duration) (*gameState, error) {
 	if err := config.Validate(); err != nil {
 		return nil, err
 	}
-	var x, R, B, W Marble = marbleNil, marbleRed, marbleBlack, marbleWhite
+	if config.Variant.usesSeed() && config.Seed == 0 {
+		config.Seed = newSeed()
+	}
 	startPosition := []snapshot{
 		snapshot{
-			board: [][]Marble{
-				{W, W, x, x, x, B, B},
-				{W, W, x, R, x, B, B},
-				{x, x, R, R, R, x, x},
-				{x, R, R, R, R, R, x},
-				{x, x, R, R, R, x, x},
-				{B, B, x, R, x, W, W},
-				{B, B, x, x, x, W, W},
-			},
+			board:     config.Variant.startBoard(config.boardSize(), config.Seed),
 			whoseTurn: agentWhite,
 			lastMove:  nil,
 		},
 	}
+	winThreshold := winThresholdFor(startPosition[0].board)
+	var ko *Move
+	scores := make(map[AgentColor]int)
+	if p := config.StartPosition; p != nil {
+		startPosition[0].board = p.Board.deepCopy()
+		startPosition[0].whoseTurn = p.WhoseTurn
+		winThreshold = p.winThreshold()
+		if p.Ko != nil {
+			tmp := *p.Ko
+			ko = &tmp
+		}
+		scores = p.Scores
+	}
+	extraTime := make(map[AgentColor]time.Duration)
+	if h := config.Handicap; h != nil {
+		h.apply(&startPosition[0])
+		scores[h.Receiver] = h.ScoreBonus
+		extraTime[h.Receiver] = h.ExtraTime
+	}
 
 	agents := make(map[AgentColor]*agent)
-	agents[agentWhite] = &agent{
-		score: 0,
-		time:  config.TimeControl,
-	}
-	agents[agentBlack] = &agent{
-		score: 0,
-		time:  config.TimeControl,
+	for _, color := range config.Variant.turnOrder() {
+		agents[color] = &agent{
+			wallClock:   wallClock,
+			score:       scores[color],
+			time:        config.startTime() + extraTime[color],
+			clock:       config.Clock,
+			increment:   config.Increment,
+			overtime:    config.Overtime,
+			periodTime:  config.PeriodTime,
+			periodMoves: config.PeriodMoves,
+			periods:     config.Periods,
+			timePerMove: config.timePerMove(),
+			vacation:    config.VacationDays,
+		}
 	}
 
-	firstMoveDeadline := time.Now().Add(firstMoveTimeout)
+	start := Position{
+		Board:     startPosition[0].board.deepCopy(),
+		WhoseTurn: startPosition[0].whoseTurn,
+		Scores:    make(map[AgentColor]int),
+		Ko:        ko,
+	}
+	for color, a := range agents {
+		start.Scores[color] = a.score
+	}
 
 	gs := gameState{
-		history:           startPosition,
-		agents:            agents,
-		winThreshold:      7,
-		timeControl:       config.TimeControl,
-		posToCount:        make(map[string]int),
-		firstMoveDeadline: &firstMoveDeadline,
-		onAsyncUpdate:     onAsyncUpdate,
-		onGameOver:        onGameOver,
-	}
-	gs.firstMoveTimer =
-		time.AfterFunc(firstMoveTimeout, gs.firstMoveTimeoutCallback)
+		history:          startPosition,
+		agents:           agents,
+		ko:               ko,
+		winThreshold:     winThreshold,
+		timeControl:      config.startTime(),
+		config:           config,
+		start:            start,
+		posToCount:       make(map[string]int),
+		onAsyncUpdate:    onAsyncUpdate,
+		onGameOver:       onGameOver,
+		firstMoveTimeout: firstMoveTimeout,
+		wallClock:        wallClock,
+	}
+	gs.startFirstMoveTimer()
 
 	gs.validMoves = gs.getValidMoves()
 
 	return &gs, nil
 }
 
-func (gs gameState) boardsize() int {
+func (gs *gameState) boardsize() int {
 	return len(gs.history[0].board)
 }
 
@@ -134,18 +208,24 @@ func (gs gameState) getPositionString() string {
 		"%v;%d", gs.lastSnapshot().board, gs.lastSnapshot().whoseTurn)
 }
 
+// Void spaces never change, so the first snapshot is as good as any.
 func (gs *gameState) isInBounds(x, y int) bool {
-	return x >= 0 && x < gs.boardsize() && y >= 0 && y < gs.boardsize()
+	board := gs.history[0].board
+	return y >= 0 && y < len(board) && x >= 0 && x < len(board[y]) &&
+		board[y][x] != marbleVoid
 }
 
 func (gs *gameState) ValidateMove(move Move) (*MoveWMarblesMoved, error) {
 	// Check the game is not already over
+	if gs.status == statusPaused {
+		return nil, errors.New("Game is paused.")
+	}
 	if gs.status != statusOngoing {
 		return nil, errors.New("Game already ended.")
 	}
 
 	// Validate direction
-	if !move.D.isValid() {
+	if !move.D.isValid() || !gs.config.Variant.allowsDirection(move.D) {
 		return nil, errors.New("Direction is invalid.")
 	}
 
@@ -188,7 +268,9 @@ func (gs *gameState) ValidateMove(move Move) (*MoveWMarblesMoved, error) {
 		// Move back one step to the last valid position
 		y -= move.dy()
 		x -= move.dx()
-		if gs.lastSnapshot().board[y][x] == gs.lastSnapshot().whoseTurn.marble() {
+		owner := gs.lastSnapshot().board[y][x].agent()
+		if owner != agentNil &&
+			owner.team() == gs.lastSnapshot().whoseTurn.team() {
 			return nil, errors.New("Can't push own marble off.")
 		}
 	}
@@ -221,34 +303,144 @@ func (gs *gameState) updateStatus() {
 	for t, p := range gs.agents {
 		if p.score >= gs.winThreshold {
 			newStatus = t.winStatus()
+			gs.terminate(TerminationCaptures)
       return
 		}
 	}
+	if gs.config.SharedTeamScore {
+		for team, score := range gs.teamScores() {
+			if score >= gs.winThreshold {
+				newStatus = team.winStatus()
+				gs.terminate(TerminationCaptures)
+				return
+			}
+		}
+	}
 
-	// Win by entrapment
+	// Win by entrapment. In team games a trapped player's turn is skipped for
+	// as long as their partner can still move.
 	gs.validMoves = gs.getValidMoves()
-	if len(gs.validMoves) == 0 {
-		newStatus = gs.lastSnapshot().whoseTurn.otherAgent().winStatus()
-    return
+	for len(gs.validMoves) == 0 {
+		whoseTurn := gs.lastSnapshot().whoseTurn
+		partner := gs.config.Variant.partner(whoseTurn)
+		if partner == agentNil || len(gs.validMovesFor(partner)) == 0 {
+			newStatus = whoseTurn.team().otherAgent().winStatus()
+			gs.terminate(TerminationEntrapment)
+			return
+		}
+		gs.lastSnapshot().whoseTurn = gs.config.Variant.nextTurn(whoseTurn)
+		// Ko only ever restricts the player who moves next.
+		gs.ko = nil
+		gs.validMoves = gs.getValidMoves()
 	}
 
 	// Draw by repetition
 	if gs.posToCount[gs.getPositionString()] >= 3 {
 		newStatus = statusDraw
+		gs.drawReason = DrawRepetition
+		gs.terminate(TerminationRepetition)
     return
 	}
+
+	if gs.noCaptureDrawDue() && !gs.config.NoCaptureDrawClaim {
+		newStatus = statusDraw
+		gs.drawReason = DrawNoCapture
+		gs.terminate(TerminationNoCapture)
+		return
+	}
 }
 
 func (gs *gameState) ExecuteMove(move Move) error {
 	gs.mutex.Lock()
 	defer gs.mutex.Unlock()
 
+	return gs.executeMove(move)
+}
+
+// Plays the move along with any premoves or conditional moves it makes due,
+// then starts the clock of whoever is left to move.
+func (gs *gameState) executeMove(move Move) error {
 	if _, err := gs.ValidateMove(move); err != nil {
 		return err
 	}
-  nextSnapshot := snapshot{
+	gs.playValidMove(move, false)
+	gs.playQueuedMoves()
+
+	if gs.status == statusOngoing && gs.awaitingFirstMoves() {
+		gs.startFirstMoveTimer()
+	} else if gs.status == statusOngoing {
+		agent := gs.agents[gs.lastSnapshot().whoseTurn]
+		if !agent.startTurn(gs.playerTimeoutCallback) {
+			panic("startTurn failed!")
+		}
+	}
+	return nil
+}
+
+// Plays moves lined up in advance for as long as whoever is to move has one.
+// Conditional moves go first, since they were planned for this very position.
+func (gs *gameState) playQueuedMoves() {
+	for gs.status == statusOngoing {
+		if !gs.playConditionalMove() && !gs.playPremove() {
+			return
+		}
+	}
+}
+
+// Plays a (validated) move, stopping the mover's clock. Premoves are played
+// before the mover's clock is started, so there's no clock to stop.
+func (gs *gameState) playValidMove(move Move, premove bool) {
+	gs.withdrawDrawOffer(gs.lastSnapshot().whoseTurn)
+	gs.cancelTakebackRequest()
+
+	if gs.firstMoveTimer != nil {
+		if !gs.firstMoveTimer.Stop() {
+			panic("Ending first move timer failed!")
+		}
+		gs.firstMoveTimer = nil
+		gs.firstMoveDeadline = nil
+	}
+
+	agent := gs.agents[gs.lastSnapshot().whoseTurn]
+	if premove {
+		// Nobody's clock runs until the first moves are in.
+		if !gs.awaitingFirstMoves() {
+			agent.premove()
+		}
+	} else if !agent.endTurn(true) {
+		panic("End player turn failed!")
+	}
+
+	gs.applyMove(move)
+}
+
+// Plays a (validated) move and updates everything but the timers.
+func (gs *gameState) applyMove(move Move) {
+	nextSnapshot, pushedOff, ko := gs.playMove(move)
+	// A red marble was pushed off the board
+	if pushedOff == marbleRed {
+		gs.agents[gs.lastSnapshot().whoseTurn].score++
+	}
+	if pushedOff != marbleNil {
+		gs.sinceCapture = 0
+	} else {
+		gs.sinceCapture++
+	}
+	gs.ko = ko
+
+	gs.history = append(gs.history, nextSnapshot)
+	gs.posToCount[gs.getPositionString()]++
+
+	gs.updateStatus()
+}
+
+// Plays a (validated) move on a copy of the current board without touching
+// any other state. Returns the resulting snapshot, the marble pushed off the
+// board (marbleNil if none) and the reply forbidden by ko (nil if none).
+func (gs *gameState) playMove(move Move) (snapshot, Marble, *Move) {
+	nextSnapshot := snapshot{
 		board:     gs.lastSnapshot().board.deepCopy(),
-		whoseTurn: gs.lastSnapshot().whoseTurn.otherAgent(),
+		whoseTurn: gs.config.Variant.nextTurn(gs.lastSnapshot().whoseTurn),
 		lastMove: &MoveWMarblesMoved{
 			X:            move.X,
 			Y:            move.Y,
@@ -266,56 +458,40 @@ func (gs *gameState) ExecuteMove(move Move) error {
 		}
 		nextSnapshot.lastMove.MarblesMoved++
 	}
-	// A red marble was pushed off the board
-	if !gs.isInBounds(x, y) && tmp == marbleRed {
-		gs.agents[gs.lastSnapshot().whoseTurn].score++
-	}
-	// Check for ko
-	gs.ko = nil
+	pushedOff := marbleNil
 	if !gs.isInBounds(x, y) {
+		pushedOff = tmp
 		x -= move.dx()
 		y -= move.dy()
 	}
-  otherAgentMarble := gs.lastSnapshot().whoseTurn.otherAgent().marble()
-	if nextSnapshot.board[y][x] == otherAgentMarble {
-		gs.ko = &Move{
+	// Check for ko
+	var ko *Move
+	if nextSnapshot.board[y][x] == nextSnapshot.whoseTurn.marble() {
+		ko = &Move{
 			X: x,
 			Y: y,
 			D: move.D.reverse(),
 		}
 	}
-
-	if gs.firstMoveTimer != nil {
-		if !gs.firstMoveTimer.Stop() {
-			panic("Ending first move timer failed!")
-		}
-		gs.firstMoveTimer = nil
-		gs.firstMoveDeadline = nil
-	}
-
-	if !gs.agents[gs.lastSnapshot().whoseTurn].endTurn() {
-		panic("End player turn failed!")
-	}
-
-	gs.history = append(gs.history, nextSnapshot)
-	gs.posToCount[gs.getPositionString()]++
-
-	gs.updateStatus()
-	if gs.status == statusOngoing {
-		agent := gs.agents[gs.lastSnapshot().whoseTurn]
-		if !agent.startTurn(gs.playerTimeoutCallback) {
-			panic("startTurn failed!")
-		}
-	}
-	return nil
+	return nextSnapshot, pushedOff, ko
 }
 
 func (gs *gameState) playerTimeoutCallback() {
 	gs.mutex.Lock()
 	defer gs.mutex.Unlock()
 
+	// Move on to the next overtime period if there is one.
+	agent := gs.agents[gs.lastSnapshot().whoseTurn]
+	if agent.startNextPeriod(gs.playerTimeoutCallback) {
+		if gs.onAsyncUpdate != nil {
+			gs.onAsyncUpdate()
+		}
+		return
+	}
+
 	// The other team just won
-	gs.status = gs.lastSnapshot().whoseTurn.otherAgent().winStatus()
+	gs.status = gs.lastSnapshot().whoseTurn.team().otherAgent().winStatus()
+	gs.terminate(TerminationTimeout)
 
 	gs.updateStatus()
 
@@ -332,8 +508,10 @@ func (gs *gameState) firstMoveTimeoutCallback() {
 	gs.mutex.Lock()
 	defer gs.mutex.Unlock()
 
-	// The other team just won
 	gs.status = statusAborted
+	gs.abortedBy = gs.lastSnapshot().whoseTurn
+	gs.abortReason = AbortNoFirstMove
+	gs.terminate(TerminationNoFirstMove)
 	gs.firstMoveDeadline = nil
 
 	gs.teardown()
@@ -351,16 +529,18 @@ func (gs *gameState) resign(agent AgentColor) bool {
 	gs.mutex.Lock()
 	defer gs.mutex.Unlock()
 
-	if gs.status != statusOngoing {
+	if gs.status.isOver() {
 		return false
 	}
-	gs.status = agent.otherAgent().winStatus()
+	gs.status = agent.team().otherAgent().winStatus()
+	gs.terminate(TerminationResignation)
 
 	if gs.onGameOver != nil {
 		gs.onGameOver()
 	}
 
   gs.teardown()
+	gs.pause = pauseState{}
 	return true
 }
 
@@ -371,8 +551,9 @@ func (gs *gameState) teardown() {
 		gs.firstMoveTimer.Stop()
 		gs.firstMoveTimer = nil
 	}
+	gs.stopPauseTimers()
 	for _, a := range gs.agents {
-		a.endTurn()
+		a.endTurn(false)
 	}
 }
 
@@ -383,7 +564,7 @@ func (gs *gameState) getValidMoves() []MoveWMarblesMoved {
 			if gs.lastSnapshot().board[y][x] != gs.lastSnapshot().whoseTurn.marble() {
 				continue
 			}
-			for _, dir := range []Direction{DirUp, DirDown, DirLeft, DirRight} {
+			for _, dir := range gs.config.Variant.directions() {
 				move := Move{X: x, Y: y, D: dir}
 				if fullMove, err := gs.ValidateMove(move); err == nil {
 					moves = append(moves, *fullMove)
@@ -393,3 +574,22 @@ func (gs *gameState) getValidMoves() []MoveWMarblesMoved {
 	}
 	return moves
 }
+
+// Valid moves for ac if it were their turn in the current position.
+func (gs *gameState) validMovesFor(ac AgentColor) []MoveWMarblesMoved {
+	scratch := gameState{
+		history: []snapshot{
+			snapshot{board: gs.lastSnapshot().board, whoseTurn: ac},
+		},
+		config: gs.config,
+	}
+	return scratch.getValidMoves()
+}
+
+func (gs *gameState) teamScores() map[AgentColor]int {
+	scores := make(map[AgentColor]int)
+	for color, a := range gs.agents {
+		scores[color.team()] += a.score
+	}
+	return scores
+}
@@ -1,10 +1,10 @@
 package game
 
 import (
+	"fmt"
 	"testing"
 	"time"
   // "log"
-	// "fmt"
 )
 
 func makeSingleSnapshotHistory(board BoardT, agent AgentColor) []snapshot {
@@ -18,7 +18,7 @@ func makeSingleSnapshotHistory(board BoardT, agent AgentColor) []snapshot {
 
 func TestCreateDefaultGameState(t *testing.T) {
 	gsWClock, err := newGameState(
-		Config{TimeControl: 60 * time.Second}, nil, nil, 30*time.Second)
+		RealClock, Config{TimeControl: 60 * time.Second}, nil, nil, 30*time.Second)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -27,9 +27,86 @@ func TestCreateDefaultGameState(t *testing.T) {
 	}
 }
 
+func TestDefaultStartPosition(t *testing.T) {
+	var x, R, B, W Marble = marbleNil, marbleRed, marbleBlack, marbleWhite
+	expected := BoardT{
+		{W, W, x, x, x, B, B},
+		{W, W, x, R, x, B, B},
+		{x, x, R, R, R, x, x},
+		{x, R, R, R, R, R, x},
+		{x, x, R, R, R, x, x},
+		{B, B, x, R, x, W, W},
+		{B, B, x, x, x, W, W},
+	}
+	gs, err := newGameState(
+		RealClock, Config{TimeControl: time.Minute}, nil, nil, 30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := gs.lastSnapshot().board
+	if fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Errorf("unexpected start position:\n%v", actual)
+	}
+	if gs.winThreshold != 7 {
+		t.Errorf("expected win threshold 7, got %d", gs.winThreshold)
+	}
+}
+
+func TestStartPositionBoardSizes(t *testing.T) {
+	type testCase struct {
+		size         int
+		winThreshold int
+	}
+	testCases := []testCase{
+		{size: 5, winThreshold: 3},
+		{size: 7, winThreshold: 7},
+		{size: 9, winThreshold: 13},
+		{size: 11, winThreshold: 21},
+	}
+	for _, tc := range testCases {
+		gs, err := newGameState(
+			RealClock, Config{TimeControl: time.Minute, BoardSize: tc.size}, nil, nil,
+			30*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		board := gs.lastSnapshot().board
+		if gs.boardsize() != tc.size {
+			t.Errorf("size %d: got board of size %d", tc.size, gs.boardsize())
+		}
+		if gs.winThreshold != tc.winThreshold {
+			t.Errorf("size %d: expected win threshold %d, got %d",
+				tc.size, tc.winThreshold, gs.winThreshold)
+		}
+		if board.count(marbleWhite) != 8 || board.count(marbleBlack) != 8 {
+			t.Errorf("size %d: expected 8 marbles per player", tc.size)
+		}
+		last := tc.size - 1
+		if board[0][0] != marbleWhite || board[last][last] != marbleWhite ||
+			board[0][last] != marbleBlack || board[last][0] != marbleBlack {
+			t.Errorf("size %d: corners did not match expectation", tc.size)
+		}
+		if board[tc.size/2][tc.size/2] != marbleRed {
+			t.Errorf("size %d: expected red in the center", tc.size)
+		}
+		gs.teardown()
+	}
+}
+
+func TestInvalidBoardSize(t *testing.T) {
+	for _, size := range []int{-7, 3, 6, 8, 13} {
+		gs, err := newGameState(
+			RealClock, Config{TimeControl: time.Minute, BoardSize: size}, nil, nil,
+			30*time.Second)
+		if err == nil || gs != nil {
+			t.Errorf("expected error when creating game with board size %d", size)
+		}
+	}
+}
+
 func TestIsInBounds(t *testing.T) {
 	gs, err := newGameState(
-		Config{TimeControl: time.Minute}, nil, nil, 30*time.Second)
+		RealClock, Config{TimeControl: time.Minute}, nil, nil, 30*time.Second)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -146,8 +223,10 @@ func TestCantPushOffEdge(t *testing.T) {
 }
 
 func TestExecuteMove(t *testing.T) {
+	clock := NewFakeClock(time.Now())
 	gs, err := newGameState(
-		Config{TimeControl: 500 * time.Millisecond}, nil, nil, 30*time.Second)
+		clock, Config{TimeControl: 500 * time.Millisecond}, nil, nil,
+		30*time.Second)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -191,7 +270,7 @@ func TestExecuteMove(t *testing.T) {
 		prevPlayer := gs.lastSnapshot().whoseTurn
 
 		for idx, testCase := range moves {
-			time.Sleep(testCase.sleep)
+			clock.Advance(testCase.sleep)
 			if err := gs.ExecuteMove(testCase.move); (err == nil) != testCase.valid {
 				validityStr := "invalid"
 				if testCase.valid {
@@ -305,7 +384,8 @@ func TestResign(t *testing.T) {
 			onGameOverCalled = true
 		}
 		gs, err := newGameState(
-			Config{TimeControl: 1 * time.Minute}, nil, onGameOver, 30*time.Second)
+			RealClock, Config{TimeControl: 1 * time.Minute}, nil, onGameOver,
+			30*time.Second)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -346,6 +426,7 @@ func TestDrawByRepetition(t *testing.T) {
 			[][]Marble{{R, x, x}, {x, B, x}, {W, x, x}}, agentWhite),
 		winThreshold: 1,
 		posToCount:   make(map[string]int),
+		wallClock:    RealClock,
 	}
 
 	repeat := []Move{
@@ -372,31 +453,36 @@ func TestDrawByRepetition(t *testing.T) {
 }
 
 func TestNotifyOutOfTime(t *testing.T) {
-	done := make(chan struct{})
+	notified := false
 	timeoutCb := func() {
-		done <- struct{}{}
+		notified = true
 	}
+	clock := NewFakeClock(time.Now())
 	gs, err := newGameState(
-		Config{TimeControl: 2 * time.Millisecond}, timeoutCb, nil,
-		1*time.Millisecond)
+		clock, Config{TimeControl: 20 * time.Millisecond}, timeoutCb, nil,
+		time.Minute)
 	if err != nil {
 		t.Fatal(err)
 	}
+	// The clocks start once both players have made their first move.
 	gs.ExecuteMove(Move{X: 0, Y: 0, D: DirDown})
+	gs.ExecuteMove(Move{X: 6, Y: 0, D: DirDown})
+	gs.ExecuteMove(Move{X: 1, Y: 0, D: DirDown})
 	// Do nothing... wait on black to timeout
-	<-done
-	if gs.status != statusWhiteWon {
+	clock.Advance(20 * time.Millisecond)
+	if !notified || gs.status != statusWhiteWon {
 		t.Error("expected black to timeout and white to win")
 	}
 }
 
 func TestFirstMoveDeadline(t *testing.T) {
-	done := make(chan struct{})
+	notified := false
 	timeoutCb := func() {
-		done <- struct{}{}
+		notified = true
 	}
+	clock := NewFakeClock(time.Now())
 	gs, err := newGameState(
-		Config{TimeControl: 1 * time.Millisecond}, timeoutCb, nil,
+		clock, Config{TimeControl: 1 * time.Millisecond}, timeoutCb, nil,
 		1*time.Millisecond)
 	if err != nil {
 		t.Fatal(err)
@@ -404,8 +490,8 @@ func TestFirstMoveDeadline(t *testing.T) {
 
 	// (do nothing)
 
-	<-done
-	if gs.status != statusAborted {
+	clock.Advance(time.Millisecond)
+	if !notified || gs.status != statusAborted {
 		t.Errorf("expected aborted status; got %d", gs.status)
 	}
 }
@@ -418,7 +504,7 @@ func TestInvalidTimeControl(t *testing.T) {
 	}
 	for _, tc := range times {
 		gs, err := newGameState(
-			Config{TimeControl: tc}, nil, nil, 30*time.Second)
+			RealClock, Config{TimeControl: tc}, nil, nil, 30*time.Second)
 		if err == nil {
 			t.Error(err)
 		}
@@ -430,7 +516,7 @@ func TestInvalidTimeControl(t *testing.T) {
 
 func TestWinOverridesDraw(t *testing.T) {
 	gs, _ := newGameState(
-		Config{TimeControl: 60 * time.Second}, nil, nil, 30*time.Second)
+		RealClock, Config{TimeControl: 60 * time.Second}, nil, nil, 30*time.Second)
 
   gs.posToCount[gs.getPositionString()] = 3
   gs.agents[agentWhite].score = 7
@@ -444,3 +530,72 @@ func TestWinOverridesDraw(t *testing.T) {
     t.Error("expected white to win")
   }
 }
+
+func newTeamsTestGameState(board BoardT, whoseTurn AgentColor) *gameState {
+	gs := gameState{
+		history:      makeSingleSnapshotHistory(board, whoseTurn),
+		agents:       make(map[AgentColor]*agent),
+		winThreshold: 7,
+		posToCount:   make(map[string]int),
+		config:       Config{Variant: VariantTeams},
+		wallClock:    RealClock,
+	}
+	for _, ac := range VariantTeams.turnOrder() {
+		gs.agents[ac] = &agent{}
+	}
+	return &gs
+}
+
+func TestTrappedPlayerIsSkipped(t *testing.T) {
+	var B, G, Y, x Marble = marbleBlack, marbleGreen, marbleYellow, marbleNil
+
+	// White has no marbles left, but their partner can still move.
+	gs := newTeamsTestGameState(
+		[][]Marble{{x, x, x}, {x, Y, x}, {B, x, G}}, agentWhite)
+	gs.updateStatus()
+	if gs.status != statusOngoing {
+		t.Errorf("expected game to continue, got %s", gs.status)
+	}
+	if gs.lastSnapshot().whoseTurn != agentBlack {
+		t.Errorf("expected black to move, got %s", gs.lastSnapshot().whoseTurn)
+	}
+	if len(gs.validMoves) == 0 {
+		t.Error("expected black to have valid moves")
+	}
+
+	// Now neither white nor their partner can move.
+	gs = newTeamsTestGameState(
+		[][]Marble{{x, x, x}, {x, B, x}, {x, x, G}}, agentWhite)
+	gs.updateStatus()
+	if gs.status != statusBlackWon {
+		t.Errorf("expected black's team to win, got %s", gs.status)
+	}
+}
+
+func TestSharedTeamScore(t *testing.T) {
+	var B, G, W, Y, x Marble = marbleBlack, marbleGreen, marbleWhite,
+		marbleYellow, marbleNil
+	board := [][]Marble{{W, x, B}, {x, x, x}, {G, x, Y}}
+
+	for _, shared := range []bool{false, true} {
+		gs := newTeamsTestGameState(board, agentBlack)
+		gs.config.SharedTeamScore = shared
+		gs.agents[agentWhite].score = 4
+		gs.agents[agentYellow].score = 3
+		gs.updateStatus()
+		if shared && gs.status != statusWhiteWon {
+			t.Errorf("expected white's team to win, got %s", gs.status)
+		} else if !shared && gs.status != statusOngoing {
+			t.Errorf("expected game to continue, got %s", gs.status)
+		}
+	}
+}
+
+func TestCantPushPartnerOff(t *testing.T) {
+	var W, Y, x Marble = marbleWhite, marbleYellow, marbleNil
+
+	gs := newTeamsTestGameState([][]Marble{{x, W, Y}}, agentWhite)
+	if _, err := gs.ValidateMove(Move{X: 1, Y: 0, D: DirRight}); err == nil {
+		t.Error("could push partner's marble off")
+	}
+}
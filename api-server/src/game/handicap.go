@@ -0,0 +1,109 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Evens out games between players of different strength. Every setting
+// favors the receiver; any combination of them may be used.
+type Handicap struct {
+	// The color of the weaker player.
+	Receiver AgentColor
+	// Reds the receiver starts the game with.
+	ScoreBonus int
+	// Number of the stronger player's marbles taken off the board before the
+	// game starts, from the top row down.
+	RemovedMarbles int
+	// Time added to the receiver's clock.
+	ExtraTime time.Duration
+	// The receiver moves first, whatever their color. This only changes
+	// anything while they play black, but it follows them from game to game
+	// like the rest of the handicap (see GameManager.maybeStartRematch).
+	FirstMove bool
+}
+
+type handicapJSON struct {
+	Receiver       string        `json:"receiver"`
+	ScoreBonus     int           `json:"scoreBonus"`
+	RemovedMarbles int           `json:"removedMarbles"`
+	ExtraTime      time.Duration `json:"extraTimeNs"`
+	FirstMove      bool          `json:"firstMove"`
+}
+
+func (h Handicap) MarshalJSON() ([]byte, error) {
+	return json.Marshal(handicapJSON{
+		Receiver:       h.Receiver.String(),
+		ScoreBonus:     h.ScoreBonus,
+		RemovedMarbles: h.RemovedMarbles,
+		ExtraTime:      h.ExtraTime,
+		FirstMove:      h.FirstMove,
+	})
+}
+
+func (h *Handicap) UnmarshalJSON(raw []byte) error {
+	var tmp handicapJSON
+	if err := json.Unmarshal(raw, &tmp); err != nil {
+		return err
+	}
+	receiver, ok := agentColorFromString(tmp.Receiver)
+	if !ok {
+		return errors.New("invalid handicap receiver " + tmp.Receiver)
+	}
+	*h = Handicap{
+		Receiver:       receiver,
+		ScoreBonus:     tmp.ScoreBonus,
+		RemovedMarbles: tmp.RemovedMarbles,
+		ExtraTime:      tmp.ExtraTime,
+		FirstMove:      tmp.FirstMove,
+	}
+	return nil
+}
+
+func (h *Handicap) giver() AgentColor {
+	return h.Receiver.otherAgent()
+}
+
+// Checks the handicap against the start board it will be applied to.
+func (h *Handicap) validate(v Variant, board BoardT) error {
+	if v.partner(agentWhite) != agentNil {
+		return errors.New("handicaps are only supported in two player games")
+	}
+	if !v.isSeated(h.Receiver) {
+		return errors.New("handicap receiver should be WHITE or BLACK")
+	}
+	if h.ScoreBonus < 0 || h.ScoreBonus >= winThresholdFor(board) {
+		return errors.New("handicap score bonus can't win the game outright")
+	}
+	if h.RemovedMarbles < 0 ||
+		h.RemovedMarbles >= board.count(h.giver().marble()) {
+		return errors.New(
+			"handicap can't remove all of the stronger player's marbles")
+	}
+	if h.ExtraTime < 0 || h.ExtraTime > time.Hour {
+		return errors.New("handicap extra time should be >= 0s and <= 1hr")
+	}
+	if h.ScoreBonus == 0 && h.RemovedMarbles == 0 && h.ExtraTime == 0 &&
+		!h.FirstMove {
+		return errors.New("handicap doesn't do anything")
+	}
+	return nil
+}
+
+// Applies the handicap's changes to the start position.
+func (h *Handicap) apply(start *snapshot) {
+	removed := 0
+	for y := range start.board {
+		for x := range start.board[y] {
+			if removed < h.RemovedMarbles &&
+				start.board[y][x] == h.giver().marble() {
+				start.board[y][x] = marbleNil
+				removed++
+			}
+		}
+	}
+	if h.FirstMove {
+		start.whoseTurn = h.Receiver
+	}
+}
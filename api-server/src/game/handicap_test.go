@@ -0,0 +1,190 @@
+package game
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHandicapJSON(t *testing.T) {
+	raw := `{"timeControlNs": 60000000000, "handicap": {"receiver": "BLACK",
+		"scoreBonus": 1, "removedMarbles": 2, "extraTimeNs": 30000000000,
+		"firstMove": true}}`
+	var config Config
+	if err := json.Unmarshal([]byte(raw), &config); err != nil {
+		t.Fatal(err)
+	}
+	expected := Handicap{
+		Receiver:       agentBlack,
+		ScoreBonus:     1,
+		RemovedMarbles: 2,
+		ExtraTime:      30 * time.Second,
+		FirstMove:      true,
+	}
+	if *config.Handicap != expected {
+		t.Errorf("expected %v, got %v", expected, *config.Handicap)
+	}
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInvalidHandicap(t *testing.T) {
+	type testCase struct {
+		handicap Handicap
+		variant  Variant
+		reason   string
+	}
+	for _, tc := range []testCase{
+		testCase{Handicap{Receiver: agentBlack}, VariantStandard, "no-op"},
+		testCase{
+			Handicap{Receiver: agentYellow, ScoreBonus: 1}, VariantStandard,
+			"yellow receiver"},
+		testCase{
+			Handicap{Receiver: agentWhite, ScoreBonus: 1}, VariantTeams,
+			"team game"},
+		testCase{
+			Handicap{Receiver: agentBlack, ScoreBonus: 7}, VariantStandard,
+			"score bonus wins"},
+		testCase{
+			Handicap{Receiver: agentBlack, RemovedMarbles: 8}, VariantStandard,
+			"all marbles removed"},
+		testCase{
+			Handicap{Receiver: agentBlack, ScoreBonus: 10}, VariantHex,
+			"score bonus wins on hex"},
+		testCase{
+			Handicap{Receiver: agentBlack, RemovedMarbles: 9}, VariantHex,
+			"all hex marbles removed"},
+		testCase{
+			Handicap{Receiver: agentBlack, ExtraTime: -time.Second},
+			VariantStandard, "negative extra time"},
+	} {
+		handicap := tc.handicap
+		config := Config{
+			TimeControl: time.Minute, Variant: tc.variant, Handicap: &handicap,
+		}
+		if err := config.Validate(); err == nil {
+			t.Errorf("%s: expected error", tc.reason)
+		}
+	}
+}
+
+func TestHandicapStart(t *testing.T) {
+	config := Config{
+		TimeControl: time.Minute,
+		Handicap: &Handicap{
+			Receiver:       agentBlack,
+			ScoreBonus:     2,
+			RemovedMarbles: 3,
+			ExtraTime:      time.Minute,
+			FirstMove:      true,
+		},
+	}
+	gs, err := newGameState(RealClock, config, nil, nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gs.teardown()
+
+	board := gs.lastSnapshot().board
+	if board.count(marbleWhite) != 5 || board.count(marbleBlack) != 8 {
+		t.Errorf("expected 3 of white's marbles to be removed, got\n%v", board)
+	}
+	// Removed from the top row down
+	if board[0][0] != marbleNil || board[0][1] != marbleNil ||
+		board[1][0] != marbleNil || board[1][1] != marbleWhite {
+		t.Errorf("unexpected marbles removed\n%v", board)
+	}
+	if gs.lastSnapshot().whoseTurn != agentBlack {
+		t.Error("expected black to move first")
+	}
+	if gs.agents[agentBlack].score != 2 || gs.agents[agentWhite].score != 0 {
+		t.Error("expected black to start with 2 reds")
+	}
+	if gs.agents[agentBlack].time != 2*time.Minute ||
+		gs.agents[agentWhite].time != time.Minute {
+		t.Error("expected black to get an extra minute")
+	}
+	expected := "5BB/1W1R1BB/2RRR2/1RRRRR1/2RRR2/BB1R1WW/BB3WW b 0,2 -"
+	if gs.start.TFEN() != expected {
+		t.Errorf("expected start position %q, got %q", expected, gs.start.TFEN())
+	}
+}
+
+func TestFirstMoveHandicapForWhite(t *testing.T) {
+	config := Config{
+		TimeControl: time.Minute,
+		Handicap:    &Handicap{Receiver: agentWhite, FirstMove: true},
+	}
+	gs, err := newGameState(RealClock, config, nil, nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gs.teardown()
+	if gs.lastSnapshot().whoseTurn != agentWhite {
+		t.Error("expected white to move first")
+	}
+}
+
+func TestHandicapFollowsPlayerInRematch(t *testing.T) {
+	config := Config{
+		TimeControl: time.Minute,
+		Handicap:    &Handicap{Receiver: agentBlack, ScoreBonus: 1},
+	}
+	white, black := fakeWhiteCookie(), fakeBlackCookie()
+	gm, err := NewGameManager(
+		RealClock, config, []*http.Cookie{white, black}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gm.TryResign(white) {
+		t.Fatal("couldn't resign")
+	}
+	for _, c := range []*http.Cookie{white, black} {
+		if _, err := gm.OfferRematch(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer gm.state.teardown()
+
+	// The former black player now plays white and keeps the handicap.
+	if gm.state.agents[agentWhite].score != 1 {
+		t.Error("expected the handicap to follow the player")
+	}
+	if config.Handicap.Receiver != agentBlack {
+		t.Error("rematch modified the original config")
+	}
+}
+
+// The handicap's receiver moves first in every game they play black.
+func TestFirstMoveHandicapInRematches(t *testing.T) {
+	for _, match := range []*MatchConfig{nil, &MatchConfig{Games: 3}} {
+		config := Config{
+			TimeControl: time.Minute,
+			Handicap:    &Handicap{Receiver: agentBlack, FirstMove: true},
+			Match:       match,
+		}
+		gm, err := NewGameManager(
+			RealClock, config, fakePlayers(), nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		order := []AgentColor{agentBlack, agentWhite, agentBlack}
+		for game, first := range order {
+			if gm.state.lastSnapshot().whoseTurn != first {
+				t.Errorf("game %d: expected %s to move first", game+1, first)
+			}
+			receiver := gm.colorToUser[gm.config.Handicap.Receiver].cookie
+			if receiver.Name != "black" {
+				t.Errorf("game %d: expected the handicap to follow black",
+					game+1)
+			}
+			if game < 2 {
+				gm.TryResign(gm.GetWhiteCookie())
+				rematch(t, gm)
+			}
+		}
+		gm.state.teardown()
+	}
+}
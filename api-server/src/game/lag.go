@@ -0,0 +1,75 @@
+package game
+
+import (
+	"time"
+)
+
+const (
+	// The most a single move can be refunded.
+	maxLagRefund = 500 * time.Millisecond
+	// Refunds come out of a quota, which refills by lagQuotaRefill for every
+	// second that passes, up to maxLagQuota. This keeps players from claiming
+	// lag on every move of a fast game.
+	lagQuotaRefill = 100 * time.Millisecond
+	maxLagQuota    = 2 * time.Second
+	// Reported round trips longer than this are ignored as bogus.
+	maxReportedLag = 5 * time.Second
+)
+
+// Gives players back the clock time their moves spent in transit. The
+// server only sees when a move arrives, so the time the position took to
+// reach the player and their move took to come back runs on their clock.
+type lagCompensator struct {
+	// Smoothed round trip time, as reported by the player's client.
+	estimate   time.Duration
+	quota      time.Duration
+	lastRefill time.Time
+	// Everything refunded so far and the number of moves it was spread over.
+	total time.Duration
+	moves int
+}
+
+// Takes a round trip time measured by the player's client into account.
+func (l *lagCompensator) report(rtt time.Duration) {
+	if rtt <= 0 || rtt > maxReportedLag {
+		return
+	}
+	if l.estimate == 0 {
+		l.estimate = rtt
+		return
+	}
+	// Single spikes shouldn't count for much.
+	l.estimate = (3*l.estimate + rtt) / 4
+}
+
+// How much of used (the time the player's clock ran for their move) to give
+// back.
+func (l *lagCompensator) refund(
+	used time.Duration, now time.Time) time.Duration {
+	if l.lastRefill.IsZero() {
+		l.quota = maxLagQuota
+	} else {
+		elapsed := now.Sub(l.lastRefill)
+		l.quota += time.Duration(elapsed.Seconds() * float64(lagQuotaRefill))
+		l.quota = min(l.quota, maxLagQuota)
+	}
+	l.lastRefill = now
+
+	refund := min(l.estimate, maxLagRefund, l.quota, used)
+	if refund <= 0 {
+		return 0
+	}
+	l.quota -= refund
+	l.total += refund
+	l.moves++
+	return refund
+}
+
+func (gs *gameState) reportLag(agent AgentColor, rtt time.Duration) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	if a := gs.agents[agent]; a != nil {
+		a.lag.report(rtt)
+	}
+}
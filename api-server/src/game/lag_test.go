@@ -0,0 +1,76 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLagEstimate(t *testing.T) {
+	var l lagCompensator
+	l.report(200 * time.Millisecond)
+	if l.estimate != 200*time.Millisecond {
+		t.Errorf("expected the first report to be taken as is, got %s",
+			l.estimate)
+	}
+	l.report(600 * time.Millisecond)
+	if l.estimate != 300*time.Millisecond {
+		t.Errorf("expected a smoothed estimate of 300ms, got %s", l.estimate)
+	}
+	// Ignored
+	l.report(-time.Second)
+	l.report(time.Minute)
+	if l.estimate != 300*time.Millisecond {
+		t.Errorf("expected bogus reports to be ignored, got %s", l.estimate)
+	}
+}
+
+func TestLagRefundLimits(t *testing.T) {
+	l := lagCompensator{estimate: time.Second}
+	now := time.Now()
+	// Capped per move...
+	if refund := l.refund(time.Minute, now); refund != maxLagRefund {
+		t.Errorf("expected a refund of %s, got %s", maxLagRefund, refund)
+	}
+	// ...and by the time actually used...
+	used := 100 * time.Millisecond
+	if refund := l.refund(used, now); refund != used {
+		t.Errorf("expected a refund of %s, got %s", used, refund)
+	}
+	// ...and by the quota, which doesn't refill while no time passes.
+	var total time.Duration
+	for i := 0; i < 10; i++ {
+		total += l.refund(time.Minute, now)
+	}
+	if total > maxLagQuota {
+		t.Errorf("expected at most %s in refunds, got %s", maxLagQuota, total)
+	}
+	if l.total != maxLagRefund+used+total || l.moves < 2 {
+		t.Errorf("refunds weren't recorded (%s over %d moves)", l.total,
+			l.moves)
+	}
+	// Five seconds later, the quota has refilled by half a second.
+	if refund := l.refund(time.Minute, now.Add(5*time.Second)); refund !=
+		5*lagQuotaRefill {
+		t.Errorf("expected a refund of %s, got %s", 5*lagQuotaRefill, refund)
+	}
+}
+
+func TestLagRefundOnMove(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	a := agent{time: time.Minute, wallClock: clock}
+	a.lag.report(time.Second)
+	if !a.startTurn(func() {}) {
+		t.Fatal("couldn't start turn")
+	}
+	clock.Advance(20 * time.Millisecond)
+	if !a.endTurn(true) {
+		t.Fatal("couldn't end turn")
+	}
+	// All 20ms were put down to lag.
+	if a.time != time.Minute {
+		t.Errorf("expected the move's time to be refunded, has %s", a.time)
+	}
+	if a.lag.total != 20*time.Millisecond {
+		t.Errorf("expected the refund to be recorded, got %s", a.lag.total)
+	}
+}
@@ -0,0 +1,149 @@
+package game
+
+import (
+	"errors"
+)
+
+// Matches are played as a series of rematches, with players moving one seat
+// along (so swapping colors) every game. Players score a point for a win and
+// half a point for a draw. Aborted games don't count towards the number of
+// games.
+type MatchConfig struct {
+	Games int `json:"games"`
+	// If set, the match ends as soon as somebody has more than half of the
+	// points there are to play for, rather than once every game is played.
+	BestOf bool `json:"bestOf"`
+}
+
+func (m *MatchConfig) validate() error {
+	if m.Games < 2 || m.Games > 25 {
+		return errors.New("matches should have between 2 and 25 games")
+	}
+	if m.BestOf && m.Games%2 == 0 {
+		return errors.New("best-of matches need an odd number of games")
+	}
+	return nil
+}
+
+// Everything needed to archive a match.
+type MatchRecord struct {
+	Match MatchConfig `json:"match"`
+	// Points by player ID.
+	Scores map[string]float64 `json:"scores"`
+	// Finished games, oldest first.
+	Games []GameRecord `json:"games"`
+	Over  bool         `json:"over"`
+}
+
+type clientViewMatch struct {
+	Games  int  `json:"games"`
+	BestOf bool `json:"bestOf"`
+	// Points by player ID.
+	Scores map[string]float64 `json:"scores"`
+	// Finished games, oldest first.
+	Results []clientViewMatchResult `json:"results"`
+	Over    bool                    `json:"over"`
+}
+
+type clientViewMatchResult struct {
+	// Player IDs by color.
+	Players     map[string]string `json:"players"`
+	Status      string            `json:"status"`
+	Termination string            `json:"termination,omitempty"`
+}
+
+func (gm *GameManager) GetMatchRecord() (MatchRecord, error) {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	if gm.config.Match == nil {
+		return MatchRecord{}, errors.New("This game isn't part of a match.")
+	}
+	games := gm.finishedMatchGames()
+	scores, _ := matchScores(games)
+	return MatchRecord{
+		Match:  *gm.config.Match,
+		Scores: scores,
+		Games:  games,
+		Over:   gm.matchOver(),
+	}, nil
+}
+
+// The finished games of the match, the current one included once it's over.
+func (gm *GameManager) finishedMatchGames() []GameRecord {
+	games := append([]GameRecord{}, gm.matchGames...)
+	if gm.state.status.isOver() {
+		if record, err := gm.record(); err == nil {
+			games = append(games, record)
+		}
+	}
+	return games
+}
+
+// Each player's points, and the number of games which counted.
+func matchScores(games []GameRecord) (map[string]float64, int) {
+	scores := make(map[string]float64)
+	played := 0
+	for _, g := range games {
+		status, _ := statusFromString(g.Status)
+		if status != statusAborted {
+			played++
+		}
+		for s, id := range g.Players {
+			color, _ := agentColorFromString(s)
+			points := 0.0
+			if status == statusDraw {
+				points = 0.5
+			} else if status == color.team().winStatus() {
+				points = 1
+			}
+			scores[id] += points
+		}
+	}
+	return scores, played
+}
+
+func (gm *GameManager) matchOver() bool {
+	m := gm.config.Match
+	if m == nil {
+		return false
+	}
+	scores, played := matchScores(gm.finishedMatchGames())
+	if played >= m.Games {
+		return true
+	}
+	for _, points := range scores {
+		if m.BestOf && points > float64(m.Games)/2 {
+			return true
+		}
+	}
+	return false
+}
+
+func (gm *GameManager) matchView() *clientViewMatch {
+	m := gm.config.Match
+	if m == nil {
+		return nil
+	}
+	games := gm.finishedMatchGames()
+	scores, _ := matchScores(games)
+	view := &clientViewMatch{
+		Games:   m.Games,
+		BestOf:  m.BestOf,
+		Scores:  scores,
+		Results: []clientViewMatchResult{},
+		Over:    gm.matchOver(),
+	}
+	// Players who haven't finished a game yet still get a score.
+	for _, user := range gm.colorToUser {
+		scores[user.cookie.Name] += 0
+	}
+	for _, g := range games {
+		view.Results = append(view.Results, clientViewMatchResult{
+			Players:     g.Players,
+			Status:      g.Status,
+			Termination: g.Termination,
+		})
+	}
+	return view
+}
@@ -0,0 +1,149 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+func rematch(t *testing.T, gm *GameManager) {
+	if _, err := gm.OfferRematch(fakeWhiteCookie()); err != nil {
+		t.Fatal(err)
+	}
+	if started, err := gm.OfferRematch(fakeBlackCookie()); !started ||
+		err != nil {
+		t.Fatalf("expected the next game to start, got %t, %v", started, err)
+	}
+}
+
+func TestBestOfMatch(t *testing.T) {
+	gm := newTestGame(t, RealClock, Config{
+		TimeControl: time.Minute,
+		Match:       &MatchConfig{Games: 3, BestOf: true},
+	})
+	defer gm.state.teardown()
+
+	// The player who started as white loses both games, whatever their color.
+	gm.TryResign(fakeWhiteCookie())
+	view := gm.GetClientView()
+	if view.Match == nil || view.Match.Scores["black"] != 1 ||
+		view.Match.Scores["white"] != 0 || len(view.Match.Results) != 1 {
+		t.Fatalf("unexpected scoreboard %+v", view.Match)
+	}
+	rematch(t, gm)
+	if gm.GetBlackCookie().Name != "white" {
+		t.Error("expected colors to alternate")
+	}
+	view = gm.GetClientView()
+	if len(view.Match.Results) != 1 || view.Match.Over {
+		t.Errorf("expected one finished game, got %+v", view.Match)
+	}
+
+	gm.TryResign(fakeWhiteCookie())
+	view = gm.GetClientView()
+	if view.Match.Scores["black"] != 2 || !view.Match.Over {
+		t.Errorf("expected the match to be decided, got %+v", view.Match)
+	}
+	if view.Match.Results[1].Players["WHITE"] != "black" ||
+		view.Match.Results[1].Status != "WHITE_WON" {
+		t.Errorf("unexpected second result %+v", view.Match.Results[1])
+	}
+	if _, err := gm.OfferRematch(fakeWhiteCookie()); err == nil {
+		t.Error("expected no more games once the match is decided")
+	}
+
+	record, err := gm.GetMatchRecord()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(record.Games) != 2 || !record.Over || record.Scores["black"] != 2 {
+		t.Errorf("unexpected match record %+v", record)
+	}
+}
+
+func TestFixedMatchSkipsAborts(t *testing.T) {
+	gm := newTestGame(t, RealClock, Config{
+		TimeControl: time.Minute,
+		Match:       &MatchConfig{Games: 2},
+	})
+	defer gm.state.teardown()
+
+	if !gm.TryAbort(fakeWhiteCookie()) {
+		t.Fatal("couldn't abort")
+	}
+	rematch(t, gm)
+	gm.TryResign(fakeBlackCookie())
+	rematch(t, gm)
+
+	// Aborted games don't count, so there's still one game to go.
+	restored := saveAndRestore(t, gm)
+	defer restored.state.teardown()
+	restored.TryOfferDraw(restored.GetWhiteCookie())
+	if drawn, err := restored.TryOfferDraw(restored.GetBlackCookie()); !drawn ||
+		err != nil {
+		t.Fatalf("expected the third game to be drawn, got %t, %v", drawn, err)
+	}
+	view := restored.GetClientView()
+	if len(view.Match.Results) != 3 || !view.Match.Over ||
+		view.Match.Scores["white"] != 1.5 || view.Match.Scores["black"] != 0.5 {
+		t.Errorf("unexpected scoreboard %+v", view.Match)
+	}
+}
+
+// The handicap stays with the weaker player (black in the first game) for the
+// whole match.
+func TestHandicapMatch(t *testing.T) {
+	gm := newTestGame(t, RealClock, Config{
+		TimeControl: 3 * time.Minute,
+		Variant:     VariantHex,
+		Handicap: &Handicap{
+			Receiver:   agentBlack,
+			ScoreBonus: 2,
+			ExtraTime:  time.Minute,
+			FirstMove:  true,
+		},
+		Match: &MatchConfig{Games: 3},
+	})
+	receivers := []AgentColor{agentBlack, agentWhite, agentBlack}
+	for game, receiver := range receivers {
+		giver := receiver.otherAgent()
+		if gm.colorToUser[receiver].cookie.Name != "black" {
+			t.Fatalf("game %d: expected black to keep the handicap", game+1)
+		}
+		if gm.state.agents[receiver].score != 2 ||
+			gm.state.agents[receiver].time != 4*time.Minute ||
+			gm.state.agents[giver].time != 3*time.Minute ||
+			gm.state.lastSnapshot().whoseTurn != receiver {
+			t.Errorf("game %d: expected %s to get the handicap", game+1,
+				receiver)
+		}
+		gm.TryResign(fakeWhiteCookie())
+		if game < 2 {
+			rematch(t, gm)
+		}
+	}
+	defer gm.state.teardown()
+
+	view := gm.GetClientView()
+	if len(view.Match.Results) != 3 || !view.Match.Over ||
+		view.Match.Scores["black"] != 3 {
+		t.Errorf("unexpected scoreboard %+v", view.Match)
+	}
+}
+
+func TestInvalidMatch(t *testing.T) {
+	for _, m := range []MatchConfig{
+		MatchConfig{Games: 1},
+		MatchConfig{Games: 26},
+		MatchConfig{Games: 4, BestOf: true},
+	} {
+		config := Config{TimeControl: time.Minute, Match: &m}
+		if err := config.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", m)
+		}
+	}
+	gm := newTestGame(t, RealClock, Config{TimeControl: time.Minute})
+	defer gm.state.teardown()
+	if _, err := gm.GetMatchRecord(); err == nil {
+		t.Error("expected no match record for a one-off game")
+	}
+}
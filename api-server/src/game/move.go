@@ -13,10 +13,21 @@ const (
 	DirDown
 	DirRight
 	DirLeft
+	// Only used on hex boards (along with left & right). Hex boards use axial
+	// coordinates, so "up-left" is the same step as "up" on a square board.
+	DirUpLeft
+	DirUpRight
+	DirDownLeft
+	DirDownRight
 )
 
+var allDirections = []Direction{
+	DirUp, DirDown, DirRight, DirLeft, DirUpLeft, DirUpRight, DirDownLeft,
+	DirDownRight,
+}
+
 func DirectionFromString(s string) (Direction, error) {
-	for _, d := range []Direction{DirUp, DirDown, DirRight, DirLeft} {
+	for _, d := range allDirections {
 		if s == d.String() {
 			return d, nil
 		}
@@ -25,10 +36,14 @@ func DirectionFromString(s string) (Direction, error) {
 }
 
 func (d *Direction) UnmarshalJSON(raw []byte) error {
-	if string(raw) == "" {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return err
+	}
+	if s == "" {
 		return errors.New("Direction cannot be empty.")
 	}
-	tmp, err := DirectionFromString(string(raw))
+	tmp, err := DirectionFromString(s)
 	*d = tmp
 	return err
 }
@@ -42,17 +57,25 @@ func (d Direction) String() string {
 		return "RIGHT"
 	} else if d == DirLeft {
 		return "LEFT"
+	} else if d == DirUpLeft {
+		return "UP_LEFT"
+	} else if d == DirUpRight {
+		return "UP_RIGHT"
+	} else if d == DirDownLeft {
+		return "DOWN_LEFT"
+	} else if d == DirDownRight {
+		return "DOWN_RIGHT"
 	} else {
 		panic("invalid direction!")
 	}
 }
 
 func (d Direction) dx() int {
-	if d == DirUp || d == DirDown {
+	if d == DirUp || d == DirDown || d == DirUpLeft || d == DirDownRight {
 		return 0
-	} else if d == DirRight {
+	} else if d == DirRight || d == DirUpRight {
 		return 1
-	} else if d == DirLeft {
+	} else if d == DirLeft || d == DirDownLeft {
 		return -1
 	} else {
 		panic("invalid direction!")
@@ -62,9 +85,9 @@ func (d Direction) dx() int {
 func (d Direction) dy() int {
 	if d == DirLeft || d == DirRight {
 		return 0
-	} else if d == DirUp {
+	} else if d == DirUp || d == DirUpLeft || d == DirUpRight {
 		return -1
-	} else if d == DirDown {
+	} else if d == DirDown || d == DirDownLeft || d == DirDownRight {
 		return 1
 	} else {
 		panic("invalid direction!")
@@ -72,7 +95,7 @@ func (d Direction) dy() int {
 }
 
 func (d Direction) isValid() bool {
-	return d > DirNil && d <= DirLeft
+	return d > DirNil && d <= DirDownRight
 }
 
 func (d Direction) reverse() Direction {
@@ -84,6 +107,14 @@ func (d Direction) reverse() Direction {
 		return DirLeft
 	} else if d == DirLeft {
 		return DirRight
+	} else if d == DirUpLeft {
+		return DirDownRight
+	} else if d == DirDownRight {
+		return DirUpLeft
+	} else if d == DirUpRight {
+		return DirDownLeft
+	} else if d == DirDownLeft {
+		return DirUpRight
 	} else {
 		panic("invalid direction!")
 	}
@@ -19,6 +19,8 @@ func TestUnmarshalMove(t *testing.T) {
 			expected: Move{X: 0, Y: 0, D: DirLeft}},
 		{raw: []byte(`{ "X": 0, "Y": 0, "D": "RIGHT" }`),
 			expected: Move{X: 0, Y: 0, D: DirRight}},
+		{raw: []byte(`{ "X": 4, "Y": 0, "D": "DOWN_RIGHT" }`),
+			expected: Move{X: 4, Y: 0, D: DirDownRight}},
 	}
 
 	for idx, tc := range testcases {
@@ -50,3 +52,15 @@ func TestUnmarshalInvalidMove(t *testing.T) {
 		}
 	}
 }
+
+func TestReverseDirection(t *testing.T) {
+	for _, d := range allDirections {
+		r := d.reverse()
+		if r.reverse() != d {
+			t.Errorf("%s: reversing twice gave %s", d, r.reverse())
+		}
+		if r.dx() != -d.dx() || r.dy() != -d.dy() {
+			t.Errorf("%s: %s does not point the opposite way", d, r)
+		}
+	}
+}
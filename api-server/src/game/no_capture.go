@@ -0,0 +1,45 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Long enough not to cut short any endgame that's still going somewhere.
+const minNoCaptureDrawMoves = 20
+
+func (c *Config) validateNoCaptureDraw() error {
+	if c.NoCaptureDrawMoves != 0 && (c.NoCaptureDrawMoves <
+		minNoCaptureDrawMoves || c.NoCaptureDrawMoves > 1000) {
+		return fmt.Errorf(
+			"no capture draw moves should be 0 or between %d and 1000",
+			minNoCaptureDrawMoves)
+	}
+	if c.NoCaptureDrawClaim && c.NoCaptureDrawMoves == 0 {
+		return errors.New("no capture draws can't be claimed without a limit")
+	}
+	return nil
+}
+
+// Whether enough moves have been played without a capture for the game to be
+// drawn (or, if the config says so, for the draw to be claimed).
+func (gs *gameState) noCaptureDrawDue() bool {
+	n := gs.config.NoCaptureDrawMoves
+	return n > 0 && gs.sinceCapture >= n
+}
+
+func (gs *gameState) claimNoCaptureDraw() error {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	if gs.status != statusOngoing {
+		return errors.New("Only ongoing games can be drawn.")
+	}
+	if !gs.noCaptureDrawDue() {
+		return fmt.Errorf(
+			"A draw can only be claimed after %d moves without a capture.",
+			gs.config.NoCaptureDrawMoves)
+	}
+	gs.drawBy(DrawNoCapture, TerminationNoCapture)
+	return nil
+}
@@ -0,0 +1,103 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+// Shuffles back and forth without ever capturing. The position would come up
+// a third time on move 9.
+func newShufflingGame(config Config) *gameState {
+	var B, W, R, x Marble = marbleBlack, marbleWhite, marbleRed, marbleNil
+	return &gameState{
+		history: makeSingleSnapshotHistory(
+			[][]Marble{{R, x, x}, {x, B, x}, {W, x, x}}, agentWhite),
+		winThreshold: 1,
+		posToCount:   make(map[string]int),
+		wallClock:    RealClock,
+		config:       config,
+	}
+}
+
+func shuffle(t *testing.T, gs *gameState, moves int) {
+	for i := 0; i < moves; i++ {
+		move := []Move{
+			Move{Y: 2, X: 0, D: DirRight},
+			Move{Y: 1, X: 1, D: DirLeft},
+			Move{Y: 2, X: 1, D: DirLeft},
+			Move{Y: 1, X: 0, D: DirRight},
+		}[(len(gs.history)-1)%4]
+		if err := gs.ExecuteMove(move); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAutomaticNoCaptureDraw(t *testing.T) {
+	gs := newShufflingGame(Config{NoCaptureDrawMoves: 4})
+	shuffle(t, gs, 3)
+	if gs.status != statusOngoing || gs.sinceCapture != 3 {
+		t.Fatalf("expected an ongoing game 3 moves since a capture, got %s, %d",
+			gs.status, gs.sinceCapture)
+	}
+	shuffle(t, gs, 1)
+	if gs.status != statusDraw || gs.drawReason != DrawNoCapture ||
+		gs.termination != TerminationNoCapture {
+		t.Errorf("expected a no capture draw, got %s (%s)", gs.status,
+			gs.drawReason)
+	}
+}
+
+func TestClaimNoCaptureDraw(t *testing.T) {
+	gs := newShufflingGame(
+		Config{NoCaptureDrawMoves: 4, NoCaptureDrawClaim: true})
+	shuffle(t, gs, 3)
+	if err := gs.claimNoCaptureDraw(); err == nil {
+		t.Error("expected the claim to be too early")
+	}
+	shuffle(t, gs, 2)
+	if gs.status != statusOngoing {
+		t.Fatal("expected the draw to wait for a claim")
+	}
+	if err := gs.claimNoCaptureDraw(); err != nil {
+		t.Fatal(err)
+	}
+	if gs.status != statusDraw || gs.drawReason != DrawNoCapture {
+		t.Errorf("expected a no capture draw, got %s (%s)", gs.status,
+			gs.drawReason)
+	}
+}
+
+func TestCaptureResetsNoCaptureCount(t *testing.T) {
+	gm := newTestGame(t, RealClock, takebackConfig(t, false))
+	defer gm.state.teardown()
+	playMoves(t, gm, []Move{
+		Move{X: 0, Y: 6, D: DirUp},
+		Move{X: 6, Y: 6, D: DirUp},
+	})
+	if view := gm.GetClientView(); view.MovesSinceCapture != 2 {
+		t.Errorf("expected 2 moves without a capture, got %d",
+			view.MovesSinceCapture)
+	}
+	playMoves(t, gm, []Move{Move{X: 5, Y: 0, D: DirRight}})
+	if gm.state.sinceCapture != 0 {
+		t.Errorf("expected the capture to reset the count, got %d",
+			gm.state.sinceCapture)
+	}
+}
+
+func TestInvalidNoCaptureDraw(t *testing.T) {
+	for _, config := range []Config{
+		Config{TimeControl: time.Minute, NoCaptureDrawMoves: 5},
+		Config{TimeControl: time.Minute, NoCaptureDrawMoves: -1},
+		Config{TimeControl: time.Minute, NoCaptureDrawClaim: true},
+	} {
+		if err := config.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", config)
+		}
+	}
+	config := Config{TimeControl: time.Minute, NoCaptureDrawMoves: 50}
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+}
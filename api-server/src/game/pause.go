@@ -0,0 +1,247 @@
+package game
+
+import (
+	"errors"
+	"time"
+)
+
+// How long a game may stay paused, unless the config says otherwise.
+const defaultMaxAdjournment = 24 * time.Hour
+
+// Games are paused (adjourned) and resumed by mutual agreement: one team
+// offers and the other accepts. While paused, no timers run.
+type pauseState struct {
+	// The color of whoever has an open offer: to pause while the game is
+	// ongoing, or to resume while it's paused. agentNil if nobody does.
+	offeredBy AgentColor
+	// Only used by offers to pause: when to resume, if the game should resume
+	// on its own rather than by agreement.
+	proposedResumeAt *time.Time
+	// The rest is only set while paused.
+	resumeAt        *time.Time
+	adjournDeadline *time.Time
+	// Time left for the first move, if the game was paused before everyone
+	// had made theirs.
+	firstMoveLeft *time.Duration
+	resumeTimer   Timer
+	adjournTimer  Timer
+}
+
+func (c Config) maxAdjournment() time.Duration {
+	if c.MaxAdjournment == 0 {
+		return defaultMaxAdjournment
+	}
+	return c.MaxAdjournment
+}
+
+func (c *Config) validateMaxAdjournment() error {
+	if c.MaxAdjournment < 0 || c.MaxAdjournment > 7*24*time.Hour {
+		return errors.New("max adjournment should be >= 0s and <= 7 days")
+	}
+	return nil
+}
+
+// Offers to pause the game, or accepts the other team's offer. resumeAt may
+// be nil, in which case the game only resumes by agreement. Accepting an
+// offer with a different resumeAt makes a counter offer instead.
+func (gs *gameState) offerPause(
+	agent AgentColor, resumeAt *time.Time) (bool, error) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	if gs.status != statusOngoing {
+		return false, errors.New("Only ongoing games can be paused.")
+	}
+	if resumeAt != nil &&
+		(gs.until(*resumeAt) <= 0 ||
+			gs.until(*resumeAt) > gs.config.maxAdjournment()) {
+		return false, errors.New(
+			"The game has to resume within " +
+				gs.config.maxAdjournment().String() + ".")
+	}
+
+	p := &gs.pause
+	if p.offeredBy == agentNil || !sameTime(resumeAt, p.proposedResumeAt) {
+		p.offeredBy = agent
+		p.proposedResumeAt = resumeAt
+		return false, nil
+	}
+	if p.offeredBy.team() == agent.team() {
+		return false, errors.New("Pause already offered.")
+	}
+
+	gs.pauseNow()
+	return true, nil
+}
+
+// Offers to resume the game, or accepts the other team's offer.
+func (gs *gameState) offerResume(agent AgentColor) (bool, error) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	if gs.status != statusPaused {
+		return false, errors.New("The game isn't paused.")
+	}
+	p := &gs.pause
+	if p.offeredBy == agentNil {
+		p.offeredBy = agent
+		return false, nil
+	}
+	if p.offeredBy.team() == agent.team() {
+		return false, errors.New("Resuming already offered.")
+	}
+
+	gs.resumeNow()
+	return true, nil
+}
+
+func (gs *gameState) pauseNow() {
+	p := &gs.pause
+	if gs.firstMoveTimer != nil {
+		gs.firstMoveTimer.Stop()
+		gs.firstMoveTimer = nil
+		left := max(gs.until(*gs.firstMoveDeadline), 0)
+		p.firstMoveLeft = &left
+		gs.firstMoveDeadline = nil
+	}
+	// Keeps whatever the player had left.
+	gs.agents[gs.lastSnapshot().whoseTurn].endTurn(false)
+
+	gs.status = statusPaused
+	p.offeredBy = agentNil
+	p.resumeAt = p.proposedResumeAt
+	p.proposedResumeAt = nil
+	adjournDeadline := gs.wallClock.Now().Add(gs.config.maxAdjournment())
+	gs.startPauseTimers(adjournDeadline)
+}
+
+// Also used when restoring paused games.
+func (gs *gameState) startPauseTimers(adjournDeadline time.Time) {
+	p := &gs.pause
+	p.adjournDeadline = &adjournDeadline
+	p.adjournTimer = gs.wallClock.AfterFunc(
+		gs.until(adjournDeadline), gs.adjournTimeoutCallback)
+	if p.resumeAt != nil {
+		p.resumeTimer = gs.wallClock.AfterFunc(
+			gs.until(*p.resumeAt), gs.scheduledResumeCallback)
+	}
+}
+
+func (gs *gameState) resumeNow() {
+	firstMoveLeft := gs.pause.firstMoveLeft
+	gs.stopPauseTimers()
+	gs.pause = pauseState{}
+	gs.status = statusOngoing
+
+	if gs.awaitingFirstMoves() {
+		// Picks up where the first move timer left off.
+		left := gs.config.firstMoveTimeoutFor(
+			gs.lastSnapshot().whoseTurn, gs.firstMoveTimeout)
+		if firstMoveLeft != nil {
+			left = *firstMoveLeft
+		}
+		deadline := gs.wallClock.Now().Add(left)
+		gs.firstMoveDeadline = &deadline
+		gs.firstMoveTimer =
+			gs.wallClock.AfterFunc(left, gs.firstMoveTimeoutCallback)
+		return
+	}
+	agent := gs.agents[gs.lastSnapshot().whoseTurn]
+	if !agent.startTurn(gs.playerTimeoutCallback) {
+		panic("startTurn failed!")
+	}
+}
+
+func (gs *gameState) stopPauseTimers() {
+	p := &gs.pause
+	if p.resumeTimer != nil {
+		p.resumeTimer.Stop()
+		p.resumeTimer = nil
+	}
+	if p.adjournTimer != nil {
+		p.adjournTimer.Stop()
+		p.adjournTimer = nil
+	}
+}
+
+func (gs *gameState) scheduledResumeCallback() {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	if gs.status != statusPaused {
+		return
+	}
+	gs.resumeNow()
+
+	if gs.onAsyncUpdate != nil {
+		gs.onAsyncUpdate()
+	}
+}
+
+// Games paused for too long are aborted if somebody has yet to make their
+// first move, and otherwise adjudicated: the team with more reds wins.
+func (gs *gameState) adjournTimeoutCallback() {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	if gs.status != statusPaused {
+		return
+	}
+	if gs.awaitingFirstMoves() {
+		gs.status = statusAborted
+		gs.abortReason = AbortAdjourned
+		gs.terminate(TerminationAdjourned)
+	} else {
+		gs.terminate(TerminationAdjudication)
+		scores := gs.teamScores()
+		white, black := scores[agentWhite], scores[agentBlack]
+		if white > black {
+			gs.status = statusWhiteWon
+		} else if black > white {
+			gs.status = statusBlackWon
+		} else {
+			gs.status = statusDraw
+			gs.drawReason = DrawAdjudicated
+		}
+	}
+	gs.teardown()
+	gs.pause = pauseState{}
+
+	if gs.onAsyncUpdate != nil {
+		gs.onAsyncUpdate()
+	}
+	if gs.onGameOver != nil {
+		gs.onGameOver()
+	}
+}
+
+type clientViewPause struct {
+	// The color with an open offer to pause, or while paused, to resume.
+	OfferedBy        string     `json:"offeredBy,omitempty"`
+	ProposedResumeAt *time.Time `json:"proposedResumeAt"`
+	// Only set while paused.
+	ResumeAt        *time.Time `json:"resumeAt"`
+	AdjournDeadline *time.Time `json:"adjournDeadline"`
+	FirstMoveLeftNs *int64     `json:"firstMoveLeftNs"`
+}
+
+func (p *pauseState) clientView() clientViewPause {
+	view := clientViewPause{
+		OfferedBy:        p.offeredBy.String(),
+		ProposedResumeAt: p.proposedResumeAt,
+		ResumeAt:         p.resumeAt,
+		AdjournDeadline:  p.adjournDeadline,
+	}
+	if p.firstMoveLeft != nil {
+		left := p.firstMoveLeft.Nanoseconds()
+		view.FirstMoveLeftNs = &left
+	}
+	return view
+}
+
+func sameTime(a *time.Time, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,165 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPauseAndResumeByAgreement(t *testing.T) {
+	gm := newTestGame(t, RealClock, Config{TimeControl: time.Minute})
+	playMoves(t, gm, firstMoves)
+	defer gm.state.teardown()
+	white, black := gm.GetWhiteCookie(), gm.GetBlackCookie()
+
+	if paused, err := gm.TryOfferPause(white, nil); paused || err != nil {
+		t.Fatalf("expected an open offer, got %t, %v", paused, err)
+	}
+	if _, err := gm.TryOfferPause(white, nil); err == nil {
+		t.Error("expected offering twice to fail")
+	}
+	if paused, err := gm.TryOfferPause(black, nil); !paused || err != nil {
+		t.Fatalf("expected black to accept, got %t, %v", paused, err)
+	}
+	if gm.state.status != statusPaused ||
+		gm.state.agents[agentWhite].deadline != nil {
+		t.Error("expected the game to be paused with white's clock stopped")
+	}
+	if gm.state.pause.adjournDeadline == nil ||
+		time.Until(*gm.state.pause.adjournDeadline) < 23*time.Hour {
+		t.Error("expected the game to be adjourned for at most a day")
+	}
+	err := gm.TryMove(Move{X: 1, Y: 0, D: DirDown}, white)
+	if err == nil {
+		t.Error("expected moves to fail while paused")
+	}
+
+	if resumed, err := gm.TryOfferResume(black); resumed || err != nil {
+		t.Fatalf("expected an open offer, got %t, %v", resumed, err)
+	}
+	if resumed, err := gm.TryOfferResume(white); !resumed || err != nil {
+		t.Fatalf("expected white to accept, got %t, %v", resumed, err)
+	}
+	if gm.state.status != statusOngoing ||
+		gm.state.agents[agentWhite].deadline == nil {
+		t.Error("expected white's clock to run again")
+	}
+	if gm.state.pause.adjournTimer != nil {
+		t.Error("expected the adjournment timer to be stopped")
+	}
+}
+
+func TestPauseBeforeFirstMove(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	gm, err := NewGameManager(
+		clock, Config{TimeControl: time.Minute}, fakePlayers(), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gm.state.teardown()
+
+	gm.TryOfferPause(gm.GetWhiteCookie(), nil)
+	if paused, err := gm.TryOfferPause(gm.GetBlackCookie(), nil); !paused {
+		t.Fatalf("expected the game to be paused, got %v", err)
+	}
+	if gm.state.firstMoveTimer != nil || gm.state.firstMoveDeadline != nil {
+		t.Error("expected the first move timer to be stopped")
+	}
+	clock.Advance(time.Hour)
+	gm.TryOfferResume(gm.GetWhiteCookie())
+	gm.TryOfferResume(gm.GetBlackCookie())
+	if gm.state.firstMoveDeadline == nil ||
+		gm.state.until(*gm.state.firstMoveDeadline) != time.Minute {
+		t.Error("expected the first move timer to pick up where it left off")
+	}
+}
+
+func TestScheduledResume(t *testing.T) {
+	updates := 0
+	onAsyncUpdate := func() {
+		updates++
+	}
+	clock := NewFakeClock(time.Now())
+	gs, err := newGameState(
+		clock, Config{TimeControl: time.Minute}, onAsyncUpdate, nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gs.teardown()
+
+	resumeAt := clock.Now().Add(10 * time.Millisecond)
+	gs.offerPause(agentWhite, &resumeAt)
+	// A different time is a counter offer.
+	later := resumeAt.Add(time.Minute)
+	if paused, _ := gs.offerPause(agentBlack, &later); paused {
+		t.Fatal("expected a counter offer")
+	}
+	if paused, _ := gs.offerPause(agentWhite, &later); !paused {
+		t.Fatal("expected the counter offer to be accepted")
+	}
+	if gs.pause.resumeAt == nil || !gs.pause.resumeAt.Equal(later) {
+		t.Errorf("expected to resume at %s, got %v", later, gs.pause.resumeAt)
+	}
+
+	clock.Advance(time.Minute)
+	if gs.status != statusPaused {
+		t.Error("expected the game to stay paused until the agreed time")
+	}
+	clock.Advance(10 * time.Millisecond)
+	if updates != 1 || gs.status != statusOngoing ||
+		gs.firstMoveDeadline == nil {
+		t.Error("expected the game to resume on its own")
+	}
+}
+
+func TestAdjournmentExpires(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	config := Config{TimeControl: time.Minute, MaxAdjournment: time.Hour}
+	gs, err := newGameState(clock, config, nil, nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs.ExecuteMove(Move{X: 0, Y: 0, D: DirDown})
+	gs.ExecuteMove(Move{X: 6, Y: 0, D: DirDown})
+	gs.agents[agentBlack].score = 1
+
+	gs.offerPause(agentWhite, nil)
+	gs.offerPause(agentBlack, nil)
+	clock.Advance(time.Hour)
+	if gs.status != statusBlackWon {
+		t.Errorf("expected black to win on reds, got %s", gs.status)
+	}
+
+	// Nobody has moved yet, so there's nothing to adjudicate.
+	gs, err = newGameState(clock, config, nil, nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs.offerPause(agentWhite, nil)
+	gs.offerPause(agentBlack, nil)
+	clock.Advance(time.Hour)
+	if gs.status != statusAborted || gs.abortReason != AbortAdjourned {
+		t.Errorf("expected the game to be aborted, got %s (%s)", gs.status,
+			gs.abortReason)
+	}
+}
+
+func TestSaveAndRestorePausedGame(t *testing.T) {
+	gm := newTestGame(t, RealClock, Config{DaysPerMove: 3})
+	playMoves(t, gm, firstMoves)
+	gm.TryOfferPause(gm.GetWhiteCookie(), nil)
+	gm.TryOfferPause(gm.GetBlackCookie(), nil)
+	adjournDeadline := *gm.state.pause.adjournDeadline
+
+	restored := saveAndRestore(t, gm)
+	defer restored.state.teardown()
+	if restored.state.status != statusPaused {
+		t.Fatalf("expected a paused game, got %s", restored.state.status)
+	}
+	p := restored.state.pause
+	if p.adjournTimer == nil || !p.adjournDeadline.Equal(adjournDeadline) {
+		t.Error("expected the adjournment deadline to carry on")
+	}
+	if restored.state.agents[agentWhite].deadline != nil {
+		t.Error("expected white's clock to stay stopped")
+	}
+}
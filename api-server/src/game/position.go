@@ -0,0 +1,294 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A position to start a game from instead of the variant's start position.
+//
+// In JSON a position is either an object with the fields below or a TFEN
+// string. TFEN is FEN for Traboulet: up to four space separated fields,
+//
+//	WW3BB/WW3BB/3R3/2RRR2/3R3/BB3WW/BB3WW w 0,1 3,4,LEFT
+//
+// The board is listed row by row from the top, with digits standing for runs
+// of empty spaces. It is followed by the first letter of the color to move,
+// the reds captured by white, black (and yellow & green, in team games) and
+// the move forbidden by ko ("-" for none). Scores and ko may be left out.
+type Position struct {
+	Board     BoardT
+	WhoseTurn AgentColor
+	// Reds already captured by each player.
+	Scores map[AgentColor]int
+	Ko     *Move
+}
+
+type positionJSON struct {
+	Board     BoardT         `json:"board"`
+	WhoseTurn string         `json:"whoseTurn"`
+	Scores    map[string]int `json:"scores"`
+	Ko        *Move          `json:"ko"`
+}
+
+func (p Position) MarshalJSON() ([]byte, error) {
+	scores := make(map[string]int)
+	for ac, score := range p.Scores {
+		scores[ac.String()] = score
+	}
+	return json.Marshal(positionJSON{
+		Board:     p.Board,
+		WhoseTurn: p.WhoseTurn.String(),
+		Scores:    scores,
+		Ko:        p.Ko,
+	})
+}
+
+func (p *Position) UnmarshalJSON(raw []byte) error {
+	var tfen string
+	if err := json.Unmarshal(raw, &tfen); err == nil {
+		parsed, err := ParseTFEN(tfen)
+		if err != nil {
+			return err
+		}
+		*p = *parsed
+		return nil
+	}
+
+	var tmp positionJSON
+	if err := json.Unmarshal(raw, &tmp); err != nil {
+		return err
+	}
+	whoseTurn, ok := agentColorFromString(tmp.WhoseTurn)
+	if !ok {
+		return errors.New("invalid color to move " + tmp.WhoseTurn)
+	}
+	scores := make(map[AgentColor]int)
+	for s, score := range tmp.Scores {
+		ac, ok := agentColorFromString(s)
+		if !ok {
+			return errors.New("invalid color " + s)
+		}
+		scores[ac] = score
+	}
+	*p = Position{
+		Board:     tmp.Board,
+		WhoseTurn: whoseTurn,
+		Scores:    scores,
+		Ko:        tmp.Ko,
+	}
+	return nil
+}
+
+func ParseTFEN(tfen string) (*Position, error) {
+	fields := strings.Fields(tfen)
+	if len(fields) < 2 || len(fields) > 4 {
+		return nil, errors.New("TFEN should have between 2 and 4 fields")
+	}
+
+	rows := strings.Split(fields[0], "/")
+	board := make(BoardT, len(rows))
+	for y, row := range rows {
+		empty := 0
+		addEmpty := func() {
+			for ; empty > 0; empty-- {
+				board[y] = append(board[y], marbleNil)
+			}
+		}
+		for _, r := range row {
+			if r >= '0' && r <= '9' {
+				empty = empty*10 + int(r-'0')
+				continue
+			}
+			addEmpty()
+			m, ok := marbleFromString(string(r))
+			if !ok || m == marbleNil {
+				return nil, fmt.Errorf("invalid marble %q in TFEN", r)
+			}
+			board[y] = append(board[y], m)
+		}
+		addEmpty()
+	}
+
+	p := Position{
+		Board:  board,
+		Scores: make(map[AgentColor]int),
+	}
+	for _, ac := range allAgentColors {
+		if fields[1] == tfenColor(ac) {
+			p.WhoseTurn = ac
+		}
+	}
+	if p.WhoseTurn == agentNil {
+		return nil, errors.New("invalid color to move " + fields[1])
+	}
+
+	if len(fields) > 2 {
+		scores := strings.Split(fields[2], ",")
+		if len(scores) > len(allAgentColors) {
+			return nil, errors.New("too many scores in TFEN")
+		}
+		for i, s := range scores {
+			score, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, errors.New("invalid score " + s)
+			}
+			p.Scores[allAgentColors[i]] = score
+		}
+	}
+
+	if len(fields) > 3 && fields[3] != "-" {
+		ko := strings.Split(fields[3], ",")
+		if len(ko) != 3 {
+			return nil, errors.New("ko should look like x,y,DIRECTION")
+		}
+		x, xErr := strconv.Atoi(ko[0])
+		y, yErr := strconv.Atoi(ko[1])
+		d, dErr := DirectionFromString(ko[2])
+		if xErr != nil || yErr != nil || dErr != nil {
+			return nil, errors.New("invalid ko " + fields[3])
+		}
+		p.Ko = &Move{X: x, Y: y, D: d}
+	}
+	return &p, nil
+}
+
+func tfenColor(ac AgentColor) string {
+	return strings.ToLower(ac.String()[:1])
+}
+
+func (p Position) TFEN() string {
+	rows := make([]string, len(p.Board))
+	for y, row := range p.Board {
+		var sb strings.Builder
+		empty := 0
+		for _, m := range row {
+			if m == marbleNil {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteString(strconv.Itoa(empty))
+				empty = 0
+			}
+			sb.WriteString(m.String())
+		}
+		if empty > 0 {
+			sb.WriteString(strconv.Itoa(empty))
+		}
+		rows[y] = sb.String()
+	}
+
+	// Yellow's & green's scores are only listed if they are playing.
+	seats := allAgentColors[:2]
+	if p.Board.count(marbleYellow) > 0 || p.Board.count(marbleGreen) > 0 ||
+		p.Scores[agentYellow] > 0 || p.Scores[agentGreen] > 0 {
+		seats = allAgentColors
+	}
+	scores := make([]string, len(seats))
+	for i, ac := range seats {
+		scores[i] = strconv.Itoa(p.Scores[ac])
+	}
+
+	ko := "-"
+	if p.Ko != nil {
+		ko = fmt.Sprintf("%d,%d,%s", p.Ko.X, p.Ko.Y, p.Ko.D)
+	}
+	return strings.Join([]string{
+		strings.Join(rows, "/"), tfenColor(p.WhoseTurn),
+		strings.Join(scores, ","), ko,
+	}, " ")
+}
+
+// Reds which have already been captured still count, so the threshold is the
+// same as it was at the start of the game the position came from.
+func (p *Position) winThreshold() int {
+	reds := p.Board.count(marbleRed)
+	for _, score := range p.Scores {
+		reds += score
+	}
+	return reds/2 + 1
+}
+
+// Checks that the position could come up in a game of the given variant and
+// that the game isn't already decided.
+func (p *Position) validate(v Variant, sharedTeamScore bool) error {
+	size := len(p.Board)
+	// Only used for its void spaces
+	template := v.startBoard(size, 0)
+	for y, row := range p.Board {
+		if len(row) != size {
+			return errors.New("start position board should be square")
+		}
+		for x, m := range row {
+			if m == marbleHidden {
+				return errors.New("start position can't have hidden spaces")
+			}
+			if (m == marbleVoid) != (template[y][x] == marbleVoid) {
+				return fmt.Errorf(
+					"start position's void spaces don't match the %s board", v)
+			}
+			if owner := m.agent(); owner != agentNil && !v.isSeated(owner) {
+				return fmt.Errorf("%s games have no %s marbles", v, owner)
+			}
+		}
+	}
+	if !v.isSeated(p.WhoseTurn) {
+		return fmt.Errorf("%s games have no %s player", v, p.WhoseTurn)
+	}
+	for ac, score := range p.Scores {
+		if !v.isSeated(ac) {
+			return fmt.Errorf("%s games have no %s player", v, ac)
+		}
+		if score < 0 {
+			return errors.New("start position scores should be >= 0")
+		}
+	}
+	for _, ac := range v.turnOrder() {
+		if p.Board.count(ac.marble()) == 0 {
+			return fmt.Errorf("%s has no marbles in the start position", ac)
+		}
+	}
+	if p.Board.count(marbleRed) == 0 {
+		return errors.New("start position has no reds left")
+	}
+
+	threshold := p.winThreshold()
+	teamScores := make(map[AgentColor]int)
+	for ac, score := range p.Scores {
+		teamScores[ac.team()] += score
+		if score >= threshold {
+			return errors.New("start position is already decided")
+		}
+	}
+	for _, score := range teamScores {
+		if sharedTeamScore && score >= threshold {
+			return errors.New("start position is already decided")
+		}
+	}
+
+	scratch := gameState{
+		history: []snapshot{
+			snapshot{board: p.Board, whoseTurn: p.WhoseTurn},
+		},
+		config: Config{Variant: v},
+		ko:     p.Ko,
+	}
+	if p.Ko != nil {
+		ko := *p.Ko
+		if !ko.D.isValid() || !v.allowsDirection(ko.D) ||
+			!scratch.isInBounds(ko.X, ko.Y) ||
+			p.Board[ko.Y][ko.X] != p.WhoseTurn.marble() {
+			return errors.New("start position ko is invalid")
+		}
+	}
+	if len(scratch.getValidMoves()) == 0 {
+		return errors.New(
+			"start position is already decided: " + p.WhoseTurn.String() +
+				" can't move")
+	}
+	return nil
+}
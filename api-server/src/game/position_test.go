@@ -0,0 +1,145 @@
+package game
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTFEN(t *testing.T) {
+	tfen := "WW3BB/WW1R1BB/2RRR2/1RRRRR1/2RRR2/BB1R1WW/BB3WW w 0,0 -"
+	p, err := ParseTFEN(tfen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.Board, newStartBoard(7)) {
+		t.Errorf("expected the standard start board, got %v", p.Board)
+	}
+	if p.WhoseTurn != agentWhite {
+		t.Errorf("expected white to move, got %s", p.WhoseTurn)
+	}
+	if p.TFEN() != tfen {
+		t.Errorf("expected %q, got %q", tfen, p.TFEN())
+	}
+
+	for _, invalid := range []string{
+		"",
+		"WW3BB",
+		"W4/5/2R2/5/4B x",
+		"W4/5/2R2/5/4Q w",
+		"W4/5/2R2/5/4B w 0,a",
+		"W4/5/2R2/5/4B w 0,0 3,4",
+		"W4/5/2R2/5/4B w 0,0 3,4,SIDEWAYS",
+	} {
+		if _, err := ParseTFEN(invalid); err == nil {
+			t.Errorf("expected error parsing %q", invalid)
+		}
+	}
+}
+
+func TestStartPositionJSON(t *testing.T) {
+	raw := `{"timeControlNs": 60000000000,
+		"startPosition": "W4/5/2R2/5/3B1 b 1,0 3,4,RIGHT"}`
+	var config Config
+	if err := json.Unmarshal([]byte(raw), &config); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if config.boardSize() != 5 {
+		t.Errorf("expected board size 5, got %d", config.boardSize())
+	}
+
+	// Positions are marshaled as objects, which can be read back too.
+	b, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual Config
+	if err := json.Unmarshal(b, &actual); err != nil {
+		t.Fatal(err)
+	}
+	if actual.StartPosition.TFEN() != config.StartPosition.TFEN() {
+		t.Errorf("expected %q, got %q", config.StartPosition.TFEN(),
+			actual.StartPosition.TFEN())
+	}
+}
+
+func TestInvalidStartPosition(t *testing.T) {
+	type testCase struct {
+		tfen    string
+		variant Variant
+		reason  string
+	}
+	for _, tc := range []testCase{
+		testCase{"?4/5/2R2/5/4B w", VariantStandard, "hidden space"},
+		testCase{"W4/5/2R2/5/4B1 w", VariantStandard, "not square"},
+		testCase{"W4/5/2R2/5/4Y w", VariantStandard, "yellow marble"},
+		testCase{"W4/5/2R2/5/4B y", VariantStandard, "yellow to move"},
+		testCase{"W4/5/2R2/5/5 w", VariantStandard, "black has no marbles"},
+		testCase{"W4/5/5/5/4B w", VariantStandard, "no reds"},
+		testCase{"W4/5/2R2/5/4B b -1,0", VariantStandard, "negative score"},
+		testCase{"W4/5/2R2/5/4B b 2,0", VariantStandard, "white already won"},
+		testCase{"WBW2/1W3/1R3/1W3/WBW2 b", VariantStandard, "black is trapped"},
+		testCase{"W4/5/2R2/5/4B b 0,0 0,0,RIGHT", VariantStandard, "bad ko"},
+		testCase{"W4/5/2R2/5/4B w", VariantRandom, "random variant"},
+		testCase{"W6/7/7/3R3/7/7/6B w", VariantHex, "no void spaces"},
+	} {
+		p, err := ParseTFEN(tc.tfen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config := Config{
+			TimeControl: time.Minute, Variant: tc.variant, StartPosition: p,
+		}
+		if err := config.Validate(); err == nil {
+			t.Errorf("%s: expected error", tc.reason)
+		}
+	}
+
+	p, err := ParseTFEN("W4/5/2R2/5/4B w")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := Config{TimeControl: time.Minute, BoardSize: 7, StartPosition: p}
+	if err := config.Validate(); err == nil {
+		t.Error("expected error when the board size doesn't match")
+	}
+}
+
+func TestGameFromStartPosition(t *testing.T) {
+	p, err := ParseTFEN("W4/5/2R2/5/3B1 b 1,0 3,4,RIGHT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := Config{TimeControl: time.Minute, StartPosition: p}
+	gs, err := newGameState(RealClock, config, nil, nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gs.teardown()
+
+	if gs.lastSnapshot().whoseTurn != agentBlack {
+		t.Error("expected black to move first")
+	}
+	if gs.agents[agentWhite].score != 1 {
+		t.Errorf("expected white to start with 1 red, got %d",
+			gs.agents[agentWhite].score)
+	}
+	// One red left on the board & one captured
+	if gs.winThreshold != 2 {
+		t.Errorf("expected win threshold 2, got %d", gs.winThreshold)
+	}
+	if _, err := gs.ValidateMove(Move{X: 3, Y: 4, D: DirRight}); err == nil {
+		t.Error("expected the start position's ko to apply")
+	}
+	if err := gs.ExecuteMove(Move{X: 3, Y: 4, D: DirUp}); err != nil {
+		t.Fatal(err)
+	}
+	// The game doesn't share the config's board
+	if p.Board[4][3] != marbleBlack {
+		t.Error("playing a move modified the start position")
+	}
+}
@@ -0,0 +1,134 @@
+package game
+
+import (
+	"errors"
+	"net/http"
+)
+
+// The most moves a player can have queued at once.
+const maxPremoves = 5
+
+// Moves queued by a player waiting for their turn. As soon as their turn
+// comes the first one is played, without their clock running; if it has
+// become illegal the whole queue is discarded instead, since whatever came
+// after it was planned with it in mind.
+type premoveQueue struct {
+	moves []Move
+	// Kept until the player queues another move, so they can find out what
+	// happened.
+	discarded     []Move
+	discardReason string
+}
+
+type clientViewPremoves struct {
+	Moves         []Move `json:"moves"`
+	Discarded     []Move `json:"discarded,omitempty"`
+	DiscardReason string `json:"discardReason,omitempty"`
+}
+
+// Queues m to be played as soon as it's the player's turn. If it already is
+// (say the opponent's move came in first) m is played as a regular move
+// instead. Returns whether m was played.
+func (gm *GameManager) TryPremove(m Move, c *http.Cookie) (bool, error) {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	user, ok := gm.cookieToUser[getKeyFromCookie(c)]
+	if !ok {
+		return false, errors.New("Cookie not found.")
+	}
+	return gm.state.queuePremove(user.color, m)
+}
+
+func (gm *GameManager) ClearPremoves(c *http.Cookie) error {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	user, ok := gm.cookieToUser[getKeyFromCookie(c)]
+	if !ok {
+		return errors.New("Cookie not found.")
+	}
+	gm.state.clearPremoves(user.color)
+	return nil
+}
+
+// The premoves the owner of c has queued, which only they get to see.
+func (gm *GameManager) GetPremoves(
+	c *http.Cookie) (clientViewPremoves, error) {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	user, ok := gm.cookieToUser[getKeyFromCookie(c)]
+	if !ok {
+		return clientViewPremoves{}, errors.New("Cookie not found.")
+	}
+	return gm.state.premovesView(user.color), nil
+}
+
+func (gs *gameState) queuePremove(agent AgentColor, m Move) (bool, error) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	if gs.status != statusOngoing {
+		return false, errors.New("Game is not ongoing.")
+	}
+	if gs.lastSnapshot().whoseTurn == agent {
+		return true, gs.executeMove(m)
+	}
+	// Anything else is only checked once the premove's turn comes.
+	if !m.D.isValid() || !gs.config.Variant.allowsDirection(m.D) {
+		return false, errors.New("Direction is invalid.")
+	}
+	if !gs.isInBounds(m.X, m.Y) {
+		return false, errors.New("Index out of bounds.")
+	}
+	queue := gs.premoves[agent]
+	if len(queue.moves) >= maxPremoves {
+		return false, errors.New("Too many premoves queued.")
+	}
+	if gs.premoves == nil {
+		gs.premoves = make(map[AgentColor]premoveQueue)
+	}
+	gs.premoves[agent] = premoveQueue{moves: append(queue.moves, m)}
+	return false, nil
+}
+
+func (gs *gameState) clearPremoves(agent AgentColor) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	delete(gs.premoves, agent)
+}
+
+// Plays the first premove of whoever is to move, if they have one queued.
+// Returns whether a premove was played.
+func (gs *gameState) playPremove() bool {
+	agent := gs.lastSnapshot().whoseTurn
+	queue := gs.premoves[agent]
+	if len(queue.moves) == 0 {
+		return false
+	}
+	m := queue.moves[0]
+	if _, err := gs.ValidateMove(m); err != nil {
+		gs.premoves[agent] = premoveQueue{
+			discarded:     queue.moves,
+			discardReason: err.Error(),
+		}
+		return false
+	}
+	gs.premoves[agent] = premoveQueue{moves: queue.moves[1:]}
+	gs.playValidMove(m, true)
+	return true
+}
+
+func (gs *gameState) premovesView(agent AgentColor) clientViewPremoves {
+	gs.mutex.RLock()
+	defer gs.mutex.RUnlock()
+
+	queue := gs.premoves[agent]
+	return clientViewPremoves{
+		Moves:         append([]Move{}, queue.moves...),
+		Discarded:     queue.discarded,
+		DiscardReason: queue.discardReason,
+	}
+}
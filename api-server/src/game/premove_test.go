@@ -0,0 +1,135 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPremovesCostNoTime(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	gm := newTestGame(t, clock, Config{
+		TimeControl: time.Minute,
+		Clock:       ClockFischer,
+		Increment:   2 * time.Second,
+	})
+	defer gm.state.teardown()
+	black := gm.GetBlackCookie()
+	playMoves(t, gm, firstMoves)
+
+	for _, m := range []Move{
+		Move{X: 5, Y: 0, D: DirLeft},
+		Move{X: 4, Y: 0, D: DirRight},
+	} {
+		if played, err := gm.TryPremove(m, black); played || err != nil {
+			t.Fatalf("expected %+v to be queued, got %t, %v", m, played, err)
+		}
+	}
+	clock.Advance(5 * time.Second)
+	playMoves(t, gm, []Move{Move{X: 1, Y: 0, D: DirRight}})
+	clock.Advance(5 * time.Second)
+	playMoves(t, gm, []Move{Move{X: 2, Y: 0, D: DirLeft}})
+
+	if len(gm.state.history) != 7 ||
+		gm.state.lastSnapshot().whoseTurn != agentWhite {
+		t.Fatalf("expected both premoves to be played, got %d plies",
+			len(gm.state.history)-1)
+	}
+	got := gm.state.agents[agentBlack].time
+	if got != time.Minute+4*time.Second {
+		t.Errorf("expected black to only gain increments, got %s", got)
+	}
+	if gm.state.agents[agentBlack].deadline != nil ||
+		gm.state.agents[agentWhite].deadline == nil {
+		t.Error("expected white's clock to be running")
+	}
+	view, _ := gm.GetPremoves(black)
+	if len(view.Moves) != 0 || len(view.Discarded) != 0 {
+		t.Errorf("expected an empty queue, got %+v", view)
+	}
+}
+
+func TestIllegalPremovesAreDiscarded(t *testing.T) {
+	gm := newTestGame(
+		t, NewFakeClock(time.Now()), Config{TimeControl: time.Minute})
+	defer gm.state.teardown()
+	black := gm.GetBlackCookie()
+	playMoves(t, gm, firstMoves)
+
+	// White's marble moves away before black's premove can push it.
+	gm.TryPremove(Move{X: 1, Y: 0, D: DirDown}, black)
+	gm.TryPremove(Move{X: 5, Y: 0, D: DirLeft}, black)
+	playMoves(t, gm, []Move{Move{X: 1, Y: 0, D: DirRight}})
+
+	if gm.state.lastSnapshot().whoseTurn != agentBlack ||
+		gm.state.agents[agentBlack].deadline == nil {
+		t.Fatal("expected black to be left to move with their clock running")
+	}
+	view, err := gm.GetPremoves(black)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(view.Moves) != 0 || len(view.Discarded) != 2 ||
+		view.DiscardReason == "" {
+		t.Errorf("expected the whole queue to be discarded, got %+v", view)
+	}
+
+	// Queueing again (on black's own turn, so it's just played) starts over.
+	played, err := gm.TryPremove(Move{X: 5, Y: 0, D: DirLeft}, black)
+	if !played || err != nil {
+		t.Fatalf("expected the move to be played, got %t, %v", played, err)
+	}
+	if view, _ := gm.GetPremoves(black); len(view.Discarded) != 2 {
+		t.Error("expected playing a move not to touch the queue")
+	}
+}
+
+func TestPremoveQueueLimits(t *testing.T) {
+	gm := newTestGame(
+		t, NewFakeClock(time.Now()), Config{TimeControl: time.Minute})
+	defer gm.state.teardown()
+	black := gm.GetBlackCookie()
+
+	offBoard := Move{X: 9, Y: 0, D: DirDown}
+	if _, err := gm.TryPremove(offBoard, black); err == nil {
+		t.Error("expected premoves off the board to be refused")
+	}
+	m := Move{X: 6, Y: 0, D: DirDown}
+	for i := 0; i < maxPremoves; i++ {
+		if _, err := gm.TryPremove(m, black); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := gm.TryPremove(m, black); err == nil {
+		t.Error("expected the queue to be full")
+	}
+	if err := gm.ClearPremoves(black); err != nil {
+		t.Fatal(err)
+	}
+	if view, _ := gm.GetPremoves(black); len(view.Moves) != 0 {
+		t.Errorf("expected the queue to be cleared, got %+v", view)
+	}
+}
+
+func TestTakebackClearsPremoves(t *testing.T) {
+	gm := newTestGame(t, NewFakeClock(time.Now()),
+		Config{TimeControl: time.Minute, AllowTakebacks: true})
+	defer gm.state.teardown()
+	white, black := gm.GetWhiteCookie(), gm.GetBlackCookie()
+	playMoves(t, gm, []Move{
+		Move{X: 0, Y: 0, D: DirDown},
+		Move{X: 6, Y: 0, D: DirDown},
+		Move{X: 1, Y: 0, D: DirRight},
+	})
+
+	gm.TryPremove(Move{X: 2, Y: 0, D: DirLeft}, white)
+	if err := gm.TryRequestTakeback(white); err != nil {
+		t.Fatal(err)
+	}
+	if err := gm.TryAcceptTakeback(black); err != nil {
+		t.Fatal(err)
+	}
+	if view, _ := gm.GetPremoves(white); len(view.Moves) != 0 {
+		t.Errorf("expected the takeback to clear white's premoves, got %+v",
+			view)
+	}
+}
@@ -0,0 +1,160 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Players who haven't pinged for this long count as disconnected, and their
+// opponents may claim the game.
+const disconnectGrace = time.Minute
+
+// Abandoned games can only be aborted while few moves have been played.
+const maxAbandonedAbortMoves = 10
+
+// What a player claims when their opponents have disconnected.
+type AbandonClaim int
+
+const (
+	abandonClaimNil AbandonClaim = iota
+	AbandonClaimWin
+	AbandonClaimDraw
+	// Only while few moves have been played; see maxAbandonedAbortMoves.
+	AbandonClaimAbort
+)
+
+func (a AbandonClaim) String() string {
+	if a == abandonClaimNil {
+		return ""
+	} else if a == AbandonClaimWin {
+		return "WIN"
+	} else if a == AbandonClaimDraw {
+		return "DRAW"
+	} else if a == AbandonClaimAbort {
+		return "ABORT"
+	} else {
+		panic("invalid abandon claim!")
+	}
+}
+
+func AbandonClaimFromString(s string) (AbandonClaim, error) {
+	for _, a := range []AbandonClaim{
+		AbandonClaimWin, AbandonClaimDraw, AbandonClaimAbort} {
+		if s == a.String() {
+			return a, nil
+		}
+	}
+	return abandonClaimNil, errors.New("invalid abandon claim " + s)
+}
+
+func (a AbandonClaim) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+func (a *AbandonClaim) UnmarshalJSON(raw []byte) error {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return err
+	}
+	tmp, err := AbandonClaimFromString(s)
+	*a = tmp
+	return err
+}
+
+func (u *User) connected(now time.Time) bool {
+	return now.Sub(u.lastSeen) < disconnectGrace
+}
+
+// Players' clients ping while they have the game open. Returns whether anybody
+// connected or disconnected since the last time it returned true, so that
+// everybody can be told. Disconnections are only noticed when somebody pings,
+// which is fine since only the players still around care about them.
+func (gm *GameManager) Ping(c *http.Cookie) (bool, error) {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	user, ok := gm.cookieToUser[getKeyFromCookie(c)]
+	if !ok {
+		return false, errors.New("Cookie not found.")
+	}
+	now := gm.state.wallClock.Now()
+	user.lastSeen = now
+
+	changed := false
+	for _, u := range gm.colorToUser {
+		if connected := u.connected(now); connected != u.reportedConnected {
+			u.reportedConnected = connected
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+// Ends the game as claimed once every player on the other team has been
+// disconnected for longer than the grace period. Correspondence games are
+// exempt, since nobody is expected to stay connected for those.
+func (gm *GameManager) TryClaimAbandoned(
+	c *http.Cookie, claim AbandonClaim) error {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	user, ok := gm.cookieToUser[getKeyFromCookie(c)]
+	if !ok {
+		return errors.New("Cookie not found.")
+	}
+	if gm.state.config.IsCorrespondence() {
+		return errors.New(
+			"Correspondence games can't be claimed; wait for the clock.")
+	}
+	now := gm.state.wallClock.Now()
+	for _, u := range gm.colorToUser {
+		if u.color.team() != user.color.team() && u.connected(now) {
+			return errors.New("Your opponent is still connected.")
+		}
+	}
+	return gm.state.claimAbandoned(user.color, claim)
+}
+
+func (gs *gameState) claimAbandoned(
+	agent AgentColor, claim AbandonClaim) error {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	if gs.status != statusOngoing {
+		return errors.New("Only ongoing games can be claimed.")
+	}
+	switch claim {
+	case AbandonClaimWin, AbandonClaimDraw:
+		if gs.awaitingFirstMoves() {
+			return errors.New("Wins and draws can only be claimed after every " +
+				"player has moved; abort the game instead.")
+		}
+		if claim == AbandonClaimWin {
+			gs.status = agent.team().winStatus()
+		} else {
+			gs.status = statusDraw
+			gs.drawReason = DrawAbandoned
+		}
+	case AbandonClaimAbort:
+		if len(gs.history)-1 >= maxAbandonedAbortMoves {
+			return errors.New("Too many moves have been played to abort.")
+		}
+		gs.status = statusAborted
+		gs.abortedBy = agent
+		gs.abortReason = AbortAbandoned
+	default:
+		return errors.New("Invalid claim.")
+	}
+	gs.terminate(TerminationAbandonment)
+	gs.draw.offeredBy = agentNil
+	gs.firstMoveDeadline = nil
+	gs.teardown()
+	gs.pause = pauseState{}
+
+	if gs.onGameOver != nil {
+		gs.onGameOver()
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package game
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPing(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	gm := newTestGame(t, clock, Config{TimeControl: time.Hour})
+	defer gm.state.teardown()
+	white, black := gm.GetWhiteCookie(), gm.GetBlackCookie()
+
+	if changed, err := gm.Ping(white); changed || err != nil {
+		t.Errorf("expected nothing to change, got %t, %v", changed, err)
+	}
+	clock.Advance(disconnectGrace / 2)
+	gm.Ping(white)
+	clock.Advance(disconnectGrace / 2)
+	if changed, _ := gm.Ping(white); !changed {
+		t.Error("expected black's disconnection to be noticed")
+	}
+	view := gm.GetClientView()
+	if !view.ColorToPlayer["WHITE"].Connected ||
+		view.ColorToPlayer["BLACK"].Connected {
+		t.Errorf("expected only white to be connected, got %+v",
+			view.ColorToPlayer)
+	}
+	if changed, _ := gm.Ping(white); changed {
+		t.Error("expected black's disconnection to be reported once")
+	}
+	if changed, _ := gm.Ping(black); !changed {
+		t.Error("expected black's reconnection to be noticed")
+	}
+}
+
+func TestClaimAbandonedWin(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	gm := newTestGame(t, clock, Config{TimeControl: time.Hour})
+	defer gm.state.teardown()
+	white, black := gm.GetWhiteCookie(), gm.GetBlackCookie()
+	playMoves(t, gm, firstMoves)
+
+	if err := gm.TryClaimAbandoned(white, AbandonClaimWin); err == nil {
+		t.Error("expected black to still be connected")
+	}
+	clock.Advance(disconnectGrace)
+	gm.Ping(white)
+	if err := gm.TryClaimAbandoned(black, AbandonClaimWin); err == nil {
+		t.Error("expected white to still be connected")
+	}
+	if err := gm.TryClaimAbandoned(white, AbandonClaimWin); err != nil {
+		t.Fatal(err)
+	}
+	if gm.state.status != statusWhiteWon ||
+		gm.state.termination != TerminationAbandonment {
+		t.Errorf("expected white to win by abandonment, got %s (%s)",
+			gm.state.status, gm.state.termination)
+	}
+}
+
+func TestClaimAbandonedDrawAndAbort(t *testing.T) {
+	// Black takes longer to disconnect than to run out of first move time.
+	config := Config{
+		TimeControl:       time.Hour,
+		FirstMoveTimeouts: FirstMoveTimeouts{agentBlack: 2 * disconnectGrace},
+	}
+	clock := NewFakeClock(time.Now())
+	gm := newTestGame(t, clock, config)
+	defer gm.state.teardown()
+	white := gm.GetWhiteCookie()
+	playMoves(t, gm, []Move{Move{X: 0, Y: 0, D: DirDown}})
+
+	clock.Advance(disconnectGrace)
+	err := gm.TryClaimAbandoned(white, AbandonClaimDraw)
+	if err == nil || !strings.Contains(err.Error(), "abort") {
+		t.Errorf("expected only aborts before black's first move, got %v", err)
+	}
+	if err := gm.TryClaimAbandoned(white, AbandonClaimAbort); err != nil {
+		t.Fatal(err)
+	}
+	if gm.state.status != statusAborted ||
+		gm.state.abortReason != AbortAbandoned {
+		t.Errorf("expected an abort, got %s (%s)", gm.state.status,
+			gm.state.abortReason)
+	}
+
+	clock = NewFakeClock(time.Now())
+	gm = newTestGame(t, clock, Config{TimeControl: time.Hour})
+	defer gm.state.teardown()
+	white, black := gm.GetWhiteCookie(), gm.GetBlackCookie()
+	moves := []Move{
+		Move{X: 0, Y: 0, D: DirDown},
+		Move{X: 6, Y: 0, D: DirDown},
+		Move{X: 1, Y: 0, D: DirRight},
+		Move{X: 5, Y: 0, D: DirLeft},
+		Move{X: 2, Y: 0, D: DirLeft},
+		Move{X: 4, Y: 0, D: DirRight},
+		Move{X: 1, Y: 0, D: DirDown},
+		Move{X: 5, Y: 0, D: DirDown},
+		Move{X: 1, Y: 1, D: DirDown},
+		Move{X: 5, Y: 1, D: DirDown},
+	}
+	playMoves(t, gm, moves[:maxAbandonedAbortMoves])
+	gm.Ping(black)
+	clock.Advance(disconnectGrace)
+	if err := gm.TryClaimAbandoned(white, AbandonClaimAbort); err == nil {
+		t.Errorf("expected no aborts after %d moves", len(moves))
+	}
+	if err := gm.TryClaimAbandoned(white, AbandonClaimDraw); err != nil {
+		t.Fatal(err)
+	}
+	if gm.state.status != statusDraw || gm.state.drawReason != DrawAbandoned {
+		t.Errorf("expected a draw, got %s (%s)", gm.state.status,
+			gm.state.drawReason)
+	}
+}
+
+func TestNoAbandonedClaimsInCorrespondence(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	gm := newTestGame(t, clock, Config{DaysPerMove: 1})
+	defer gm.state.teardown()
+	clock.Advance(disconnectGrace)
+	err := gm.TryClaimAbandoned(gm.GetWhiteCookie(), AbandonClaimAbort)
+	if err == nil {
+		t.Error("expected correspondence games not to be claimable")
+	}
+}
+
+func TestAbandonClaimJSON(t *testing.T) {
+	var claim AbandonClaim
+	if err := json.Unmarshal([]byte(`"DRAW"`), &claim); err != nil ||
+		claim != AbandonClaimDraw {
+		t.Errorf("expected DRAW, got %s, %v", claim, err)
+	}
+	if err := json.Unmarshal([]byte(`""`), &claim); err == nil {
+		t.Error("expected an empty claim to be invalid")
+	}
+}
@@ -0,0 +1,66 @@
+package game
+
+import (
+	"errors"
+	"time"
+)
+
+// Everything needed to archive or replay a game.
+type GameRecord struct {
+	// Includes the variant, handicap, etc.
+	Config Config `json:"config"`
+	// Player IDs by color.
+	Players map[string]string `json:"players"`
+	// TFEN of the position the game started from, handicap included.
+	Start  string `json:"start"`
+	Moves  []Move `json:"moves"`
+	Status string `json:"status"`
+	// Clock time given back for network lag, by color.
+	Lag map[string]time.Duration `json:"lagRefundNs"`
+	// How the game ended and after how many moves, once it's over.
+	Termination     string `json:"termination,omitempty"`
+	TerminationMove int    `json:"terminationMove,omitempty"`
+}
+
+// The record of the current game. Games of hidden information variants only
+// have a record once they are over, since it gives away the whole board.
+func (gm *GameManager) GetRecord() (GameRecord, error) {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	return gm.record()
+}
+
+func (gm *GameManager) record() (GameRecord, error) {
+	if gm.state.config.Variant.hidesInformation() &&
+		!gm.state.status.isOver() {
+		return GameRecord{}, errors.New(
+			"The record is only available once the game is over.")
+	}
+
+	players := make(map[string]string)
+	lag := make(map[string]time.Duration)
+	for color, user := range gm.colorToUser {
+		players[color.String()] = user.cookie.Name
+		lag[color.String()] = gm.state.agents[color].lag.total
+	}
+	moves := make([]Move, 0, len(gm.state.history)-1)
+	for _, s := range gm.state.history[1:] {
+		moves = append(moves, Move{
+			X: s.lastMove.X,
+			Y: s.lastMove.Y,
+			D: s.lastMove.D,
+		})
+	}
+	return GameRecord{
+		Config:  gm.state.config,
+		Players: players,
+		Start:   gm.state.start.TFEN(),
+		Moves:   moves,
+		Status:  gm.state.status.String(),
+		Lag:     lag,
+
+		Termination:     gm.state.termination.String(),
+		TerminationMove: gm.state.terminationMove,
+	}, nil
+}
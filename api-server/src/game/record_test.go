@@ -0,0 +1,64 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetRecord(t *testing.T) {
+	config := Config{
+		TimeControl: time.Minute,
+		Handicap:    &Handicap{Receiver: agentBlack, ScoreBonus: 1},
+	}
+	gm, err := NewGameManager(RealClock, config, fakePlayers(), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	move := Move{X: 0, Y: 0, D: DirRight}
+	if err := gm.TryMove(move, gm.GetWhiteCookie()); err != nil {
+		t.Fatal(err)
+	}
+	if !gm.TryResign(gm.GetBlackCookie()) {
+		t.Fatal("couldn't resign")
+	}
+
+	record, err := gm.GetRecord()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(record.Moves) != 1 || record.Moves[0] != move {
+		t.Errorf("expected moves [%v], got %v", move, record.Moves)
+	}
+	if record.Players["BLACK"] != fakeBlackCookie().Name {
+		t.Errorf("unexpected players %v", record.Players)
+	}
+	if record.Status != "WHITE_WON" {
+		t.Errorf("expected WHITE_WON, got %s", record.Status)
+	}
+	// The start position includes black's head start.
+	expected := "WW3BB/WW1R1BB/2RRR2/1RRRRR1/2RRR2/BB1R1WW/BB3WW w 0,1 -"
+	if record.Start != expected {
+		t.Errorf("expected start %q, got %q", expected, record.Start)
+	}
+	if record.Config.Handicap == nil {
+		t.Error("expected the record to include the handicap")
+	}
+}
+
+func TestNoRecordForOngoingDarkGame(t *testing.T) {
+	gm, err := NewGameManager(
+		RealClock, Config{TimeControl: time.Minute, Variant: VariantDark},
+		fakePlayers(), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gm.GetRecord(); err == nil {
+		t.Error("expected no record while the game is ongoing")
+	}
+	if !gm.TryResign(gm.GetWhiteCookie()) {
+		t.Fatal("couldn't resign")
+	}
+	if _, err := gm.GetRecord(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,135 @@
+package game
+
+import (
+	"errors"
+	"net/http"
+)
+
+// What a player proposes to play next.
+type RematchOffer struct {
+	// The config for the rematch, with any handicap given in terms of the
+	// rematch's colors. Nil means the current config.
+	Config *Config `json:"config,omitempty"`
+	// If set, players keep their colors rather than moving one seat along.
+	KeepColors bool `json:"keepColors"`
+}
+
+// Rematches are proposed by one player and accepted or declined by everybody
+// else, who may also counter with a proposal of their own. The rematch starts
+// once everybody has accepted the same proposal.
+type rematchNegotiation struct {
+	// The color of whoever made the open proposal, agentNil if nobody has.
+	proposedBy AgentColor
+	offer      RematchOffer
+	// Everybody who accepted the open proposal, its proposer included.
+	accepted map[AgentColor]bool
+	// Whoever declined the last proposal, until somebody proposes again.
+	declinedBy AgentColor
+}
+
+type clientViewRematch struct {
+	ProposedBy string        `json:"proposedBy,omitempty"`
+	Offer      *RematchOffer `json:"offer,omitempty"`
+	DeclinedBy string        `json:"declinedBy,omitempty"`
+}
+
+func (gm *GameManager) proposeRematch(proposer AgentColor, offer RematchOffer) {
+	gm.rematch = rematchNegotiation{
+		proposedBy: proposer,
+		offer:      offer,
+		accepted:   map[AgentColor]bool{proposer: true},
+	}
+}
+
+// Proposes a rematch with different settings, replacing (so countering) any
+// open proposal. Matches are played out with the settings they started with.
+func (gm *GameManager) TryProposeRematch(
+	c *http.Cookie, offer RematchOffer) (bool, error) {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	user, err := gm.checkRematchAnswer(c)
+	if err != nil {
+		return false, err
+	}
+	if gm.config.Match != nil && (offer.Config != nil || offer.KeepColors) {
+		return false, errors.New("Match settings can't be renegotiated.")
+	}
+	if offer.Config != nil {
+		config := *offer.Config
+		if err := config.Validate(); err != nil {
+			return false, err
+		}
+		if len(config.Variant.turnOrder()) != len(gm.colorToUser) {
+			return false, errors.New(
+				"The rematch needs as many players as this game.")
+		}
+		offer.Config = &config
+	}
+	gm.proposeRematch(user.color, offer)
+	return gm.maybeStartRematch()
+}
+
+func (gm *GameManager) TryAcceptRematch(c *http.Cookie) (bool, error) {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	user, err := gm.checkRematchAnswer(c)
+	if err != nil {
+		return false, err
+	}
+	if gm.rematch.proposedBy == agentNil {
+		return false, errors.New("No rematch proposed.")
+	}
+	if gm.rematch.accepted[user.color] {
+		return false, errors.New("Rematch already accepted.")
+	}
+	gm.rematch.accepted[user.color] = true
+	return gm.maybeStartRematch()
+}
+
+func (gm *GameManager) TryDeclineRematch(c *http.Cookie) error {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	user, err := gm.checkRematchAnswer(c)
+	if err != nil {
+		return err
+	}
+	if gm.rematch.proposedBy == agentNil {
+		return errors.New("No rematch proposed.")
+	}
+	if gm.rematch.proposedBy == user.color {
+		return errors.New("Can't decline your own proposal.")
+	}
+	gm.rematch = rematchNegotiation{declinedBy: user.color}
+	return nil
+}
+
+func (gm *GameManager) checkRematchAnswer(c *http.Cookie) (*User, error) {
+	if !gm.state.status.isOver() {
+		return nil, errors.New("Current game is not over.")
+	}
+	user, ok := gm.cookieToUser[getKeyFromCookie(c)]
+	if !ok {
+		return nil, errors.New("Cookie not found.")
+	}
+	if gm.matchOver() {
+		return nil, errors.New("The match is over.")
+	}
+	return user, nil
+}
+
+func (gm *GameManager) rematchView() *clientViewRematch {
+	r := gm.rematch
+	if r.proposedBy == agentNil && r.declinedBy == agentNil {
+		return nil
+	}
+	view := &clientViewRematch{DeclinedBy: r.declinedBy.String()}
+	if r.proposedBy != agentNil {
+		offer := r.offer
+		view.ProposedBy = r.proposedBy.String()
+		view.Offer = &offer
+	}
+	return view
+}
@@ -0,0 +1,198 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCounterRematchProposal(t *testing.T) {
+	gm := newTestGame(t, RealClock, Config{TimeControl: time.Minute})
+	gm.TryResign(fakeWhiteCookie())
+	defer gm.state.teardown()
+	white, black := fakeWhiteCookie(), fakeBlackCookie()
+
+	blitz := Config{TimeControl: 3 * time.Minute}
+	offer := RematchOffer{Config: &blitz, KeepColors: true}
+	if started, err := gm.TryProposeRematch(white, offer); started ||
+		err != nil {
+		t.Fatalf("expected an open proposal, got %t, %v", started, err)
+	}
+	view := gm.GetClientView()
+	if view.Rematch == nil || view.Rematch.ProposedBy != "WHITE" ||
+		view.Rematch.Offer.Config.TimeControl != 3*time.Minute ||
+		!view.ColorToPlayer["WHITE"].WantsRematch {
+		t.Errorf("expected white's proposal in the view, got %+v", view.Rematch)
+	}
+
+	// Black would rather play longer, with colors swapped as usual.
+	rapid := Config{TimeControl: 10 * time.Minute}
+	_, err := gm.TryProposeRematch(black, RematchOffer{Config: &rapid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gm.rematch.accepted[agentWhite] {
+		t.Error("expected the counter proposal to need white's acceptance")
+	}
+	if _, err := gm.TryAcceptRematch(black); err == nil {
+		t.Error("expected black to have accepted their own proposal already")
+	}
+	started, err := gm.TryAcceptRematch(white)
+	if err != nil || !started {
+		t.Fatalf("expected the rematch to start, got %t, %v", started, err)
+	}
+	if gm.config.TimeControl != 10*time.Minute ||
+		gm.state.config.TimeControl != 10*time.Minute {
+		t.Errorf("expected the rematch to use black's config, got %s",
+			gm.state.config.TimeControl)
+	}
+	if gm.GetWhiteCookie().Name != "black" {
+		t.Error("expected colors to be swapped")
+	}
+	if gm.GetClientView().Rematch != nil {
+		t.Error("expected the negotiation to be over")
+	}
+}
+
+func TestKeepColorsRematch(t *testing.T) {
+	gm := newTestGame(t, RealClock, Config{TimeControl: time.Minute})
+	gm.TryResign(fakeWhiteCookie())
+	defer gm.state.teardown()
+
+	offer := RematchOffer{KeepColors: true}
+	gm.TryProposeRematch(fakeBlackCookie(), offer)
+	if started, _ := gm.OfferRematch(fakeWhiteCookie()); !started {
+		t.Fatal("expected offering a rematch to accept the proposal")
+	}
+	if gm.GetWhiteCookie().Name != "white" {
+		t.Error("expected colors to be kept")
+	}
+}
+
+// Without a renegotiated config the handicap stays with the weaker player,
+// whichever color they play.
+func TestHandicapRematch(t *testing.T) {
+	for _, keepColors := range []bool{false, true} {
+		gm := newTestGame(t, RealClock, Config{
+			TimeControl: time.Minute,
+			Handicap: &Handicap{
+				Receiver:   agentBlack,
+				ScoreBonus: 1,
+				FirstMove:  true,
+			},
+		})
+		gm.TryResign(fakeWhiteCookie())
+
+		offer := RematchOffer{KeepColors: keepColors}
+		_, err := gm.TryProposeRematch(fakeWhiteCookie(), offer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		started, err := gm.TryAcceptRematch(fakeBlackCookie())
+		if !started || err != nil {
+			t.Fatalf("expected the rematch to start, got %t, %v", started, err)
+		}
+		receiver := gm.config.Handicap.Receiver
+		if gm.colorToUser[receiver].cookie.Name != "black" ||
+			gm.state.agents[receiver].score != 1 {
+			t.Errorf("keepColors %t: expected black to keep the handicap",
+				keepColors)
+		}
+		if gm.state.lastSnapshot().whoseTurn != receiver {
+			t.Errorf("keepColors %t: expected black to move first", keepColors)
+		}
+		gm.state.teardown()
+	}
+}
+
+func TestDeclineRematch(t *testing.T) {
+	gm := newTestGame(t, RealClock, Config{TimeControl: time.Minute})
+	gm.TryResign(fakeWhiteCookie())
+	defer gm.state.teardown()
+	white, black := fakeWhiteCookie(), fakeBlackCookie()
+
+	if err := gm.TryDeclineRematch(black); err == nil {
+		t.Error("expected nothing to decline")
+	}
+	gm.OfferRematch(white)
+	if err := gm.TryDeclineRematch(white); err == nil {
+		t.Error("expected white not to decline their own proposal")
+	}
+	if err := gm.TryDeclineRematch(black); err != nil {
+		t.Fatal(err)
+	}
+	view := gm.GetClientView()
+	if view.Rematch == nil || view.Rematch.DeclinedBy != "BLACK" ||
+		view.Rematch.Offer != nil || view.ColorToPlayer["WHITE"].WantsRematch {
+		t.Errorf("expected black's decline in the view, got %+v", view.Rematch)
+	}
+	if _, err := gm.TryAcceptRematch(white); err == nil {
+		t.Error("expected the declined proposal to be gone")
+	}
+}
+
+func TestInvalidRematchProposals(t *testing.T) {
+	gm := newTestGame(t, RealClock, Config{TimeControl: time.Minute})
+	gm.TryResign(fakeWhiteCookie())
+	defer gm.state.teardown()
+
+	for _, config := range []Config{
+		Config{TimeControl: 2 * time.Hour},
+		Config{TimeControl: time.Minute, Variant: VariantTeams},
+	} {
+		offer := RematchOffer{Config: &config}
+		if _, err := gm.TryProposeRematch(fakeWhiteCookie(), offer); err == nil {
+			t.Errorf("expected %+v to be refused", config)
+		}
+	}
+
+	match := newTestGame(t, RealClock, Config{
+		TimeControl: time.Minute,
+		Match:       &MatchConfig{Games: 3},
+	})
+	defer match.state.teardown()
+	match.TryResign(fakeWhiteCookie())
+	offer := RematchOffer{KeepColors: true}
+	if _, err := match.TryProposeRematch(fakeWhiteCookie(), offer); err == nil {
+		t.Error("expected match settings not to be renegotiated")
+	}
+}
+
+func TestSaveAndRestoreRematchProposal(t *testing.T) {
+	gm := newTestGame(t, RealClock, Config{TimeControl: time.Minute})
+	gm.TryResign(fakeWhiteCookie())
+	blitz := Config{TimeControl: 3 * time.Minute}
+	gm.TryProposeRematch(fakeBlackCookie(), RematchOffer{Config: &blitz})
+
+	restored := saveAndRestore(t, gm)
+	defer restored.state.teardown()
+	if restored.rematch.proposedBy != agentBlack ||
+		!restored.rematch.accepted[agentBlack] {
+		t.Fatalf("expected black's proposal to be restored, got %+v",
+			restored.rematch)
+	}
+	started, err := restored.TryAcceptRematch(fakeWhiteCookie())
+	if err != nil || !started {
+		t.Fatalf("expected the rematch to start, got %t, %v", started, err)
+	}
+	if restored.state.config.TimeControl != 3*time.Minute {
+		t.Error("expected the proposed config to be restored")
+	}
+}
+
+func TestRematchThatCantStart(t *testing.T) {
+	gm := newTestGame(t, RealClock, Config{TimeControl: time.Minute})
+	gm.TryResign(fakeWhiteCookie())
+	defer gm.state.teardown()
+	state := gm.state
+
+	// Renegotiated configs are validated when proposed, so sneak one in.
+	gm.proposeRematch(agentWhite, RematchOffer{Config: &Config{}})
+	started, err := gm.OfferRematch(fakeBlackCookie())
+	if started || err == nil {
+		t.Fatalf("expected the rematch to fail, got %t, %v", started, err)
+	}
+	if gm.state != state || gm.GetWhiteCookie().Name != "white" ||
+		gm.rematch.proposedBy != agentNil {
+		t.Error("expected the finished game to be left alone")
+	}
+}
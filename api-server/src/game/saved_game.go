@@ -0,0 +1,397 @@
+package game
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Everything needed to bring a game back after the server restarts.
+type SavedGame struct {
+	Config Config `json:"config"`
+	// The seed the current game was generated from, if the variant uses one.
+	Seed    int64                 `json:"seed"`
+	Players []SavedPlayer         `json:"players"`
+	Moves   []Move                `json:"moves"`
+	Status  string                `json:"status"`
+	Clocks  map[string]SavedClock `json:"clocks"`
+	// Only set while waiting for somebody's first move.
+	FirstMoveDeadline *time.Time `json:"firstMoveDeadline"`
+	// Only set for aborted games.
+	AbortedBy   string `json:"abortedBy,omitempty"`
+	AbortReason string `json:"abortReason,omitempty"`
+	// Only set for paused games and games with an open offer to pause.
+	Pause *SavedPause `json:"pause,omitempty"`
+	// Only set for drawn games.
+	DrawReason string `json:"drawReason,omitempty"`
+	// Only set once somebody has offered a draw.
+	DrawOffers *SavedDrawOffers `json:"drawOffers,omitempty"`
+	// Only set while a takeback is requested.
+	TakebackRequestedBy string `json:"takebackRequestedBy,omitempty"`
+	// Only set for finished games.
+	Termination     string `json:"termination,omitempty"`
+	TerminationMove int    `json:"terminationMove,omitempty"`
+	// Only set for matches: the games finished before this one.
+	MatchGames []GameRecord `json:"matchGames,omitempty"`
+	// Only set while a rematch is proposed (or was just declined). Who
+	// accepted it is saved with the players.
+	Rematch *SavedRematch `json:"rematch,omitempty"`
+}
+
+// See rematchNegotiation.
+type SavedRematch struct {
+	ProposedBy string       `json:"proposedBy"`
+	Offer      RematchOffer `json:"offer"`
+	DeclinedBy string       `json:"declinedBy"`
+}
+
+// See pauseState.
+type SavedPause struct {
+	OfferedBy        string         `json:"offeredBy"`
+	ProposedResumeAt *time.Time     `json:"proposedResumeAt"`
+	ResumeAt         *time.Time     `json:"resumeAt"`
+	AdjournDeadline  *time.Time     `json:"adjournDeadline"`
+	FirstMoveLeft    *time.Duration `json:"firstMoveLeftNs"`
+}
+
+// See drawOffers. Keyed by color.
+type SavedDrawOffers struct {
+	OfferedBy string         `json:"offeredBy"`
+	Made      map[string]int `json:"made"`
+	LastPly   map[string]int `json:"lastPly"`
+}
+
+type SavedPlayer struct {
+	Color        string `json:"color"`
+	Name         string `json:"name"`
+	Value        string `json:"value"`
+	WantsRematch bool   `json:"wantsRematch"`
+	// Correspondence games only.
+	ConditionalMoves []ConditionalMove `json:"conditionalMoves,omitempty"`
+}
+
+type SavedClock struct {
+	Time time.Duration `json:"timeNs"`
+	// Only set for the player whose clock is running.
+	Deadline   *time.Time `json:"deadline"`
+	InOvertime bool       `json:"inOvertime"`
+	Periods    int        `json:"periods"`
+	MovesLeft  int        `json:"movesLeft"`
+	Vacation   int        `json:"vacation"`
+	// Lag compensation given so far.
+	Lag time.Duration `json:"lagRefundNs"`
+}
+
+func (gm *GameManager) Save() SavedGame {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	saved := SavedGame{
+		Config:            gm.config,
+		Seed:              gm.state.config.Seed,
+		Status:            gm.state.status.String(),
+		Clocks:            make(map[string]SavedClock),
+		FirstMoveDeadline: gm.state.firstMoveDeadline,
+		AbortedBy:         gm.state.abortedBy.String(),
+		AbortReason:       gm.state.abortReason.String(),
+		DrawReason:        gm.state.drawReason.String(),
+
+		TakebackRequestedBy: gm.state.takeback.requestedBy.String(),
+		Termination:         gm.state.termination.String(),
+		TerminationMove:     gm.state.terminationMove,
+		MatchGames:          gm.matchGames,
+	}
+	if r := gm.rematch; r.proposedBy != agentNil || r.declinedBy != agentNil {
+		saved.Rematch = &SavedRematch{
+			ProposedBy: r.proposedBy.String(),
+			Offer:      r.offer,
+			DeclinedBy: r.declinedBy.String(),
+		}
+	}
+	if p := gm.state.pause; p.offeredBy != agentNil ||
+		gm.state.status == statusPaused {
+		saved.Pause = &SavedPause{
+			OfferedBy:        p.offeredBy.String(),
+			ProposedResumeAt: p.proposedResumeAt,
+			ResumeAt:         p.resumeAt,
+			AdjournDeadline:  p.adjournDeadline,
+			FirstMoveLeft:    p.firstMoveLeft,
+		}
+	}
+	if d := gm.state.draw; d.made != nil {
+		saved.DrawOffers = &SavedDrawOffers{
+			OfferedBy: d.offeredBy.String(),
+			Made:      make(map[string]int),
+			LastPly:   make(map[string]int),
+		}
+		for team, made := range d.made {
+			saved.DrawOffers.Made[team.String()] = made
+			saved.DrawOffers.LastPly[team.String()] = d.lastPly[team]
+		}
+	}
+	for color, user := range gm.colorToUser {
+		saved.Players = append(saved.Players, SavedPlayer{
+			Color:        color.String(),
+			Name:         user.cookie.Name,
+			Value:        user.cookie.Value,
+			WantsRematch: gm.rematch.accepted[color],
+
+			ConditionalMoves: gm.state.conditionals[color],
+		})
+	}
+	for _, s := range gm.state.history[1:] {
+		saved.Moves = append(saved.Moves, Move{
+			X: s.lastMove.X,
+			Y: s.lastMove.Y,
+			D: s.lastMove.D,
+		})
+	}
+	for color, a := range gm.state.agents {
+		saved.Clocks[color.String()] = SavedClock{
+			Time:       a.time,
+			Deadline:   a.deadline,
+			InOvertime: a.inOvertime,
+			Periods:    a.periods,
+			MovesLeft:  a.movesLeft,
+			Vacation:   a.vacation,
+			Lag:        a.lag.total,
+		}
+	}
+	return saved
+}
+
+// Brings back a game from Save. Clocks carry on from where they were, so any
+// time the server was down counts against the player who was to move.
+func RestoreGameManager(
+	wallClock Clock, saved SavedGame, onAsyncUpdate func(), onGameOver func(),
+	onRematch func()) (*GameManager, error) {
+	colorToCookie := make(map[AgentColor]*http.Cookie)
+	wantsRematch := make(map[AgentColor]bool)
+	conditionals := make(map[AgentColor][]ConditionalMove)
+	for _, p := range saved.Players {
+		color, ok := agentColorFromString(p.Color)
+		if !ok {
+			return nil, errors.New("invalid saved player color " + p.Color)
+		}
+		colorToCookie[color] = &http.Cookie{Name: p.Name, Value: p.Value}
+		wantsRematch[color] = p.WantsRematch
+		if len(p.ConditionalMoves) > 0 {
+			conditionals[color] = p.ConditionalMoves
+		}
+	}
+	var players []*http.Cookie
+	for _, color := range saved.Config.Variant.turnOrder() {
+		players = append(players, colorToCookie[color])
+	}
+
+	gm, err := NewGameManager(
+		wallClock, saved.Config, players, onAsyncUpdate, onGameOver, onRematch)
+	if err != nil {
+		return nil, err
+	}
+	if err := gm.restoreRematch(saved.Rematch, wantsRematch); err != nil {
+		return nil, err
+	}
+	gm.matchGames = saved.MatchGames
+	if saved.Config.Variant.usesSeed() {
+		gm.state.teardown()
+		config := saved.Config
+		config.Seed = saved.Seed
+		gm.state, err = newGameState(
+			wallClock, config, onAsyncUpdate, onGameOver,
+			config.defaultFirstMoveTimeout())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := gm.state.restore(saved); err != nil {
+		gm.state.teardown()
+		return nil, err
+	}
+	// Set once the moves are replayed, so that they don't get played again.
+	gm.state.conditionals = conditionals
+	return gm, nil
+}
+
+func (gs *gameState) restore(saved SavedGame) error {
+	for _, m := range saved.Moves {
+		if err := gs.ExecuteMove(m); err != nil {
+			return err
+		}
+	}
+
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	// Stop the clocks started while replaying the moves and set them as they
+	// were.
+	gs.teardown()
+	for s, clock := range saved.Clocks {
+		color, ok := agentColorFromString(s)
+		if !ok || gs.agents[color] == nil {
+			return errors.New("invalid saved clock color " + s)
+		}
+		a := gs.agents[color]
+		a.time = clock.Time
+		a.inOvertime = clock.InOvertime
+		a.periods = clock.Periods
+		a.movesLeft = clock.MovesLeft
+		a.vacation = clock.Vacation
+		a.lag.total = clock.Lag
+	}
+
+	status, err := statusFromString(saved.Status)
+	if err != nil {
+		return err
+	}
+	if err := gs.restoreDraw(saved); err != nil {
+		return err
+	}
+	if s := saved.TakebackRequestedBy; s != "" {
+		color, ok := agentColorFromString(s)
+		if !ok {
+			return errors.New("invalid saved takeback color " + s)
+		}
+		gs.takeback.requestedBy = color
+	}
+	// Games saved before terminations were recorded keep whatever replaying
+	// their moves came up with.
+	if saved.Termination != "" {
+		termination, err := terminationFromString(saved.Termination)
+		if err != nil {
+			return err
+		}
+		gs.termination = termination
+		gs.terminationMove = saved.TerminationMove
+	}
+	if status == statusPaused {
+		gs.status = status
+		gs.firstMoveDeadline = nil
+		return gs.restorePause(saved.Pause)
+	}
+	if status != statusOngoing {
+		gs.status = status
+		gs.firstMoveDeadline = nil
+		return gs.restoreAbort(saved)
+	}
+	if err := gs.restorePause(saved.Pause); err != nil {
+		return err
+	}
+	if saved.FirstMoveDeadline != nil {
+		gs.firstMoveDeadline = saved.FirstMoveDeadline
+		gs.firstMoveTimer = gs.wallClock.AfterFunc(
+			gs.until(*saved.FirstMoveDeadline), gs.firstMoveTimeoutCallback)
+		return nil
+	}
+	whoseTurn := gs.lastSnapshot().whoseTurn
+	if clock := saved.Clocks[whoseTurn.String()]; clock.Deadline != nil {
+		gs.agents[whoseTurn].time = gs.until(*clock.Deadline)
+	}
+	if !gs.agents[whoseTurn].startTurn(gs.playerTimeoutCallback) {
+		return errors.New("couldn't restart the clock")
+	}
+	return nil
+}
+
+func (gs *gameState) restoreAbort(saved SavedGame) error {
+	if saved.AbortedBy != "" {
+		color, ok := agentColorFromString(saved.AbortedBy)
+		if !ok {
+			return errors.New("invalid saved abort color " + saved.AbortedBy)
+		}
+		gs.abortedBy = color
+	}
+	reason, err := abortReasonFromString(saved.AbortReason)
+	if err != nil {
+		return err
+	}
+	gs.abortReason = reason
+	return nil
+}
+
+func (gs *gameState) restoreDraw(saved SavedGame) error {
+	reason, err := drawReasonFromString(saved.DrawReason)
+	if err != nil {
+		return err
+	}
+	gs.drawReason = reason
+	if saved.DrawOffers == nil {
+		return nil
+	}
+	gs.draw = drawOffers{
+		made:    make(map[AgentColor]int),
+		lastPly: make(map[AgentColor]int),
+	}
+	if s := saved.DrawOffers.OfferedBy; s != "" {
+		color, ok := agentColorFromString(s)
+		if !ok {
+			return errors.New("invalid saved draw offer color " + s)
+		}
+		gs.draw.offeredBy = color
+	}
+	for s, made := range saved.DrawOffers.Made {
+		team, ok := agentColorFromString(s)
+		if !ok {
+			return errors.New("invalid saved draw offer team " + s)
+		}
+		gs.draw.made[team] = made
+		gs.draw.lastPly[team] = saved.DrawOffers.LastPly[s]
+	}
+	return nil
+}
+
+func (gs *gameState) restorePause(saved *SavedPause) error {
+	if saved == nil {
+		if gs.status == statusPaused {
+			return errors.New("missing saved pause")
+		}
+		return nil
+	}
+	if saved.OfferedBy != "" {
+		color, ok := agentColorFromString(saved.OfferedBy)
+		if !ok {
+			return errors.New("invalid saved pause color " + saved.OfferedBy)
+		}
+		gs.pause.offeredBy = color
+	}
+	gs.pause.proposedResumeAt = saved.ProposedResumeAt
+	if gs.status != statusPaused {
+		return nil
+	}
+	if saved.AdjournDeadline == nil {
+		return errors.New("missing saved adjournment deadline")
+	}
+	gs.pause.resumeAt = saved.ResumeAt
+	gs.pause.firstMoveLeft = saved.FirstMoveLeft
+	gs.startPauseTimers(*saved.AdjournDeadline)
+	return nil
+}
+
+// Games saved before rematches could be renegotiated only have who wants one;
+// that's an offer to play again with the same settings.
+func (gm *GameManager) restoreRematch(
+	saved *SavedRematch, accepted map[AgentColor]bool) error {
+	if saved == nil {
+		for color, ok := range accepted {
+			if ok && gm.rematch.proposedBy == agentNil {
+				gm.proposeRematch(color, RematchOffer{})
+			}
+		}
+		gm.rematch.accepted = accepted
+		return nil
+	}
+	for _, s := range []string{saved.ProposedBy, saved.DeclinedBy} {
+		if _, ok := agentColorFromString(s); s != "" && !ok {
+			return errors.New("invalid saved rematch color " + s)
+		}
+	}
+	proposedBy, _ := agentColorFromString(saved.ProposedBy)
+	declinedBy, _ := agentColorFromString(saved.DeclinedBy)
+	gm.rematch = rematchNegotiation{
+		proposedBy: proposedBy,
+		offer:      saved.Offer,
+		accepted:   accepted,
+		declinedBy: declinedBy,
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package game
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestInvalidCorrespondenceConfig(t *testing.T) {
+	for _, config := range []Config{
+		Config{DaysPerMove: 15},
+		Config{DaysPerMove: 3, TimeControl: time.Minute},
+		Config{DaysPerMove: 3, VacationDays: 31},
+		Config{DaysPerMove: 3, Clock: ClockFischer, Increment: time.Second},
+		Config{TimeControl: time.Minute, VacationDays: 3},
+	} {
+		if err := config.Validate(); err == nil {
+			t.Errorf("expected error for %+v", config)
+		}
+	}
+}
+
+// Round trips the game through JSON, as when it is stored on disk.
+func saveAndRestore(t *testing.T, gm *GameManager) *GameManager {
+	b, err := json.Marshal(gm.Save())
+	if err != nil {
+		t.Fatal(err)
+	}
+	gm.state.teardown()
+	var saved SavedGame
+	if err := json.Unmarshal(b, &saved); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := RestoreGameManager(RealClock, saved, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return restored
+}
+
+func TestSaveAndRestore(t *testing.T) {
+	config := Config{DaysPerMove: 3, VacationDays: 2}
+	gm, err := NewGameManager(RealClock, config, fakePlayers(), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gm.state.firstMoveDeadline == nil ||
+		time.Until(*gm.state.firstMoveDeadline) <= 71*time.Hour {
+		t.Error("expected white to get the full 3 days for the first move")
+	}
+	err = gm.TryMove(Move{X: 0, Y: 0, D: DirRight}, gm.GetWhiteCookie())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gm.TryMove(Move{X: 6, Y: 0, D: DirLeft}, gm.GetBlackCookie())
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := *gm.state.agents[agentWhite].deadline
+
+	restored := saveAndRestore(t, gm)
+	defer restored.state.teardown()
+
+	if len(restored.state.history) != 3 {
+		t.Fatalf("expected 2 moves to be replayed, got %d",
+			len(restored.state.history)-1)
+	}
+	white := restored.state.agents[agentWhite]
+	if white.deadline == nil || white.deadline.Sub(deadline).Abs() > time.Second {
+		t.Errorf("expected white's clock to carry on from %s", deadline)
+	}
+	if white.vacation != 2 {
+		t.Errorf("expected 2 vacation days left, got %d", white.vacation)
+	}
+	if restored.GetWhiteCookie().Value != gm.GetWhiteCookie().Value {
+		t.Error("expected players to keep their seats")
+	}
+	if id, ply, ok := restored.ToMove(); !ok || ply != 2 ||
+		id != gm.GetWhiteCookie().Name {
+		t.Errorf("expected white to move, got %s at ply %d", id, ply)
+	}
+}
+
+func TestRestoreFinishedGame(t *testing.T) {
+	config := Config{DaysPerMove: 1, Variant: VariantRandom}
+	gm, err := NewGameManager(RealClock, config, fakePlayers(), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gm.TryResign(gm.GetBlackCookie()) {
+		t.Fatal("couldn't resign")
+	}
+	if _, err := gm.OfferRematch(gm.GetWhiteCookie()); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := saveAndRestore(t, gm)
+	if restored.state.status != statusWhiteWon {
+		t.Errorf("expected WHITE_WON, got %s", restored.state.status)
+	}
+	if restored.state.config.Seed != gm.state.config.Seed {
+		t.Error("expected the random start position to be kept")
+	}
+	if !restored.rematch.accepted[agentWhite] {
+		t.Error("expected the rematch offer to be kept")
+	}
+	if _, _, ok := restored.ToMove(); ok {
+		t.Error("expected nobody to move in a finished game")
+	}
+}
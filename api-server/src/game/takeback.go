@@ -0,0 +1,149 @@
+package game
+
+import (
+	"errors"
+)
+
+// Takebacks are requested by one team and accepted or declined by the other,
+// and only allowed if the config says so. Accepting takes back the requesting
+// player's last move along with every move made since. A request lapses once
+// anybody moves.
+type takebackRequests struct {
+	// The color of whoever has an open request, agentNil if nobody does.
+	requestedBy AgentColor
+	// The ply of each team's last request. Teams can't ask again before
+	// another move has been played.
+	lastPly map[AgentColor]int
+}
+
+func (gs *gameState) requestTakeback(agent AgentColor) error {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	if !gs.config.AllowTakebacks {
+		return errors.New("Takebacks aren't allowed in this game.")
+	}
+	if gs.status != statusOngoing {
+		return errors.New("Only ongoing games allow takebacks.")
+	}
+	if _, err := gs.takebackPlies(agent); err != nil {
+		return err
+	}
+	tb := &gs.takeback
+	if tb.requestedBy != agentNil {
+		return errors.New("A takeback is already requested.")
+	}
+	ply := len(gs.history) - 1
+	if last, ok := tb.lastPly[agent.team()]; ok && last == ply {
+		return errors.New(
+			"A takeback was already requested this move; wait for the next one.")
+	}
+	if tb.lastPly == nil {
+		tb.lastPly = make(map[AgentColor]int)
+	}
+	tb.requestedBy = agent
+	tb.lastPly[agent.team()] = ply
+	return nil
+}
+
+func (gs *gameState) acceptTakeback(agent AgentColor) error {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	if err := gs.checkTakebackAnswer(agent); err != nil {
+		return err
+	}
+	plies, err := gs.takebackPlies(gs.takeback.requestedBy)
+	if err != nil {
+		return err
+	}
+	gs.takeback.requestedBy = agentNil
+
+	// Clocks carry on from where they are: the time spent on the moves taken
+	// back isn't given back.
+	gs.agents[gs.lastSnapshot().whoseTurn].endTurn(false)
+	gs.rewind(plies)
+	// Premoves and conditional moves were lined up against the position taken
+	// back.
+	gs.premoves = nil
+	gs.conditionals = nil
+	if !gs.agents[gs.lastSnapshot().whoseTurn].startTurn(
+		gs.playerTimeoutCallback) {
+		panic("startTurn failed!")
+	}
+	return nil
+}
+
+func (gs *gameState) declineTakeback(agent AgentColor) error {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	if err := gs.checkTakebackAnswer(agent); err != nil {
+		return err
+	}
+	gs.takeback.requestedBy = agentNil
+	return nil
+}
+
+func (gs *gameState) checkTakebackAnswer(agent AgentColor) error {
+	if gs.status != statusOngoing || gs.takeback.requestedBy == agentNil {
+		return errors.New("No takeback requested.")
+	}
+	if gs.takeback.requestedBy.team() == agent.team() {
+		return errors.New("Can't answer your own team's takeback request.")
+	}
+	return nil
+}
+
+// How many plies have to be taken back to undo agent's last move. First moves
+// can't be taken back; the game can be aborted instead.
+func (gs *gameState) takebackPlies(agent AgentColor) (int, error) {
+	for i := len(gs.history) - 1; i > 0; i-- {
+		if gs.history[i-1].whoseTurn != agent {
+			continue
+		}
+		if i-1 < gs.config.NumPlayers() {
+			return 0, errors.New("First moves can't be taken back.")
+		}
+		return len(gs.history) - i, nil
+	}
+	return 0, errors.New("You have no move to take back.")
+}
+
+// Takes back the last plies moves by replaying the rest from the start, so
+// that scores, ko, repetition counts and valid moves all match the position
+// the game is left in.
+func (gs *gameState) rewind(plies int) {
+	var moves []Move
+	for _, s := range gs.history[1 : len(gs.history)-plies] {
+		moves = append(moves, Move{X: s.lastMove.X, Y: s.lastMove.Y,
+			D: s.lastMove.D})
+	}
+
+	gs.history = []snapshot{
+		snapshot{
+			board:     gs.start.Board.deepCopy(),
+			whoseTurn: gs.start.WhoseTurn,
+		},
+	}
+	gs.posToCount = make(map[string]int)
+	gs.sinceCapture = 0
+	gs.ko = nil
+	if gs.start.Ko != nil {
+		tmp := *gs.start.Ko
+		gs.ko = &tmp
+	}
+	for color, a := range gs.agents {
+		a.score = gs.start.Scores[color]
+	}
+	gs.validMoves = gs.getValidMoves()
+	for _, m := range moves {
+		gs.applyMove(m)
+	}
+}
+
+// Requests lapse when anybody moves, since they were about the position
+// before the move.
+func (gs *gameState) cancelTakebackRequest() {
+	gs.takeback.requestedBy = agentNil
+}
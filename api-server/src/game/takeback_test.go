@@ -0,0 +1,166 @@
+package game
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// White can capture the red in the top right corner once both players have
+// made their first move.
+func takebackConfig(t *testing.T, allow bool) Config {
+	start, err := ParseTFEN("5WR/B6/7/2RRR2/3R3/7/W5B w")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Config{
+		TimeControl:    time.Minute,
+		StartPosition:  start,
+		AllowTakebacks: allow,
+	}
+}
+
+func TestTakebacksNotAllowed(t *testing.T) {
+	gm := newTestGame(t, RealClock, takebackConfig(t, false))
+	defer gm.state.teardown()
+	playMoves(t, gm, []Move{
+		Move{X: 0, Y: 6, D: DirUp},
+		Move{X: 6, Y: 6, D: DirUp},
+		Move{X: 5, Y: 0, D: DirRight},
+	})
+	if err := gm.TryRequestTakeback(gm.GetWhiteCookie()); err == nil {
+		t.Error("expected takebacks to be forbidden by default")
+	}
+}
+
+func TestTakeback(t *testing.T) {
+	gm := newTestGame(t, RealClock, takebackConfig(t, true))
+	defer gm.state.teardown()
+	white, black := gm.GetWhiteCookie(), gm.GetBlackCookie()
+
+	playMoves(t, gm, []Move{
+		Move{X: 0, Y: 6, D: DirUp},
+		Move{X: 6, Y: 6, D: DirUp},
+	})
+	if err := gm.TryRequestTakeback(black); err == nil {
+		t.Error("expected first moves not to be taken back")
+	}
+	posToCount := fmt.Sprint(gm.state.posToCount)
+	validMoves := len(gm.state.validMoves)
+
+	// White captures, and black replies.
+	playMoves(t, gm, []Move{
+		Move{X: 5, Y: 0, D: DirRight},
+		Move{X: 0, Y: 1, D: DirDown},
+	})
+	if gm.state.agents[agentWhite].score != 1 {
+		t.Fatal("expected white to capture the red")
+	}
+
+	if err := gm.TryRequestTakeback(white); err != nil {
+		t.Fatal(err)
+	}
+	if err := gm.TryAcceptTakeback(white); err == nil {
+		t.Error("expected white not to be able to accept their own request")
+	}
+	if view := gm.GetClientView(); view.TakebackRequestedBy != "WHITE" {
+		t.Errorf("expected the request in the view, got %q",
+			view.TakebackRequestedBy)
+	}
+	blackTime := gm.state.agents[agentBlack].time
+	if err := gm.TryAcceptTakeback(black); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both white's capture and black's reply are gone.
+	if len(gm.state.history) != 3 ||
+		gm.state.lastSnapshot().whoseTurn != agentWhite {
+		t.Fatalf("expected to be back at ply 2 with white to move, got %d",
+			len(gm.state.history)-1)
+	}
+	if gm.state.agents[agentWhite].score != 0 {
+		t.Error("expected white's capture to be taken back")
+	}
+	if actual := fmt.Sprint(gm.state.posToCount); actual != posToCount {
+		t.Errorf("expected repetition counts %s, got %s", posToCount, actual)
+	}
+	if len(gm.state.validMoves) != validMoves {
+		t.Errorf("expected %d valid moves, got %d", validMoves,
+			len(gm.state.validMoves))
+	}
+	if gm.state.agents[agentWhite].deadline == nil ||
+		gm.state.agents[agentBlack].deadline != nil {
+		t.Error("expected only white's clock to be running")
+	}
+	if gm.state.agents[agentBlack].time != blackTime {
+		t.Error("expected black's clock to be left as it was")
+	}
+	if gm.state.takeback.requestedBy != agentNil {
+		t.Error("expected the request to be closed")
+	}
+
+	// The capture can be played again.
+	playMoves(t, gm, []Move{Move{X: 5, Y: 0, D: DirRight}})
+	if gm.state.agents[agentWhite].score != 1 {
+		t.Error("expected white to capture the red again")
+	}
+}
+
+func TestTakebackDeclinedAndLapsed(t *testing.T) {
+	gm := newTestGame(t, RealClock, takebackConfig(t, true))
+	defer gm.state.teardown()
+	white, black := gm.GetWhiteCookie(), gm.GetBlackCookie()
+
+	playMoves(t, gm, []Move{
+		Move{X: 0, Y: 6, D: DirUp},
+		Move{X: 6, Y: 6, D: DirUp},
+		Move{X: 5, Y: 0, D: DirRight},
+	})
+	if err := gm.TryRequestTakeback(white); err != nil {
+		t.Fatal(err)
+	}
+	if err := gm.TryDeclineTakeback(black); err != nil {
+		t.Fatal(err)
+	}
+	if err := gm.TryRequestTakeback(white); err == nil {
+		t.Error("expected white not to ask again before the next move")
+	}
+
+	playMoves(t, gm, []Move{Move{X: 0, Y: 1, D: DirDown}})
+	if err := gm.TryRequestTakeback(white); err != nil {
+		t.Fatal(err)
+	}
+	playMoves(t, gm, []Move{Move{X: 0, Y: 5, D: DirUp}})
+	if err := gm.TryAcceptTakeback(black); err == nil {
+		t.Error("expected the request to lapse when white moved")
+	}
+	if len(gm.state.history) != 6 {
+		t.Error("expected no moves to be taken back")
+	}
+}
+
+func TestSaveAndRestoreTakebackRequest(t *testing.T) {
+	gm := newTestGame(t, RealClock, takebackConfig(t, true))
+	defer gm.state.teardown()
+	playMoves(t, gm, []Move{
+		Move{X: 0, Y: 6, D: DirUp},
+		Move{X: 6, Y: 6, D: DirUp},
+		Move{X: 5, Y: 0, D: DirRight},
+	})
+	if err := gm.TryRequestTakeback(gm.GetWhiteCookie()); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := saveAndRestore(t, gm)
+	defer restored.state.teardown()
+	if restored.state.takeback.requestedBy != agentWhite {
+		t.Fatal("expected white's request to be restored")
+	}
+	if err := restored.TryAcceptTakeback(restored.GetBlackCookie()); err != nil {
+		t.Fatal(err)
+	}
+	if len(restored.state.history) != 3 ||
+		restored.state.agents[agentWhite].score != 0 {
+		t.Error("expected white's capture to be taken back")
+	}
+}
@@ -0,0 +1,89 @@
+package game
+
+import (
+	"errors"
+)
+
+// How a game ended. Unlike the status, this tells e.g. a resignation apart
+// from a timeout.
+type Termination int
+
+const (
+	terminationNil Termination = iota
+	// A team pushed enough reds off the board.
+	TerminationCaptures
+	// The player to move (and their partner, if any) had no valid moves.
+	TerminationEntrapment
+	TerminationResignation
+	// A player ran out of time.
+	TerminationTimeout
+	// The same position came up three times.
+	TerminationRepetition
+	// The players agreed to a draw.
+	TerminationAgreement
+	// The game was adjourned for too long and decided on the scores.
+	TerminationAdjudication
+	// A player didn't make their first move in time.
+	TerminationNoFirstMove
+	// A player aborted the game before everybody had moved.
+	TerminationAborted
+	// The game was adjourned for too long before everybody had moved.
+	TerminationAdjourned
+	// Too many moves were played without a capture.
+	TerminationNoCapture
+	// A player's opponents disconnected, and they claimed the game.
+	TerminationAbandonment
+)
+
+func (t Termination) String() string {
+	if t == terminationNil {
+		return ""
+	} else if t == TerminationCaptures {
+		return "CAPTURES"
+	} else if t == TerminationEntrapment {
+		return "ENTRAPMENT"
+	} else if t == TerminationResignation {
+		return "RESIGNATION"
+	} else if t == TerminationTimeout {
+		return "TIMEOUT"
+	} else if t == TerminationRepetition {
+		return "REPETITION"
+	} else if t == TerminationAgreement {
+		return "AGREEMENT"
+	} else if t == TerminationAdjudication {
+		return "ADJUDICATION"
+	} else if t == TerminationNoFirstMove {
+		return "NO_FIRST_MOVE"
+	} else if t == TerminationAborted {
+		return "ABORTED"
+	} else if t == TerminationAdjourned {
+		return "ADJOURNED"
+	} else if t == TerminationNoCapture {
+		return "NO_CAPTURE"
+	} else if t == TerminationAbandonment {
+		return "ABANDONMENT"
+	} else {
+		panic("invalid termination!")
+	}
+}
+
+func terminationFromString(s string) (Termination, error) {
+	for _, t := range []Termination{
+		terminationNil, TerminationCaptures, TerminationEntrapment,
+		TerminationResignation, TerminationTimeout, TerminationRepetition,
+		TerminationAgreement, TerminationAdjudication, TerminationNoFirstMove,
+		TerminationAborted, TerminationAdjourned, TerminationNoCapture,
+		TerminationAbandonment} {
+		if s == t.String() {
+			return t, nil
+		}
+	}
+	return terminationNil, errors.New("invalid termination " + s)
+}
+
+// Records how the game ended and how many moves had been played by then. The
+// caller sets the status.
+func (gs *gameState) terminate(t Termination) {
+	gs.termination = t
+	gs.terminationMove = len(gs.history) - 1
+}
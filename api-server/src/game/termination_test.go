@@ -0,0 +1,116 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTerminationByCaptures(t *testing.T) {
+	// White wins by pushing the only red off.
+	start, err := ParseTFEN("5WR/7/7/7/7/7/B6 w")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gm, err := NewGameManager(
+		RealClock, Config{TimeControl: time.Minute, StartPosition: start},
+		fakePlayers(), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gm.state.termination != terminationNil {
+		t.Errorf("expected no termination yet, got %s", gm.state.termination)
+	}
+	playMoves(t, gm, []Move{Move{X: 5, Y: 0, D: DirRight}})
+	if gm.state.status != statusWhiteWon ||
+		gm.state.termination != TerminationCaptures ||
+		gm.state.terminationMove != 1 {
+		t.Errorf("expected white to win by captures on move 1, got %s (%s, %d)",
+			gm.state.status, gm.state.termination, gm.state.terminationMove)
+	}
+}
+
+func TestTerminationByTimeout(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	gs, err := newGameState(
+		clock, Config{TimeControl: time.Minute}, nil, nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs.ExecuteMove(Move{X: 0, Y: 0, D: DirDown})
+	gs.ExecuteMove(Move{X: 6, Y: 0, D: DirDown})
+	gs.ExecuteMove(Move{X: 1, Y: 0, D: DirDown})
+	clock.Advance(time.Minute)
+	if gs.termination != TerminationTimeout || gs.terminationMove != 3 {
+		t.Errorf("expected a timeout after 3 moves, got %s, %d",
+			gs.termination, gs.terminationMove)
+	}
+}
+
+func TestTerminationByFirstMoveTimeout(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	gs, err := newGameState(
+		clock, Config{TimeControl: time.Minute}, nil, nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	if gs.termination != TerminationNoFirstMove || gs.terminationMove != 0 {
+		t.Errorf("expected an abort before any moves, got %s, %d",
+			gs.termination, gs.terminationMove)
+	}
+}
+
+func TestTerminationByRepetition(t *testing.T) {
+	var B, W, R, x Marble = marbleBlack, marbleWhite, marbleRed, marbleNil
+	gs := gameState{
+		history: makeSingleSnapshotHistory(
+			[][]Marble{{R, x, x}, {x, B, x}, {W, x, x}}, agentWhite),
+		winThreshold: 1,
+		posToCount:   make(map[string]int),
+		wallClock:    RealClock,
+	}
+	for i := 0; i < 9; i++ {
+		move := []Move{
+			Move{Y: 2, X: 0, D: DirRight},
+			Move{Y: 1, X: 1, D: DirLeft},
+			Move{Y: 2, X: 1, D: DirLeft},
+			Move{Y: 1, X: 0, D: DirRight},
+		}[i%4]
+		if err := gs.ExecuteMove(move); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if gs.termination != TerminationRepetition || gs.terminationMove != 9 {
+		t.Errorf("expected a repetition on move 9, got %s, %d",
+			gs.termination, gs.terminationMove)
+	}
+}
+
+func TestTerminationInViewRecordAndSave(t *testing.T) {
+	gm := newTestGame(t, RealClock, Config{TimeControl: time.Minute})
+	playMoves(t, gm, firstMoves)
+	if !gm.TryResign(gm.GetBlackCookie()) {
+		t.Fatal("couldn't resign")
+	}
+
+	view := gm.GetClientView()
+	if view.Termination != "RESIGNATION" || view.TerminationMove != 2 {
+		t.Errorf("expected a resignation after 2 moves in the view, got %s, %d",
+			view.Termination, view.TerminationMove)
+	}
+	record, err := gm.GetRecord()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Termination != "RESIGNATION" || record.TerminationMove != 2 {
+		t.Errorf("expected the resignation in the record, got %s, %d",
+			record.Termination, record.TerminationMove)
+	}
+
+	restored := saveAndRestore(t, gm)
+	if restored.state.termination != TerminationResignation ||
+		restored.state.terminationMove != 2 {
+		t.Errorf("expected the resignation to be restored, got %s, %d",
+			restored.state.termination, restored.state.terminationMove)
+	}
+}
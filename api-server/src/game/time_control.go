@@ -0,0 +1,160 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// How a player's clock is topped up as they move. Every clock starts with the
+// config's TimeControl; the config's Increment is the increment or delay.
+type ClockType int
+
+const (
+	// No time is ever added.
+	ClockSuddenDeath ClockType = iota
+	// The increment is added after every move.
+	ClockFischer
+	// After every move, the time the move took is given back, up to the delay.
+	ClockBronstein
+	// The clock only starts running once the delay has passed.
+	ClockSimpleDelay
+)
+
+func (c ClockType) String() string {
+	if c == ClockSuddenDeath {
+		return "SUDDEN_DEATH"
+	} else if c == ClockFischer {
+		return "FISCHER"
+	} else if c == ClockBronstein {
+		return "BRONSTEIN"
+	} else if c == ClockSimpleDelay {
+		return "SIMPLE_DELAY"
+	} else {
+		panic("invalid clock type!")
+	}
+}
+
+func (c ClockType) isValid() bool {
+	return c >= ClockSuddenDeath && c <= ClockSimpleDelay
+}
+
+func ClockTypeFromString(s string) (ClockType, error) {
+	for _, c := range []ClockType{
+		ClockSuddenDeath, ClockFischer, ClockBronstein, ClockSimpleDelay} {
+		if s == c.String() {
+			return c, nil
+		}
+	}
+	return ClockSuddenDeath, errors.New("invalid clock type " + s)
+}
+
+func (c ClockType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+func (c *ClockType) UnmarshalJSON(raw []byte) error {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return err
+	}
+	tmp, err := ClockTypeFromString(s)
+	*c = tmp
+	return err
+}
+
+// What happens once a player's main time runs out.
+type OvertimeType int
+
+const (
+	// The player loses.
+	OvertimeNone OvertimeType = iota
+	// The player gets a number of periods. Every move has to be made within a
+	// period; a period is only used up if it runs out.
+	OvertimeByoYomi
+	// The player has to make a number of moves within the period, after which
+	// the period starts over. The player loses if it runs out.
+	OvertimeCanadian
+)
+
+func (o OvertimeType) String() string {
+	if o == OvertimeNone {
+		return "NONE"
+	} else if o == OvertimeByoYomi {
+		return "BYO_YOMI"
+	} else if o == OvertimeCanadian {
+		return "CANADIAN"
+	} else {
+		panic("invalid overtime type!")
+	}
+}
+
+func (o OvertimeType) isValid() bool {
+	return o >= OvertimeNone && o <= OvertimeCanadian
+}
+
+func OvertimeTypeFromString(s string) (OvertimeType, error) {
+	for _, o := range []OvertimeType{
+		OvertimeNone, OvertimeByoYomi, OvertimeCanadian} {
+		if s == o.String() {
+			return o, nil
+		}
+	}
+	return OvertimeNone, errors.New("invalid overtime type " + s)
+}
+
+func (o OvertimeType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.String())
+}
+
+func (o *OvertimeType) UnmarshalJSON(raw []byte) error {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return err
+	}
+	tmp, err := OvertimeTypeFromString(s)
+	*o = tmp
+	return err
+}
+
+func validateClock(clock ClockType, increment time.Duration) error {
+	if !clock.isValid() {
+		return errors.New("invalid clock type")
+	}
+	if clock == ClockSuddenDeath && increment != 0 {
+		return errors.New("sudden death clocks have no increment")
+	}
+	if clock != ClockSuddenDeath &&
+		(increment <= 0 || increment > time.Minute) {
+		return errors.New("increment / delay should be > 0s and <= 1min")
+	}
+	return nil
+}
+
+func (c *Config) validateOvertime() error {
+	if !c.Overtime.isValid() {
+		return errors.New("invalid overtime type")
+	}
+	if c.Overtime == OvertimeNone {
+		if c.Periods != 0 || c.PeriodTime != 0 || c.PeriodMoves != 0 {
+			return errors.New("overtime settings given without overtime")
+		}
+		return nil
+	}
+	if c.Clock != ClockSuddenDeath {
+		return errors.New("overtime can only follow sudden death main time")
+	}
+	if c.PeriodTime <= 0 || c.PeriodTime > 10*time.Minute {
+		return errors.New("overtime period should be > 0s and <= 10min")
+	}
+	if c.Overtime == OvertimeByoYomi &&
+		(c.Periods < 1 || c.Periods > 10 || c.PeriodMoves != 0) {
+		return errors.New("byo-yomi should have between 1 and 10 periods")
+	}
+	if c.Overtime == OvertimeCanadian &&
+		(c.PeriodMoves < 1 || c.PeriodMoves > 50 || c.Periods != 0) {
+		return errors.New(
+			"Canadian overtime should have between 1 and 50 moves per period")
+	}
+	return nil
+}
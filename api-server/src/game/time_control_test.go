@@ -0,0 +1,129 @@
+package game
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestClockJSON(t *testing.T) {
+	raw := `{"timeControlNs": 60000000000, "clock": "BRONSTEIN",
+		"incrementNs": 2000000000}`
+	var config Config
+	if err := json.Unmarshal([]byte(raw), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Clock != ClockBronstein || config.Increment != 2*time.Second {
+		t.Errorf("unexpected clock %s +%s", config.Clock, config.Increment)
+	}
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	if err := json.Unmarshal([]byte(`"HOURGLASS"`), &config.Clock); err == nil {
+		t.Error("expected error for unknown clock type")
+	}
+}
+
+func TestInvalidClock(t *testing.T) {
+	type testCase struct {
+		clock     ClockType
+		increment time.Duration
+	}
+	for _, tc := range []testCase{
+		testCase{ClockSuddenDeath, time.Second},
+		testCase{ClockFischer, 0},
+		testCase{ClockBronstein, -time.Second},
+		testCase{ClockSimpleDelay, time.Hour},
+		testCase{ClockType(42), time.Second},
+	} {
+		config := Config{
+			TimeControl: time.Minute, Clock: tc.clock, Increment: tc.increment,
+		}
+		if err := config.Validate(); err == nil {
+			t.Errorf("expected error for clock %d +%s", tc.clock, tc.increment)
+		}
+	}
+}
+
+func TestIncrementAfterMove(t *testing.T) {
+	config := Config{
+		TimeControl: time.Minute, Clock: ClockFischer, Increment: 5 * time.Second,
+	}
+	gs, err := newGameState(RealClock, config, nil, nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gs.teardown()
+
+	// First moves are covered by the first move timers, so the clocks only
+	// start running once both players have moved.
+	for _, m := range []Move{
+		Move{X: 0, Y: 0, D: DirRight},
+		Move{X: 6, Y: 0, D: DirLeft},
+		Move{X: 0, Y: 1, D: DirRight},
+		Move{X: 6, Y: 1, D: DirLeft},
+	} {
+		if err := gs.ExecuteMove(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if black := gs.agents[agentBlack].time; black <= time.Minute {
+		t.Errorf("expected black to get the increment, has %s", black)
+	}
+}
+
+func TestInvalidOvertime(t *testing.T) {
+	for _, config := range []Config{
+		Config{Periods: 3},
+		Config{Overtime: OvertimeByoYomi, PeriodTime: time.Second},
+		Config{Overtime: OvertimeByoYomi, Periods: 3},
+		Config{
+			Overtime: OvertimeByoYomi, Periods: 3, PeriodTime: time.Second,
+			PeriodMoves: 5},
+		Config{Overtime: OvertimeCanadian, PeriodTime: time.Minute},
+		Config{
+			Overtime: OvertimeCanadian, PeriodTime: time.Minute, PeriodMoves: 5,
+			Clock: ClockFischer, Increment: time.Second},
+		Config{Overtime: OvertimeType(42)},
+	} {
+		config.TimeControl = time.Minute
+		if err := config.Validate(); err == nil {
+			t.Errorf("expected error for %+v", config)
+		}
+	}
+}
+
+func TestByoYomiGame(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	config := Config{
+		TimeControl: time.Minute,
+		Overtime:    OvertimeByoYomi,
+		Periods:     1,
+		PeriodTime:  10 * time.Second,
+	}
+	gs, err := newGameState(clock, config, nil, nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []Move{
+		Move{X: 0, Y: 0, D: DirDown},
+		Move{X: 6, Y: 0, D: DirDown},
+		Move{X: 1, Y: 0, D: DirDown},
+	} {
+		if err := gs.ExecuteMove(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Black's main time runs out...
+	clock.Advance(time.Minute)
+	if gs.status != statusOngoing || !gs.agents[agentBlack].inOvertime {
+		t.Error("expected black to go into overtime")
+	}
+	// ...and then their only period does.
+	clock.Advance(10 * time.Second)
+	if gs.status != statusWhiteWon {
+		t.Error("expected white to win once black's period ran out")
+	}
+}
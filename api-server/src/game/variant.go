@@ -0,0 +1,296 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+)
+
+type Variant int
+
+const (
+	// The classic fixed start position.
+	VariantStandard Variant = iota
+	// Symmetric start position generated from a seed (a.k.a. Traboulet960).
+	VariantRandom
+	// Four players in two teams of two; each corner belongs to a different
+	// player.
+	VariantTeams
+	// Hexagonal board where marbles can be pushed in six directions.
+	VariantHex
+	// Standard position, but players only see the parts of the board their
+	// own marbles can reach (fog of war).
+	VariantDark
+)
+
+func (v Variant) String() string {
+	if v == VariantStandard {
+		return "STANDARD"
+	} else if v == VariantRandom {
+		return "RANDOM"
+	} else if v == VariantTeams {
+		return "TEAMS"
+	} else if v == VariantHex {
+		return "HEX"
+	} else if v == VariantDark {
+		return "DARK"
+	} else {
+		panic("invalid variant!")
+	}
+}
+
+func (v Variant) isValid() bool {
+	return v >= VariantStandard && v <= VariantDark
+}
+
+func VariantFromString(s string) (Variant, error) {
+	for _, v := range []Variant{
+		VariantStandard, VariantRandom, VariantTeams, VariantHex,
+		VariantDark} {
+		if s == v.String() {
+			return v, nil
+		}
+	}
+	return VariantStandard, errors.New("invalid variant " + s)
+}
+
+func (v Variant) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.String())
+}
+
+func (v *Variant) UnmarshalJSON(raw []byte) error {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return err
+	}
+	tmp, err := VariantFromString(s)
+	*v = tmp
+	return err
+}
+
+func (v Variant) usesSeed() bool {
+	return v == VariantRandom
+}
+
+// Whether players must be sent redacted views of the game.
+func (v Variant) hidesInformation() bool {
+	return v == VariantDark
+}
+
+// Directions marbles can be pushed in.
+func (v Variant) directions() []Direction {
+	if v == VariantHex {
+		return []Direction{
+			DirRight, DirLeft, DirUpLeft, DirUpRight, DirDownLeft, DirDownRight}
+	}
+	return []Direction{DirUp, DirDown, DirLeft, DirRight}
+}
+
+func (v Variant) allowsDirection(d Direction) bool {
+	for _, allowed := range v.directions() {
+		if d == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func (v Variant) defaultBoardSize() int {
+	if v == VariantHex {
+		return defaultHexBoardSize
+	}
+	return defaultBoardSize
+}
+
+// Smallest board the variant's start position fits on.
+func (v Variant) minBoardSize() int {
+	if v == VariantHex {
+		return 7
+	}
+	return 5
+}
+
+// Seats in the order they take turns.
+func (v Variant) turnOrder() []AgentColor {
+	if v == VariantTeams {
+		// Clockwise, starting from the top left corner, so that teams alternate.
+		return []AgentColor{agentWhite, agentBlack, agentYellow, agentGreen}
+	}
+	return []AgentColor{agentWhite, agentBlack}
+}
+
+func (v Variant) isSeated(ac AgentColor) bool {
+	for _, seat := range v.turnOrder() {
+		if seat == ac {
+			return true
+		}
+	}
+	return false
+}
+
+func (v Variant) nextTurn(ac AgentColor) AgentColor {
+	order := v.turnOrder()
+	for i, seat := range order {
+		if seat == ac {
+			return order[(i+1)%len(order)]
+		}
+	}
+	panic("agent " + ac.String() + " is not seated in " + v.String())
+}
+
+// The other member of ac's team, or agentNil if ac plays alone.
+func (v Variant) partner(ac AgentColor) AgentColor {
+	if v != VariantTeams {
+		return agentNil
+	}
+	if ac == agentWhite {
+		return agentYellow
+	} else if ac == agentYellow {
+		return agentWhite
+	} else if ac == agentBlack {
+		return agentGreen
+	} else if ac == agentGreen {
+		return agentBlack
+	}
+	return agentNil
+}
+
+// Seeds are kept small so that they are easy to share.
+func newSeed() int64 {
+	return rand.Int63n(1e9) + 1
+}
+
+func (v Variant) startBoard(size int, seed int64) BoardT {
+	if v == VariantRandom {
+		return newRandomStartBoard(size, seed)
+	} else if v == VariantTeams {
+		return newTeamsStartBoard(size)
+	} else if v == VariantHex {
+		return newHexStartBoard(size)
+	}
+	return newStartBoard(size)
+}
+
+// The standard position, except the bottom corners go to white's and black's
+// partners.
+func newTeamsStartBoard(size int) BoardT {
+	board := newStartBoard(size)
+	for y := size - 2; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if board[y][x] == marbleWhite {
+				board[y][x] = marbleYellow
+			} else if board[y][x] == marbleBlack {
+				board[y][x] = marbleGreen
+			}
+		}
+	}
+	return board
+}
+
+// Generates a start position from the seed. The same seed (and size) always
+// yields the same position. Positions are symmetric so that neither player is
+// favored: mirroring the board left-to-right or top-to-bottom swaps the colors
+// of the players' marbles. Each player gets as many marbles, and the board as
+// many reds, as in the standard position of the same size.
+func newRandomStartBoard(size int, seed int64) BoardT {
+	rng := rand.New(rand.NewSource(seed))
+	for {
+		board, ok := randomSymmetricBoard(size, rng)
+		if ok && !hasImmediateWin(board) {
+			return board
+		}
+	}
+}
+
+func randomSymmetricBoard(size int, rng *rand.Rand) (BoardT, bool) {
+	standard := newStartBoard(size)
+	reds := standard.count(marbleRed)
+	// Every marble placed in the top-left quadrant is mirrored into the other
+	// three quadrants.
+	playerMarbles := standard.count(marbleWhite) / 2
+
+	board := make(BoardT, size)
+	for y := range board {
+		board[y] = make([]Marble, size)
+	}
+	last, center := size-1, size/2
+	// Fills (x, y) and its mirror images.
+	place := func(x, y int, m Marble) {
+		swapped := m
+		if m == marbleWhite {
+			swapped = marbleBlack
+		} else if m == marbleBlack {
+			swapped = marbleWhite
+		}
+		board[y][x] = m
+		board[y][last-x] = swapped
+		board[last-y][x] = swapped
+		board[last-y][last-x] = m
+	}
+
+	// Cells on the middle row / column mirror onto themselves, so they can
+	// only ever hold reds (or nothing).
+	var quadrant, middle [][2]int
+	for y := 0; y < center; y++ {
+		for x := 0; x < center; x++ {
+			quadrant = append(quadrant, [2]int{x, y})
+		}
+		middle = append(middle, [2]int{center, y}, [2]int{y, center})
+	}
+	rng.Shuffle(len(quadrant), func(i, j int) {
+		quadrant[i], quadrant[j] = quadrant[j], quadrant[i]
+	})
+
+	for _, cell := range quadrant[:playerMarbles] {
+		if rng.Intn(2) == 0 {
+			place(cell[0], cell[1], marbleWhite)
+		} else {
+			place(cell[0], cell[1], marbleBlack)
+		}
+	}
+
+	board[center][center] = marbleRed
+	remaining := reds - 1
+	candidates := append(middle, quadrant[playerMarbles:]...)
+	rng.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	for _, cell := range candidates {
+		x, y := cell[0], cell[1]
+		// Number of cells this one is mirrored onto (including itself).
+		n := 4
+		if x == center || y == center {
+			n = 2
+		}
+		if n > remaining {
+			continue
+		}
+		place(x, y, marbleRed)
+		remaining -= n
+	}
+	return board, remaining == 0
+}
+
+// Reports whether white, who moves first, is already trapped or can win with
+// their very first move.
+func hasImmediateWin(board BoardT) bool {
+	gs := gameState{
+		history:      []snapshot{snapshot{board: board, whoseTurn: agentWhite}},
+		winThreshold: winThresholdFor(board),
+	}
+	moves := gs.getValidMoves()
+	if len(moves) == 0 {
+		return true
+	}
+	for _, m := range moves {
+		next, pushedOff, ko := gs.playMove(Move{X: m.X, Y: m.Y, D: m.D})
+		if pushedOff == marbleRed && gs.winThreshold <= 1 {
+			return true
+		}
+		reply := gameState{history: []snapshot{next}, ko: ko}
+		if len(reply.getValidMoves()) == 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestVariantJSON(t *testing.T) {
+	for _, v := range []Variant{VariantStandard, VariantRandom} {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual Variant
+		if err := json.Unmarshal(b, &actual); err != nil {
+			t.Error(err)
+		}
+		if actual != v {
+			t.Errorf("expected %s, got %s", v, actual)
+		}
+	}
+
+	var v Variant
+	if err := json.Unmarshal([]byte(`"BLAH"`), &v); err == nil {
+		t.Error("expected error for unknown variant")
+	}
+}
+
+func TestRandomStartSameSeed(t *testing.T) {
+	for _, size := range []int{5, 7, 9, 11} {
+		a := newRandomStartBoard(size, 960)
+		b := newRandomStartBoard(size, 960)
+		if fmt.Sprint(a) != fmt.Sprint(b) {
+			t.Errorf("size %d: same seed gave different positions", size)
+		}
+	}
+
+	// Not guaranteed in general, but true for these seeds.
+	if fmt.Sprint(newRandomStartBoard(7, 1)) ==
+		fmt.Sprint(newRandomStartBoard(7, 2)) {
+		t.Error("expected different seeds to give different positions")
+	}
+}
+
+func TestRandomStartIsFair(t *testing.T) {
+	swap := map[Marble]Marble{
+		marbleNil:   marbleNil,
+		marbleRed:   marbleRed,
+		marbleWhite: marbleBlack,
+		marbleBlack: marbleWhite,
+	}
+	for _, size := range []int{5, 7, 9, 11} {
+		standard := newStartBoard(size)
+		for seed := int64(1); seed <= 50; seed++ {
+			board := newRandomStartBoard(size, seed)
+			last := size - 1
+			for y := 0; y < size; y++ {
+				for x := 0; x < size; x++ {
+					if board[y][last-x] != swap[board[y][x]] ||
+						board[last-y][x] != swap[board[y][x]] {
+						t.Fatalf("size %d, seed %d: position is not symmetric:\n%v",
+							size, seed, board)
+					}
+				}
+			}
+			for _, m := range []Marble{marbleWhite, marbleBlack, marbleRed} {
+				if board.count(m) != standard.count(m) {
+					t.Errorf("size %d, seed %d: expected %d of %s, got %d",
+						size, seed, standard.count(m), m, board.count(m))
+				}
+			}
+			if hasImmediateWin(board) {
+				t.Errorf("size %d, seed %d: white can win immediately", size, seed)
+			}
+		}
+	}
+}
+
+func TestHasImmediateWin(t *testing.T) {
+	var x, R, B, W Marble = marbleNil, marbleRed, marbleBlack, marbleWhite
+
+	// White pushes black's only marble off the board
+	if !hasImmediateWin(BoardT{{W, B}, {x, R}}) {
+		t.Error("expected immediate win")
+	}
+	// White has no moves at all
+	if !hasImmediateWin(BoardT{{W, W}, {W, W}}) {
+		t.Error("expected white to be trapped")
+	}
+	if hasImmediateWin(newStartBoard(7)) {
+		t.Error("expected no immediate win in standard position")
+	}
+}
+
+func TestRandomVariantGeneratesSeed(t *testing.T) {
+	gs, err := newGameState(
+		RealClock, Config{TimeControl: time.Minute, Variant: VariantRandom}, nil, nil,
+		30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gs.teardown()
+	if gs.config.Seed == 0 {
+		t.Fatal("expected a seed to be generated")
+	}
+	expected := newRandomStartBoard(defaultBoardSize, gs.config.Seed)
+	if fmt.Sprint(gs.lastSnapshot().board) != fmt.Sprint(expected) {
+		t.Error("start position does not match the generated seed")
+	}
+}
+
+func TestInvalidSeed(t *testing.T) {
+	configs := []Config{
+		Config{TimeControl: time.Minute, Seed: 5},
+		Config{TimeControl: time.Minute, Variant: VariantRandom, Seed: -5},
+		Config{TimeControl: time.Minute, Variant: Variant(42)},
+	}
+	for idx, config := range configs {
+		if err := config.Validate(); err == nil {
+			t.Errorf("configs[%d]: expected error", idx)
+		}
+	}
+}
+
+func TestTeamsTurnOrder(t *testing.T) {
+	expected := []AgentColor{
+		agentBlack, agentYellow, agentGreen, agentWhite, agentBlack}
+	ac := agentWhite
+	for idx, next := range expected {
+		ac = VariantTeams.nextTurn(ac)
+		if ac != next {
+			t.Errorf("turn %d: expected %s, got %s", idx, next, ac)
+		}
+		if ac.team() == VariantTeams.nextTurn(ac).team() {
+			t.Error("expected teams to alternate")
+		}
+	}
+	if VariantStandard.nextTurn(agentBlack) != agentWhite {
+		t.Error("expected white to follow black in two player games")
+	}
+	if VariantStandard.partner(agentWhite) != agentNil ||
+		VariantTeams.partner(agentGreen) != agentBlack {
+		t.Error("partners did not match expectation")
+	}
+}
+
+func TestTeamsStartBoard(t *testing.T) {
+	board := VariantTeams.startBoard(7, 0)
+	corners := map[AgentColor][2]int{
+		agentWhite:  {0, 0},
+		agentBlack:  {6, 0},
+		agentYellow: {6, 6},
+		agentGreen:  {0, 6},
+	}
+	for ac, xy := range corners {
+		if board[xy[1]][xy[0]] != ac.marble() {
+			t.Errorf("expected %s in corner %v", ac, xy)
+		}
+		if board.count(ac.marble()) != 4 {
+			t.Errorf("expected 4 %s marbles", ac)
+		}
+	}
+}
+
+func TestHexStartBoard(t *testing.T) {
+	gs, err := newGameState(
+		RealClock, Config{TimeControl: time.Minute, Variant: VariantHex}, nil, nil,
+		30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gs.teardown()
+
+	board := gs.lastSnapshot().board
+	if len(board) != defaultHexBoardSize {
+		t.Fatalf("expected default hex board size %d, got %d",
+			defaultHexBoardSize, len(board))
+	}
+	expectedCounts := map[Marble]int{
+		marbleWhite: 9,
+		marbleBlack: 9,
+		marbleRed:   19,
+		// 81 cells in the grid, 61 on the board
+		marbleVoid: 20,
+	}
+	for m, n := range expectedCounts {
+		if board.count(m) != n {
+			t.Errorf("expected %d of %q, got %d", n, m, board.count(m))
+		}
+	}
+	if gs.winThreshold != 10 {
+		t.Errorf("expected win threshold 10, got %d", gs.winThreshold)
+	}
+	if gs.isInBounds(0, 0) || !gs.isInBounds(4, 0) || !gs.isInBounds(0, 8) {
+		t.Error("bounds did not follow the hexagon")
+	}
+
+	for _, m := range gs.validMoves {
+		if !VariantHex.allowsDirection(m.D) {
+			t.Errorf("unexpected direction %s in valid moves", m.D)
+		}
+	}
+
+	// Top corner marble pushed towards the center
+	if _, err := gs.ValidateMove(Move{X: 4, Y: 0, D: DirDownRight}); err != nil {
+		t.Error(err)
+	}
+	// Same step, but square boards only
+	if _, err := gs.ValidateMove(Move{X: 4, Y: 0, D: DirDown}); err == nil {
+		t.Error("expected DOWN to be invalid on a hex board")
+	}
+}
+
+func TestHexPushIntoVoid(t *testing.T) {
+	var W, R, x, V Marble = marbleWhite, marbleRed, marbleNil, marbleVoid
+
+	gs := gameState{
+		history: makeSingleSnapshotHistory(
+			[][]Marble{{W, R, V}, {x, x, x}, {V, x, x}}, agentWhite),
+		agents:       map[AgentColor]*agent{agentWhite: {}, agentBlack: {}},
+		winThreshold: 7,
+		posToCount:   make(map[string]int),
+		config:       Config{Variant: VariantHex},
+		wallClock:    RealClock,
+	}
+	if err := gs.ExecuteMove(Move{X: 0, Y: 0, D: DirRight}); err != nil {
+		t.Fatal(err)
+	}
+	if gs.agents[agentWhite].score != 1 {
+		t.Error("expected red pushed into the void to be captured")
+	}
+	if gs.lastSnapshot().board[0][2] != marbleVoid {
+		t.Error("expected void to stay void")
+	}
+}
+
+func TestVoidJSON(t *testing.T) {
+	board := BoardT{{marbleVoid, marbleNil}, {marbleRed, marbleVoid}}
+	b, err := json.Marshal(board)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual BoardT
+	if err := json.Unmarshal(b, &actual); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(actual) != fmt.Sprint(board) {
+		t.Errorf("expected %v, got %v", board, actual)
+	}
+}
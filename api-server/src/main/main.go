@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"net/http"
+	"net/url"
 	"server"
   "evtpub"
   "flag"
@@ -13,10 +14,22 @@ func main() {
   // the IP of the nginx container & the admin server's port.
   proxyHostname := flag.String(
     "P", "http://localhost:25566", "destination for push stream events")
+  // Correspondence games are only kept in memory unless this is set.
+  dataDir := flag.String(
+    "d", "", "directory to keep correspondence games in across restarts")
   flag.Parse()
 
   evpub := evtpub.NewExtEventPublisher(*proxyHostname)
 	router := server.NewRootRouter(evpub)
+	if *dataDir != "" {
+		if err := router.PersistCorrespondenceGames(*dataDir); err != nil {
+			log.Fatal(err)
+		}
+	}
+	// Swap this out for email / push notifications.
+	router.SetYourMoveHook(func(gamePath *url.URL, playerID string) {
+		log.Print("It's " + playerID + "'s move in " + gamePath.String() + ".")
+	})
 	log.Print("starting server...")
 	log.Fatal(http.ListenAndServe(":25565", router))
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"game"
 	"github.com/julienschmidt/httprouter"
+	"log"
 	"net/http"
 	"sync"
 	"time"
@@ -16,11 +17,14 @@ import (
 // refresh or whatever-- details of what is communicated to the client is kind
 // of none of this file's business), and probably dispose of this handler.
 type challengeAcceptedCb func(
-	*challengeHandler, game.Config, *http.Cookie, *http.Cookie) (*url.URL, error)
+	*challengeHandler, game.Config, []*http.Cookie) (*url.URL, error)
 
 type challengeHandler struct {
 	router              *httprouter.Router
 	creator             *http.Cookie
+	// Players other than the creator who have accepted so far. Only games with
+	// more than two players can have joiners waiting for the game to start.
+	joined              []*http.Cookie
 	timestamp           time.Time
 	config              game.Config
 	onChallengeAccepted challengeAcceptedCb
@@ -33,6 +37,7 @@ type challengeHandler struct {
 type challengeHandlerView struct {
 	Config    game.Config `json:"config"`
 	CreatorID string      `json:"creatorID"`
+	PlayerIDs []string    `json:"playerIDs"`
 }
 
 func newChallengeHandler(
@@ -40,6 +45,7 @@ func newChallengeHandler(
   config game.Config,
 	onChallengeAccepted challengeAcceptedCb,
   channelPub *evtpub.ChannelPublisher,
+  clock game.Clock,
 )(
   *challengeHandler, error,
 ){
@@ -48,7 +54,7 @@ func newChallengeHandler(
 	}
 	ch := challengeHandler{
 		router:              httprouter.New(),
-		timestamp:           time.Now(),
+		timestamp:           clock.Now(),
 		creator:             c,
 		config:              config,
 		onChallengeAccepted: onChallengeAccepted,
@@ -118,6 +124,13 @@ func (ch *challengeHandler) postAccept(
 			w, "You cannot accept your own challenge.", http.StatusBadRequest)
 		return
 	}
+	for _, joined := range ch.joined {
+		if c.Name == joined.Name && c.Value == joined.Value {
+			http.Error(
+				w, "You have already joined this challenge.", http.StatusBadRequest)
+			return
+		}
+	}
 
 	if ch.onChallengeAccepted == nil {
 		http.Error(
@@ -126,7 +139,23 @@ func (ch *challengeHandler) postAccept(
 		return
 	}
 
-	gamePath, err := ch.onChallengeAccepted(ch, ch.config, ch.creator, c)
+	players := append([]*http.Cookie{ch.creator}, ch.joined...)
+	players = append(players, c)
+	if len(players) < ch.config.NumPlayers() {
+		ch.joined = append(ch.joined, c)
+		w.Write([]byte("Joined; waiting for more players."))
+
+		b, err := json.Marshal(ch)
+		if err == nil {
+			err = ch.channelPub.Push("challenge-updated", string(b))
+		}
+		if err != nil {
+			log.Print("Couldn't push challenge update: " + err.Error())
+		}
+		return
+	}
+
+	gamePath, err := ch.onChallengeAccepted(ch, ch.config, players)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -140,12 +169,20 @@ func (ch *challengeHandler) postAccept(
 	w.Write([]byte("Success; check header Location field for game path."))
 
   err = ch.channelPub.Push("game-created", gamePath.String())
+	if err != nil {
+		log.Print("Couldn't push created game: " + err.Error())
+	}
 }
 
 func (ch *challengeHandler) MarshalJSON() ([]byte, error) {
+	playerIDs := []string{ch.creator.Name}
+	for _, c := range ch.joined {
+		playerIDs = append(playerIDs, c.Name)
+	}
 	return json.Marshal(challengeHandlerView{
 		Config:    ch.config,
 		CreatorID: ch.creator.Name,
+		PlayerIDs: playerIDs,
 	})
 }
 
@@ -32,7 +32,7 @@ func TestNewChallengeHandler(t *testing.T) {
 	var _ http.Handler = (*challengeHandler)(nil)
 
 	cb := func(
-		*challengeHandler, game.Config, *http.Cookie, *http.Cookie) (
+		*challengeHandler, game.Config, []*http.Cookie) (
 		*url.URL, error) {
 		return nil, nil
 	}
@@ -40,7 +40,8 @@ func TestNewChallengeHandler(t *testing.T) {
   _, chpub := GetTestPublishers()
 
 	ch, err := newChallengeHandler(
-    fakeWhiteCookie(), game.Config{time.Minute}, cb, chpub)
+    fakeWhiteCookie(), game.Config{TimeControl: time.Minute}, cb, chpub,
+    game.RealClock)
 
 	if err != nil {
 		t.Error(err)
@@ -56,12 +57,12 @@ func TestPostJoinValid(t *testing.T) {
 
 	callbackCalled := false
 	cb := func(
-		ch *challengeHandler, c game.Config, w *http.Cookie, b *http.Cookie) (
+		ch *challengeHandler, c game.Config, players []*http.Cookie) (
 		*url.URL, error) {
-		if w.Value != white.Value {
+		if players[0].Value != white.Value {
 			t.Error("white cookie did not match expectation")
 		}
-		if b.Value != black.Value {
+		if players[1].Value != black.Value {
 			t.Error("black cookie did not match expectation")
 		}
 		callbackCalled = true
@@ -74,7 +75,8 @@ func TestPostJoinValid(t *testing.T) {
 
   evpub, chpub := GetTestPublishers()
 
-	ch, err := newChallengeHandler(white, game.Config{time.Minute}, cb, chpub)
+	ch, err := newChallengeHandler(
+		white, game.Config{TimeControl: time.Minute}, cb, chpub, game.RealClock)
 	if err != nil {
 		t.Error(err)
 	}
@@ -107,12 +109,73 @@ func TestPostJoinValid(t *testing.T) {
   }
 }
 
+func TestPostJoinTeamGame(t *testing.T) {
+	joiners := []*http.Cookie{
+		fakeBlackCookie(),
+		&http.Cookie{Name: "yellow", Value: "9012", Path: "/"},
+		&http.Cookie{Name: "green", Value: "3456", Path: "/"},
+	}
+
+	var seated []*http.Cookie
+	cb := func(
+		ch *challengeHandler, c game.Config, players []*http.Cookie) (
+		*url.URL, error) {
+		seated = players
+		return url.Parse("/new/game/path/")
+	}
+
+	evpub, chpub := GetTestPublishers()
+	ch, err := newChallengeHandler(
+		fakeWhiteCookie(),
+		game.Config{TimeControl: time.Minute, Variant: game.VariantTeams}, cb,
+		chpub, game.RealClock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accept := func(c *http.Cookie) int {
+		req, err := http.NewRequest("POST", "/accept", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.AddCookie(c)
+		resp := httptest.NewRecorder()
+		ch.ServeHTTP(resp, req)
+		return resp.Code
+	}
+
+	if code := accept(joiners[0]); code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, code)
+	}
+	if code := accept(joiners[0]); code != http.StatusBadRequest {
+		t.Errorf("expected joining twice to fail, got %d", code)
+	}
+	if code := accept(joiners[1]); code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, code)
+	}
+	if seated != nil {
+		t.Error("game was created before all seats were filled")
+	}
+	if code := accept(joiners[2]); code != http.StatusSeeOther {
+		t.Errorf("expected status %d, got %d", http.StatusSeeOther, code)
+	}
+	if len(seated) != 4 {
+		t.Fatalf("expected 4 players to be seated, got %d", len(seated))
+	}
+
+	pushes := evpub.Channels[testChannelPath].Pushes
+	if len(pushes) != 3 || pushes[0].Event != "challenge-updated" ||
+		pushes[2].Event != "game-created" {
+		t.Errorf("unexpected pushes %v", pushes)
+	}
+}
+
 func TestPostJoinNoCookie(t *testing.T) {
 	white := fakeWhiteCookie()
 
 	callbackCalled := false
 	cb := func(
-		*challengeHandler, game.Config, *http.Cookie, *http.Cookie) (
+		*challengeHandler, game.Config, []*http.Cookie) (
 		*url.URL, error) {
 		callbackCalled = true
 		return nil, nil
@@ -120,7 +183,8 @@ func TestPostJoinNoCookie(t *testing.T) {
 
   _, chpub := GetTestPublishers()
 
-	ch, err := newChallengeHandler(white, game.Config{time.Minute}, cb, chpub)
+	ch, err := newChallengeHandler(
+		white, game.Config{TimeControl: time.Minute}, cb, chpub, game.RealClock)
 	if err != nil {
 		t.Error(err)
 	}
@@ -153,7 +217,7 @@ func TestPostJoinSameCookie(t *testing.T) {
 
 	callbackCalled := false
 	cb := func(
-		*challengeHandler, game.Config, *http.Cookie, *http.Cookie) (
+		*challengeHandler, game.Config, []*http.Cookie) (
 		*url.URL, error) {
 		callbackCalled = true
 		return nil, nil
@@ -161,7 +225,8 @@ func TestPostJoinSameCookie(t *testing.T) {
 
   _, chpub := GetTestPublishers()
 
-	ch, err := newChallengeHandler(white, game.Config{time.Minute}, cb, chpub)
+	ch, err := newChallengeHandler(
+		white, game.Config{TimeControl: time.Minute}, cb, chpub, game.RealClock)
 	if err != nil {
 		t.Error(err)
 	}
@@ -196,7 +261,7 @@ func getAcceptedChallenge() (*challengeHandler, error) {
 
 	callbackCalled := false
 	cb := func(
-		*challengeHandler, game.Config, *http.Cookie, *http.Cookie) (
+		*challengeHandler, game.Config, []*http.Cookie) (
 		*url.URL, error) {
 		callbackCalled = true
     newGamePath, err := url.Parse("/new/game/path/")
@@ -208,7 +273,8 @@ func getAcceptedChallenge() (*challengeHandler, error) {
 
   _, chpub := GetTestPublishers()
 
-	ch, err := newChallengeHandler(white, game.Config{time.Minute}, cb, chpub)
+	ch, err := newChallengeHandler(
+		white, game.Config{TimeControl: time.Minute}, cb, chpub, game.RealClock)
 	if err != nil {
 		return nil, err
 	}
@@ -282,10 +348,10 @@ func TestIntegrationWithGameRouter(t *testing.T) {
   }
   evpub, _ := GetTestPublishers()
 	// create game router
-	gr := newGameRouter(urlBase, evpub)
+	gr := newGameRouter(urlBase, evpub, game.RealClock)
 	// create challenge router (with game router fn as callback)
 
-	cr := newChallengeRouter(urlBase, gr.addGame, evpub)
+	cr := newChallengeRouter(urlBase, gr.addGame, evpub, game.RealClock)
 
 	// add challenge
 	b, err := json.Marshal(game.Config{TimeControl: 1 * time.Minute})
@@ -356,7 +422,7 @@ func TestInvalidConfig(t *testing.T) {
   _, chpub := GetTestPublishers()
 
 	ch, err := newChallengeHandler(
-    fakeWhiteCookie(), game.Config{TimeControl: 0}, nil, chpub)
+    fakeWhiteCookie(), game.Config{TimeControl: 0}, nil, chpub, game.RealClock)
 
 	if err == nil {
 		t.Error("shouldn't be able to make a game with zero time")
@@ -23,10 +23,12 @@ type challengeRouter struct {
 	urlBase    *url.URL
 	mutex      sync.RWMutex
   eventPub   evtpub.EventPublisher
+	clock      game.Clock
 }
 
 func newChallengeRouter(
-	urlBase *url.URL, createGame createGameFnT, eventPub evtpub.EventPublisher) (
+	urlBase *url.URL, createGame createGameFnT, eventPub evtpub.EventPublisher,
+	clock game.Clock) (
   *challengeRouter){
 
 	cr := challengeRouter{
@@ -36,6 +38,7 @@ func newChallengeRouter(
 		createGame: createGame,
 		urlBase:    urlBase,
     eventPub:   eventPub,
+		clock:      clock,
 	}
 
 	cr.router.GET("/", cr.getChallenges)
@@ -113,10 +116,10 @@ func (cr *challengeRouter) postChallenge(
 	// create challenge
 	id := cr.pathGen.newString(8)
 	bind := func(
-		ch *challengeHandler, config game.Config, cookie1, cookie2 *http.Cookie) (
+		ch *challengeHandler, config game.Config, players []*http.Cookie) (
     *url.URL, error) {
 
-		return cr.onChallengeAccepted(ch, id, config, cookie1, cookie2)
+		return cr.onChallengeAccepted(ch, id, config, players)
 	}
 
   fullPath := cr.urlBase.JoinPath(id)
@@ -125,7 +128,7 @@ func (cr *challengeRouter) postChallenge(
 		http.Error(w, err.Error(), http.StatusBadRequest)
     return
   }
-	challenge, err := newChallengeHandler(cookie, config, bind, chanPub)
+	challenge, err := newChallengeHandler(cookie, config, bind, chanPub, cr.clock)
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -162,8 +165,8 @@ func (cr *challengeRouter) getChallenges(
 
 // Not thread safe-- MUST be synchronized by challenge handler
 func (cr *challengeRouter) onChallengeAccepted(
-	ch *challengeHandler, id string, config game.Config, cookie1,
-	cookie2 *http.Cookie) (*url.URL, error) {
+	ch *challengeHandler, id string, config game.Config,
+	players []*http.Cookie) (*url.URL, error) {
   cr.mutex.RLock()
   defer cr.mutex.RUnlock()
 
@@ -184,7 +187,7 @@ func (cr *challengeRouter) onChallengeAccepted(
 		cr.deleteChallenge(id)
 	}
 
-	return cr.createGame(deleteCb, config, cookie1, cookie2)
+	return cr.createGame(deleteCb, config, players)
 }
 
 func (cr *challengeRouter) deleteChallenge(id string) {
@@ -198,7 +201,7 @@ func (cr *challengeRouter) deleteChallenge(id string) {
 
 func (cr *challengeRouter) PeriodicallyDeleteOldChallenges(d time.Duration) {
 	for {
-		time.Sleep(d)
+		cr.clock.Sleep(d)
 		cr.deleteOldChallenges(d)
 	}
 }
@@ -211,7 +214,7 @@ func (cr *challengeRouter) deleteOldChallenges(d time.Duration) {
 
 	count := 0
 	for id, challenge := range cr.challenges {
-		if !challenge.accepted && time.Since(challenge.timestamp) > d {
+		if !challenge.accepted && cr.clock.Now().Sub(challenge.timestamp) > d {
       cr.deleteChallenge(id)
 			count++
 		}
@@ -6,6 +6,7 @@ import (
 	"game"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
   "evtpub"
@@ -19,7 +20,7 @@ func TestNewChallengeRouter(t *testing.T) {
   evpub := evtpub.NewMockEventPublisher()
   urlBase, _ := url.Parse("/")
 
-	cr := newChallengeRouter(urlBase, nil, evpub)
+	cr := newChallengeRouter(urlBase, nil, evpub, game.RealClock)
 	if cr == nil {
 		t.Error("matchmaker was nil")
 	}
@@ -28,7 +29,7 @@ func TestNewChallengeRouter(t *testing.T) {
 func TestPostChallenge(t *testing.T) {
   evpub := evtpub.NewMockEventPublisher()
   urlBase, _ := url.Parse("/")
-	cr := newChallengeRouter(urlBase, nil, evpub)
+	cr := newChallengeRouter(urlBase, nil, evpub, game.RealClock)
 
 	rr, err := post10MinChallenge(cr)
   if err != nil {
@@ -54,11 +55,12 @@ func TestPostChallenge(t *testing.T) {
 func TestHandleChallengeRequestForwarding(t *testing.T) {
   evpub, chpub := GetTestPublishers()
   urlBase, _ := url.Parse("/")
-  cr := newChallengeRouter(urlBase, nil, evpub)
+  cr := newChallengeRouter(urlBase, nil, evpub, game.RealClock)
 
 
 	challenge, err := newChallengeHandler(
-    fakeWhiteCookie(), game.Config{time.Minute}, nil, chpub)
+    fakeWhiteCookie(), game.Config{TimeControl: time.Minute}, nil, chpub,
+    game.RealClock)
 	if err != nil {
 		t.Error(err)
 	}
@@ -83,7 +85,7 @@ func TestHandleChallengeRequestForwarding(t *testing.T) {
 func TestGetChallenges(t *testing.T) {
   evpub, chpub := GetTestPublishers()
   urlBase, _ := url.Parse("/")
-  cr := newChallengeRouter(urlBase, nil, evpub)
+  cr := newChallengeRouter(urlBase, nil, evpub, game.RealClock)
 
 	type challengeParams struct {
 		s      string
@@ -102,16 +104,17 @@ func TestGetChallenges(t *testing.T) {
 	}
 
 	paramsList := []*challengeParams{
-		newChallengeParams("a", fakeWhiteCookie(), game.Config{time.Minute}),
 		newChallengeParams(
-			"b", fakeWhiteCookie(), game.Config{time.Minute}),
+			"a", fakeWhiteCookie(), game.Config{TimeControl: time.Minute}),
 		newChallengeParams(
-			"c", fakeWhiteCookie(), game.Config{time.Hour}),
+			"b", fakeWhiteCookie(), game.Config{TimeControl: time.Minute}),
+		newChallengeParams(
+			"c", fakeWhiteCookie(), game.Config{TimeControl: time.Hour}),
 	}
 
 	for _, params := range paramsList {
 		challenge, err := newChallengeHandler(
-      params.cookie, params.config, nil, chpub)
+      params.cookie, params.config, nil, chpub, game.RealClock)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -162,7 +165,7 @@ func TestGetChallenges(t *testing.T) {
 func TestJoinNonExistentID(t *testing.T) {
 	evpub := evtpub.NewMockEventPublisher()
   urlBase, _ := url.Parse("/")
-  cr := newChallengeRouter(urlBase, nil, evpub)
+  cr := newChallengeRouter(urlBase, nil, evpub, game.RealClock)
 
 	// This should trigger the above callback
 	req, err := http.NewRequest("POST", "/nonexistent/join", nil)
@@ -183,7 +186,7 @@ func TestJoinNonExistentID(t *testing.T) {
 func TestPostChallengeInvalidConfig(t *testing.T) {
 	evpub := evtpub.NewMockEventPublisher()
   urlBase, _ := url.Parse("/")
-  cr := newChallengeRouter(urlBase, nil, evpub)
+  cr := newChallengeRouter(urlBase, nil, evpub, game.RealClock)
 
 	// create body
 	config := game.Config{}
@@ -206,26 +209,58 @@ func TestPostChallengeInvalidConfig(t *testing.T) {
 	}
 }
 
+func TestPostChallengeStartPosition(t *testing.T) {
+	evpub := evtpub.NewMockEventPublisher()
+  urlBase, _ := url.Parse("/")
+  cr := newChallengeRouter(urlBase, nil, evpub, game.RealClock)
+
+	type testCase struct {
+		tfen         string
+		expectedCode int
+	}
+	for _, tc := range []testCase{
+		testCase{"W4/5/2R2/5/4B b 1,0", http.StatusSeeOther},
+		// White has already won
+		testCase{"W4/5/2R2/5/4B b 2,0", http.StatusBadRequest},
+	} {
+		body := `{"timeControlNs": 60000000000, "startPosition": "` +
+			tc.tfen + `"}`
+		req, err := http.NewRequest("POST", "/", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.AddCookie(fakeWhiteCookie())
+
+		rr := httptest.NewRecorder()
+		cr.ServeHTTP(rr, req)
+		if rr.Code != tc.expectedCode {
+			t.Errorf("%s: code %d does not match expectation %d",
+				tc.tfen, rr.Code, tc.expectedCode)
+		}
+	}
+}
+
 func TestDeleteOldChallenges(t *testing.T) {
 	evpub := evtpub.NewMockEventPublisher()
   urlBase, _ := url.Parse("/")
-  cr := newChallengeRouter(urlBase, nil, evpub)
+  clock := game.NewFakeClock(time.Now())
+  cr := newChallengeRouter(urlBase, nil, evpub, clock)
 
   chpub1, _ := evpub.NewChannelPublisher("testpath1")
 	cr.challenges["too old"] = &challengeHandler{
-		timestamp: time.Now().Add(-1 * time.Hour),
+		timestamp: clock.Now().Add(-1 * time.Hour),
 		accepted:  false,
     channelPub: chpub1,
 	}
   chpub2, _ := evpub.NewChannelPublisher("testpath2")
 	cr.challenges["accepted"] = &challengeHandler{
-		timestamp: time.Now().Add(-1 * time.Hour),
+		timestamp: clock.Now().Add(-1 * time.Hour),
 		accepted:  true,
     channelPub: chpub2,
 	}
   chpub3, _ := evpub.NewChannelPublisher("testpath3")
 	cr.challenges["not too old"] = &challengeHandler{
-		timestamp: time.Now().Add(-1 * time.Minute),
+		timestamp: clock.Now().Add(-1 * time.Minute),
 		accepted:  false,
     channelPub: chpub3,
 	}
@@ -247,9 +282,39 @@ func TestDeleteOldChallenges(t *testing.T) {
 	}
 }
 
+func TestPeriodicallyDeleteOldChallenges(t *testing.T) {
+	clock := game.NewFakeClock(time.Now())
+	urlBase, _ := url.Parse("/")
+	cr := newChallengeRouter(
+		urlBase, nil, evtpub.NewMockEventPublisher(), clock)
+	if _, err := post10MinChallenge(cr); err != nil {
+		t.Fatal(err)
+	}
+
+	count := func() int {
+		cr.mutex.RLock()
+		defer cr.mutex.RUnlock()
+		return len(cr.challenges)
+	}
+
+	go cr.PeriodicallyDeleteOldChallenges(10 * time.Minute)
+	clock.BlockUntilWaiting(1)
+	clock.Advance(10 * time.Minute)
+	// Once the loop is back to sleep, the first cleanup is done.
+	clock.BlockUntilWaiting(1)
+	if count() != 1 {
+		t.Error("expected the challenge to survive the first cleanup")
+	}
+	clock.Advance(10 * time.Minute)
+	clock.BlockUntilWaiting(1)
+	if count() != 0 {
+		t.Error("expected the challenge to be deleted by the second cleanup")
+	}
+}
+
 func post10MinChallenge(
   cr *challengeRouter) (*httptest.ResponseRecorder, error) {
-	config := game.Config{10 * time.Minute}
+	config := game.Config{TimeControl: 10 * time.Minute}
 	b, err := json.Marshal(config)
 	if err != nil {
 		return nil, err
@@ -269,7 +334,7 @@ func post10MinChallenge(
 func TestChallengeCountLimit(t *testing.T) {
 	evpub := evtpub.NewMockEventPublisher()
   urlBase, _ := url.Parse("/")
-  cr := newChallengeRouter(urlBase, nil, evpub)
+  cr := newChallengeRouter(urlBase, nil, evpub, game.RealClock)
 
 	for i := 0; i < 100; i++ {
     rr, err := post10MinChallenge(cr)
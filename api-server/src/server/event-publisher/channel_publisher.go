@@ -24,3 +24,10 @@ func (scep *ChannelPublisher) Delete() error {
   return scep.eventPub.deleteChannel(scep.channelURL)
 }
 
+
+// Creates a channel nested under this one, e.g. for a single subscriber.
+func (scep *ChannelPublisher) NewSubchannel(
+  elem ...string) (*ChannelPublisher, error) {
+  return scep.eventPub.NewChannelPublisher(
+    scep.channelURL.JoinPath(elem...).String())
+}
@@ -188,3 +188,27 @@ func TestMockEventPublisherNoInit(t *testing.T) {
     t.Error("shouldn't be able to delete channel without initializing it")
   }
 }
+
+func TestMockEventPublisherSubchannel(t *testing.T) {
+  mep := NewMockEventPublisher()
+
+  cp, err := mep.NewChannelPublisher("test/path")
+  if err != nil {
+    t.Fatal(err)
+  }
+  sub, err := cp.NewSubchannel("players", "abc")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if err = sub.Push("update", "data"); err != nil {
+    t.Error(err)
+  }
+
+  stream, ok := mep.Channels["test/path/players/abc"]
+  if !ok || len(stream.Pushes) != 1 {
+    t.Error("expected the push to go to the subchannel")
+  }
+  if len(mep.Channels["test/path"].Pushes) != 0 {
+    t.Error("expected nothing to be pushed to the parent channel")
+  }
+}
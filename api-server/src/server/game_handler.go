@@ -2,9 +2,12 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
 	"game"
 	"github.com/julienschmidt/httprouter"
   "evtpub"
+	"io"
+	"log"
 	"net/http"
 	"sync"
 	"time"
@@ -20,36 +23,103 @@ type gameHandler struct {
 	timeMutex         sync.Mutex
 	deleteChallengeCb deleteChallengeFn
   channelPub evtpub.ChannelPublisher
+	// Only set for correspondence games.
+	onSave       func()
+	onYourMove   func(playerID string)
+	lastNotified string
+	notifyMutex  sync.Mutex
+	// See idempotencyCache.
+	idempotency idempotencyCache
+	// See playerChannels.
+	playerChannels playerChannels
+
+	clock game.Clock
 }
 
 func newGameHandler(
 	deleteChallengeCb deleteChallengeFn,
   channelPub evtpub.ChannelPublisher,
   config game.Config,
-  white, black *http.Cookie,
+  players []*http.Cookie,
+  clock game.Clock,
 )(
   *gameHandler,
   error,
 ){
-	gh := gameHandler{
-		router:            httprouter.New(),
-		deleteChallengeCb: deleteChallengeCb,
-    channelPub: channelPub,
-	}
+	gh := makeGameHandler(deleteChallengeCb, channelPub, clock)
 	gm, err := game.NewGameManager(
-    config, white, black, gh.publishUpdate, gh.markComplete,
+    clock, config, players, gh.publishUpdate, gh.markComplete,
     gh.undoMarkComplete)
 	if err != nil {
 		return nil, err
 	}
 	gh.gm = gm
+	return gh, nil
+}
+
+// For games brought back after a restart.
+func newRestoredGameHandler(
+	deleteChallengeCb deleteChallengeFn,
+  channelPub evtpub.ChannelPublisher,
+  saved game.SavedGame,
+  clock game.Clock,
+)(
+  *gameHandler,
+  error,
+){
+	gh := makeGameHandler(deleteChallengeCb, channelPub, clock)
+	gm, err := game.RestoreGameManager(
+    clock, saved, gh.publishUpdate, gh.markComplete, gh.undoMarkComplete)
+	if err != nil {
+		return nil, err
+	}
+	gh.gm = gm
+	// Finished games still get cleaned up.
+	if gm.IsOver() {
+		gh.markComplete()
+	}
+	return gh, nil
+}
+
+func makeGameHandler(
+	deleteChallengeCb deleteChallengeFn,
+  channelPub evtpub.ChannelPublisher, clock game.Clock) *gameHandler {
+	gh := gameHandler{
+		router:            httprouter.New(),
+		deleteChallengeCb: deleteChallengeCb,
+    channelPub: channelPub,
+		clock:             clock,
+	}
 
 	gh.router.GET("/state", gh.getState)
+	gh.router.GET("/event-channel", gh.getEventChannel)
+	gh.router.GET("/record", gh.getRecord)
+	gh.router.GET("/match", gh.getMatch)
 	gh.router.POST("/move", gh.postMove)
+	gh.router.GET("/premove", gh.getPremoves)
+	gh.router.POST("/premove", gh.postPremove)
+	gh.router.POST("/premove/clear", gh.postPremoveClear)
+	gh.router.GET("/conditional-moves", gh.getConditionalMoves)
+	gh.router.PUT("/conditional-moves", gh.putConditionalMoves)
+	gh.router.DELETE("/conditional-moves", gh.deleteConditionalMoves)
 	gh.router.POST("/resignation", gh.postResignation)
+	gh.router.POST("/abort", gh.postAbort)
+	gh.router.POST("/pause", gh.postPause)
+	gh.router.POST("/resume", gh.postResume)
+	gh.router.POST("/draw-offer", gh.postDrawOffer)
+	gh.router.POST("/draw-offer/accept", gh.postDrawAccept)
+	gh.router.POST("/draw-offer/decline", gh.postDrawDecline)
+	gh.router.POST("/draw-claim", gh.postDrawClaim)
+	gh.router.POST("/takeback-request", gh.postTakebackRequest)
+	gh.router.POST("/takeback-request/accept", gh.postTakebackAccept)
+	gh.router.POST("/takeback-request/decline", gh.postTakebackDecline)
 	gh.router.POST("/rematch-offer", gh.postRematchOffer)
+	gh.router.POST("/rematch-offer/accept", gh.postRematchAccept)
+	gh.router.POST("/rematch-offer/decline", gh.postRematchDecline)
+	gh.router.POST("/ping", gh.postPing)
+	gh.router.POST("/abandonment-claim", gh.postAbandonmentClaim)
 
-	return &gh, nil
+	return &gh
 }
 
 func (gh *gameHandler) publishUpdate() {
@@ -59,23 +129,98 @@ func (gh *gameHandler) publishUpdate() {
 	}
 
   gh.channelPub.Push("state-push", string(b))
+	if err := gh.playerChannels.push(gh.gm); err != nil {
+		log.Print("Couldn't push to players: " + err.Error())
+	}
+
+	if gh.onSave != nil {
+		gh.onSave()
+	}
+	gh.notifyYourMove()
+}
+
+func (gh *gameHandler) isCorrespondence() bool {
+	return gh.gm != nil && gh.gm.GetConfig().IsCorrespondence()
+}
+
+// Lets the player to move know it's their turn (once per move).
+func (gh *gameHandler) notifyYourMove() {
+	if gh.onYourMove == nil {
+		return
+	}
+	id, ply, ok := gh.gm.ToMove()
+	if !ok {
+		return
+	}
+
+	gh.notifyMutex.Lock()
+	defer gh.notifyMutex.Unlock()
+	key := fmt.Sprintf("%s@%d", id, ply)
+	if key == gh.lastNotified {
+		return
+	}
+	gh.lastNotified = key
+	gh.onYourMove(id)
 }
 
 // Convenience method
 func (gh *gameHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if key := r.Header.Get("Idempotency-Key"); key != "" &&
+		r.Method == http.MethodPost {
+		gh.idempotency.serve(gh.router, w, r, key)
+		return
+	}
 	gh.router.ServeHTTP(w, r)
 }
 
 func (gh *gameHandler) getState(
 	w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// Players of hidden information variants get their own view of the game;
+	// everyone else gets the same view as the state pushes.
+	var cookie *http.Cookie
+	if c := r.Cookies(); len(c) > 0 {
+		cookie = c[0]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(gh.gm.GetClientViewFor(cookie))
+}
+
+func (gh *gameHandler) getRecord(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	record, err := gh.gm.GetRecord()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(record)
+}
+
+// The scoreboard and records of every finished game of a match.
+func (gh *gameHandler) getMatch(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	record, err := gh.gm.GetMatchRecord()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(gh.gm)
+	json.NewEncoder(w).Encode(record)
+}
+
+// Clients may send along the round trip time they've been seeing, which is
+// used for lag compensation, and the position they moved from, which stale
+// moves are refused with a 409 for.
+type moveRequest struct {
+	game.Move
+	Lag time.Duration `json:"lagNs"`
+	game.MoveExpectation
 }
 
 func (gh *gameHandler) postMove(
 	w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	// Parse body.
-	var move game.Move
+	var move moveRequest
 	err := json.NewDecoder(r.Body).Decode(&move)
 	if err != nil {
 		http.Error(w, "Could not parse move: "+err.Error(), http.StatusBadRequest)
@@ -88,7 +233,14 @@ func (gh *gameHandler) postMove(
 		return
 	}
 
-	if err = gh.gm.TryMove(move, c[0]); err != nil {
+	err = gh.gm.TryExpectedMove(
+		move.Move, move.MoveExpectation, move.Lag, c[0])
+	if err == game.ErrPositionChanged {
+		http.Error(w, "Could not execute move: "+err.Error(),
+			http.StatusConflict)
+		return
+	}
+	if err != nil {
 		http.Error(w, "Could not execute move: "+err.Error(),
 			http.StatusBadRequest)
 		return
@@ -98,6 +250,139 @@ func (gh *gameHandler) postMove(
 	gh.publishUpdate()
 }
 
+// A player's own premoves, including any the server had to discard.
+func (gh *gameHandler) getPremoves(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c := r.Cookies()
+	if len(c) == 0 {
+		http.Error(w, "No cookies provided.", http.StatusUnauthorized)
+		return
+	}
+
+	premoves, err := gh.gm.GetPremoves(c[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(premoves)
+}
+
+// Nobody else gets to see premoves, so there's only an update to publish if
+// it turned out to be the player's turn and the premove was played.
+func (gh *gameHandler) postPremove(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var move game.Move
+	if err := json.NewDecoder(r.Body).Decode(&move); err != nil {
+		http.Error(w, "Could not parse move: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c := r.Cookies()
+	if len(c) == 0 {
+		http.Error(w, "No cookies provided.", http.StatusUnauthorized)
+		return
+	}
+
+	played, err := gh.gm.TryPremove(move, c[0])
+	if err != nil {
+		http.Error(w, "Could not queue premove: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+
+	w.Write([]byte("success"))
+	if played {
+		gh.publishUpdate()
+	}
+}
+
+func (gh *gameHandler) postPremoveClear(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c := r.Cookies()
+	if len(c) == 0 {
+		http.Error(w, "No cookies provided.", http.StatusUnauthorized)
+		return
+	}
+
+	if err := gh.gm.ClearPremoves(c[0]); err != nil {
+		http.Error(w, "Could not clear premoves: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+
+	w.Write([]byte("success"))
+}
+
+// Like premoves, conditional moves are private, so changing them publishes
+// nothing.
+func (gh *gameHandler) getConditionalMoves(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c := r.Cookies()
+	if len(c) == 0 {
+		http.Error(w, "No cookies provided.", http.StatusUnauthorized)
+		return
+	}
+
+	moves, err := gh.gm.GetConditionalMoves(c[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if moves == nil {
+		moves = []game.ConditionalMove{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(moves)
+}
+
+func (gh *gameHandler) putConditionalMoves(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var moves []game.ConditionalMove
+	if err := json.NewDecoder(r.Body).Decode(&moves); err != nil {
+		http.Error(w, "Could not parse conditional moves: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+
+	c := r.Cookies()
+	if len(c) == 0 {
+		http.Error(w, "No cookies provided.", http.StatusUnauthorized)
+		return
+	}
+
+	if err := gh.gm.TrySetConditionalMoves(c[0], moves); err != nil {
+		http.Error(w, "Could not set conditional moves: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+
+	w.Write([]byte("success"))
+	if gh.onSave != nil {
+		gh.onSave()
+	}
+}
+
+func (gh *gameHandler) deleteConditionalMoves(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c := r.Cookies()
+	if len(c) == 0 {
+		http.Error(w, "No cookies provided.", http.StatusUnauthorized)
+		return
+	}
+
+	if err := gh.gm.TrySetConditionalMoves(c[0], nil); err != nil {
+		http.Error(w, "Could not delete conditional moves: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+
+	w.Write([]byte("success"))
+	if gh.onSave != nil {
+		gh.onSave()
+	}
+}
+
 func (gh *gameHandler) postResignation(
 	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	c := r.Cookies()
@@ -115,8 +400,220 @@ func (gh *gameHandler) postResignation(
 	gh.publishUpdate()
 }
 
+func (gh *gameHandler) postAbort(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c := r.Cookies()
+	if len(c) == 0 {
+		http.Error(w, "No cookies provided.", http.StatusUnauthorized)
+		return
+	}
+
+	if !gh.gm.TryAbort(c[0]) {
+		http.Error(w, "Could not abort.", http.StatusBadRequest)
+		return
+	}
+
+	w.Write([]byte("success"))
+	gh.publishUpdate()
+}
+
+// The body is optional. If it has a resumeAt time, the game resumes on its own
+// then.
+type pauseRequest struct {
+	ResumeAt *time.Time `json:"resumeAt"`
+}
+
+func (gh *gameHandler) postPause(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req pauseRequest
+	if r.Body != nil {
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil && err != io.EOF {
+			http.Error(w, "Could not parse request: "+err.Error(),
+				http.StatusBadRequest)
+			return
+		}
+	}
+
+	c := r.Cookies()
+	if len(c) == 0 {
+		http.Error(w, "No cookies provided.", http.StatusUnauthorized)
+		return
+	}
+
+	if _, err := gh.gm.TryOfferPause(c[0], req.ResumeAt); err != nil {
+		http.Error(w, "Could not pause: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Write([]byte("success"))
+	gh.publishUpdate()
+}
+
+func (gh *gameHandler) postResume(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c := r.Cookies()
+	if len(c) == 0 {
+		http.Error(w, "No cookies provided.", http.StatusUnauthorized)
+		return
+	}
+
+	if _, err := gh.gm.TryOfferResume(c[0]); err != nil {
+		http.Error(w, "Could not resume: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Write([]byte("success"))
+	gh.publishUpdate()
+}
+
+// Offering a draw when the other team already has accepts their offer.
+func (gh *gameHandler) postDrawOffer(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c := r.Cookies()
+	if len(c) == 0 {
+		http.Error(w, "No cookies provided.", http.StatusUnauthorized)
+		return
+	}
+
+	if _, err := gh.gm.TryOfferDraw(c[0]); err != nil {
+		http.Error(w, "Could not offer draw: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+
+	w.Write([]byte("success"))
+	gh.publishUpdate()
+}
+
+func (gh *gameHandler) postDrawAccept(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c := r.Cookies()
+	if len(c) == 0 {
+		http.Error(w, "No cookies provided.", http.StatusUnauthorized)
+		return
+	}
+
+	if err := gh.gm.TryAcceptDraw(c[0]); err != nil {
+		http.Error(w, "Could not accept draw: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+
+	w.Write([]byte("success"))
+	gh.publishUpdate()
+}
+
+func (gh *gameHandler) postDrawDecline(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c := r.Cookies()
+	if len(c) == 0 {
+		http.Error(w, "No cookies provided.", http.StatusUnauthorized)
+		return
+	}
+
+	if err := gh.gm.TryDeclineDraw(c[0]); err != nil {
+		http.Error(w, "Could not decline draw: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+
+	w.Write([]byte("success"))
+	gh.publishUpdate()
+}
+
+// Claims a draw under the no capture rule, for games where it isn't automatic.
+func (gh *gameHandler) postDrawClaim(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c := r.Cookies()
+	if len(c) == 0 {
+		http.Error(w, "No cookies provided.", http.StatusUnauthorized)
+		return
+	}
+
+	if err := gh.gm.TryClaimNoCaptureDraw(c[0]); err != nil {
+		http.Error(w, "Could not claim draw: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+
+	w.Write([]byte("success"))
+	gh.publishUpdate()
+}
+
+func (gh *gameHandler) postTakebackRequest(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c := r.Cookies()
+	if len(c) == 0 {
+		http.Error(w, "No cookies provided.", http.StatusUnauthorized)
+		return
+	}
+
+	if err := gh.gm.TryRequestTakeback(c[0]); err != nil {
+		http.Error(w, "Could not request takeback: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+
+	w.Write([]byte("success"))
+	gh.publishUpdate()
+}
+
+func (gh *gameHandler) postTakebackAccept(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c := r.Cookies()
+	if len(c) == 0 {
+		http.Error(w, "No cookies provided.", http.StatusUnauthorized)
+		return
+	}
+
+	if err := gh.gm.TryAcceptTakeback(c[0]); err != nil {
+		http.Error(w, "Could not accept takeback: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+
+	w.Write([]byte("success"))
+	gh.publishUpdate()
+}
+
+func (gh *gameHandler) postTakebackDecline(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c := r.Cookies()
+	if len(c) == 0 {
+		http.Error(w, "No cookies provided.", http.StatusUnauthorized)
+		return
+	}
+
+	if err := gh.gm.TryDeclineTakeback(c[0]); err != nil {
+		http.Error(w, "Could not decline takeback: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+
+	w.Write([]byte("success"))
+	gh.publishUpdate()
+}
+
+// Without a body this offers to play again with the same settings (or accepts
+// the open proposal); with one it proposes different settings, countering any
+// open proposal.
 func (gh *gameHandler) postRematchOffer(
 	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var offer *game.RematchOffer
+	if r.Body != nil {
+		var tmp game.RematchOffer
+		err := json.NewDecoder(r.Body).Decode(&tmp)
+		if err != nil && err != io.EOF {
+			http.Error(w, "Could not parse request: "+err.Error(),
+				http.StatusBadRequest)
+			return
+		}
+		if err == nil {
+			offer = &tmp
+		}
+	}
+
   c := r.Cookies()
 
 	if len(c) == 0 {
@@ -124,7 +621,12 @@ func (gh *gameHandler) postRematchOffer(
 		return
 	}
 
-  _, err := gh.gm.OfferRematch(c[0])
+	var err error
+	if offer != nil {
+		_, err = gh.gm.TryProposeRematch(c[0], *offer)
+	} else {
+		_, err = gh.gm.OfferRematch(c[0])
+	}
 	if err != nil {
 		http.Error(w, "Error: "+err.Error(), http.StatusBadRequest)
 		return
@@ -136,10 +638,46 @@ func (gh *gameHandler) postRematchOffer(
 	gh.publishUpdate()
 }
 
+func (gh *gameHandler) postRematchAccept(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c := r.Cookies()
+	if len(c) == 0 {
+		http.Error(w, "No cookies provided.", http.StatusUnauthorized)
+		return
+	}
+
+	if _, err := gh.gm.TryAcceptRematch(c[0]); err != nil {
+		http.Error(w, "Could not accept rematch: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+
+	w.Write([]byte("success"))
+	gh.publishUpdate()
+}
+
+func (gh *gameHandler) postRematchDecline(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c := r.Cookies()
+	if len(c) == 0 {
+		http.Error(w, "No cookies provided.", http.StatusUnauthorized)
+		return
+	}
+
+	if err := gh.gm.TryDeclineRematch(c[0]); err != nil {
+		http.Error(w, "Could not decline rematch: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+
+	w.Write([]byte("success"))
+	gh.publishUpdate()
+}
+
 func (gh *gameHandler) markComplete() {
 	gh.timeMutex.Lock()
 	defer gh.timeMutex.Unlock()
-	t := time.Now()
+	t := gh.clock.Now()
 	gh.completionTime = &t
 }
 
@@ -155,7 +693,7 @@ func (gh *gameHandler) DurationSinceCompletion() *time.Duration {
 	if gh.completionTime == nil {
 		return nil
 	}
-	d := time.Since(*gh.completionTime)
+	d := gh.clock.Now().Sub(*gh.completionTime)
 	return &d
 }
 
@@ -164,4 +702,56 @@ func (gh *gameHandler) TearDown() {
 		gh.deleteChallengeCb()
 	}
   gh.channelPub.Delete()
+	gh.playerChannels.deleteAll()
+}
+
+// Players' clients ping every few seconds while they have the game open.
+// Everybody is told when somebody connects or disconnects.
+func (gh *gameHandler) postPing(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c := r.Cookies()
+	if len(c) == 0 {
+		http.Error(w, "No cookies provided.", http.StatusUnauthorized)
+		return
+	}
+
+	changed, err := gh.gm.Ping(c[0])
+	if err != nil {
+		http.Error(w, "Could not ping: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Write([]byte("success"))
+	if changed {
+		gh.publishUpdate()
+	}
+}
+
+type abandonmentClaimRequest struct {
+	Claim game.AbandonClaim `json:"claim"`
+}
+
+func (gh *gameHandler) postAbandonmentClaim(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req abandonmentClaimRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Could not parse claim: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+
+	c := r.Cookies()
+	if len(c) == 0 {
+		http.Error(w, "No cookies provided.", http.StatusUnauthorized)
+		return
+	}
+
+	if err := gh.gm.TryClaimAbandoned(c[0], req.Claim); err != nil {
+		http.Error(w, "Could not claim game: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+
+	w.Write([]byte("success"))
+	gh.publishUpdate()
 }
@@ -9,6 +9,7 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
   "evtpub"
@@ -32,6 +33,10 @@ func fakeBlackCookie() *http.Cookie {
 	return &c
 }
 
+func fakePlayers() []*http.Cookie {
+	return []*http.Cookie{fakeWhiteCookie(), fakeBlackCookie()}
+}
+
 func TestNewGameHandler(t *testing.T) {
 	// Make sure gameHandler implements the http.Handler interface
 	var _ http.Handler = (*gameHandler)(nil)
@@ -39,7 +44,7 @@ func TestNewGameHandler(t *testing.T) {
 
 	gh, err := newGameHandler(
 		func() {}, *chpub, game.Config{TimeControl: 1 * time.Minute},
-    fakeWhiteCookie(), fakeBlackCookie())
+    fakePlayers(), game.RealClock)
 	if err != nil {
 		t.Error(err)
 	}
@@ -52,7 +57,7 @@ func TestGetState(t *testing.T) {
   _, chpub := GetTestPublishers()
 	gh, err := newGameHandler(
 		func() {}, *chpub, game.Config{TimeControl: 1 * time.Minute},
-    fakeWhiteCookie(), fakeBlackCookie())
+    fakePlayers(), game.RealClock)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -83,6 +88,101 @@ func TestGetState(t *testing.T) {
 	}
 }
 
+func TestGetStateDark(t *testing.T) {
+  _, chpub := GetTestPublishers()
+	gh, err := newGameHandler(
+		func() {}, *chpub,
+		game.Config{TimeControl: 1 * time.Minute, Variant: game.VariantDark},
+    fakePlayers(), game.RealClock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getState := func(cookies []*http.Cookie) string {
+		req, err := http.NewRequest("GET", "/state", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		gh.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected code %d, got %d", http.StatusOK, rr.Code)
+		}
+		return rr.Body.String()
+	}
+
+	// White can't see black's corners at the start, spectators can.
+	whiteState := getState([]*http.Cookie{gh.gm.GetWhiteCookie()})
+	if strings.Contains(whiteState, `"B"`) ||
+		!strings.Contains(whiteState, `"?"`) {
+		t.Errorf("unexpected state for white: %s", whiteState)
+	}
+	spectatorState := getState(nil)
+	if !strings.Contains(spectatorState, `"B"`) ||
+		strings.Contains(spectatorState, `"?"`) {
+		t.Errorf("unexpected state for spectators: %s", spectatorState)
+	}
+}
+
+func TestGetRecord(t *testing.T) {
+  _, chpub := GetTestPublishers()
+	gh, err := newGameHandler(
+		func() {}, *chpub, game.Config{TimeControl: 1 * time.Minute},
+    fakePlayers(), game.RealClock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", "/record", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	gh.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected code %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var record game.GameRecord
+	if err := json.NewDecoder(rr.Body).Decode(&record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Status != "ONGOING" || len(record.Moves) != 0 {
+		t.Errorf("unexpected record %v", record)
+	}
+}
+
+func TestGetMatch(t *testing.T) {
+	_, chpub := GetTestPublishers()
+	config := game.Config{
+		TimeControl: 1 * time.Minute,
+		Match:       &game.MatchConfig{Games: 3, BestOf: true},
+	}
+	gh, err := newGameHandler(
+		func() {}, *chpub, config, fakePlayers(), game.RealClock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gh.gm.TryResign(fakeWhiteCookie())
+
+	req, _ := http.NewRequest("GET", "/match", nil)
+	rr := httptest.NewRecorder()
+	gh.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected code %d, got %d", http.StatusOK, rr.Code)
+	}
+	var record game.MatchRecord
+	if err := json.NewDecoder(rr.Body).Decode(&record); err != nil {
+		t.Fatal(err)
+	}
+	if len(record.Games) != 1 || record.Scores["black"] != 1 || record.Over {
+		t.Errorf("unexpected match record %+v", record)
+	}
+}
+
 func postMove(
   t *testing.T, gh *gameHandler, evpub *evtpub.MockEventPublisher, body []byte,
   cookies []*http.Cookie, expectedStatus int) {
@@ -103,7 +203,7 @@ func TestPostValidMove(t *testing.T) {
   evpub, chpub := GetTestPublishers()
 	gh, err := newGameHandler(
 		func() {}, *chpub, game.Config{TimeControl: 1 * time.Minute},
-    fakeWhiteCookie(), fakeBlackCookie())
+    fakePlayers(), game.RealClock)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -126,7 +226,7 @@ func TestPostInvalidMove(t *testing.T) {
   evpub, chpub := GetTestPublishers()
 	gh, err := newGameHandler(
 		func() {}, *chpub, game.Config{TimeControl: 1 * time.Minute},
-    fakeWhiteCookie(), fakeBlackCookie())
+    fakePlayers(), game.RealClock)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -142,7 +242,7 @@ func TestPostMoveNoCookie(t *testing.T) {
   evpub, chpub := GetTestPublishers()
 	gh, err := newGameHandler(
 		func() {}, *chpub, game.Config{TimeControl: 1 * time.Minute},
-    fakeWhiteCookie(), fakeBlackCookie())
+    fakePlayers(), game.RealClock)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -164,7 +264,7 @@ func TestPostMoveEmptyBody(t *testing.T) {
   evpub, chpub := GetTestPublishers()
 	gh, err := newGameHandler(
 		func() {}, *chpub, game.Config{TimeControl: 1 * time.Minute},
-    fakeWhiteCookie(), fakeBlackCookie())
+    fakePlayers(), game.RealClock)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -186,7 +286,6 @@ func handleReqCheckEventStream(
 	// Run the request through our handler
 	resp := httptest.NewRecorder()
 	gh.ServeHTTP(resp, req)
-	time.Sleep(time.Millisecond)
 
 	// Check the response body is what we expect.
 	if resp.Code != expectedStatus {
@@ -209,8 +308,8 @@ func handleReqCheckEventStream(
 func TestPostResignation(t *testing.T) {
   evpub, chpub := GetTestPublishers()
 	gh, _ := newGameHandler(
-		nil, *chpub, game.Config{TimeControl: 1 * time.Minute}, fakeWhiteCookie(),
-		fakeBlackCookie())
+		nil, *chpub, game.Config{TimeControl: 1 * time.Minute}, fakePlayers(),
+		game.RealClock)
 
 	req, err := http.NewRequest("POST", "/resignation", nil)
 	req.AddCookie(fakeWhiteCookie())
@@ -224,8 +323,8 @@ func TestPostResignation(t *testing.T) {
 func TestPostResignationNoCookie(t *testing.T) {
   evpub, chpub := GetTestPublishers()
 	gh, _ := newGameHandler(
-		nil, *chpub, game.Config{TimeControl: 1 * time.Minute}, fakeWhiteCookie(),
-		fakeBlackCookie())
+		nil, *chpub, game.Config{TimeControl: 1 * time.Minute}, fakePlayers(),
+		game.RealClock)
 
 	req, err := http.NewRequest("POST", "/resignation", nil)
 
@@ -238,8 +337,8 @@ func TestPostResignationNoCookie(t *testing.T) {
 func TestPostResignationTwice(t *testing.T) {
   evpub, chpub := GetTestPublishers()
 	gh, _ := newGameHandler(
-		nil, *chpub, game.Config{TimeControl: 1 * time.Minute}, fakeWhiteCookie(),
-		fakeBlackCookie())
+		nil, *chpub, game.Config{TimeControl: 1 * time.Minute}, fakePlayers(),
+		game.RealClock)
 
 	req, err := http.NewRequest("POST", "/resignation", nil)
 	req.AddCookie(fakeWhiteCookie())
@@ -256,11 +355,413 @@ func TestPostResignationTwice(t *testing.T) {
 	}
 }
 
+func TestPostAbort(t *testing.T) {
+	evpub, chpub := GetTestPublishers()
+	gh, _ := newGameHandler(
+		nil, *chpub, game.Config{TimeControl: 1 * time.Minute}, fakePlayers(),
+		game.RealClock)
+
+	// Black can abort before making their first move.
+	err := gh.gm.TryMove(
+		game.Move{X: 0, Y: 0, D: game.DirRight}, fakeWhiteCookie())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("POST", "/abort", nil)
+	req.AddCookie(fakeBlackCookie())
+
+	err = handleReqCheckEventStream(gh, evpub, req, http.StatusOK)
+	if err != nil {
+		t.Error(err)
+	}
+	if view := gh.gm.GetClientView(); view.AbortedBy != "BLACK" ||
+		view.AbortReason != "REQUESTED" {
+		t.Errorf("expected black to have aborted, got %s (%s)",
+			view.AbortedBy, view.AbortReason)
+	}
+
+	// Already over
+	err = handleReqCheckEventStream(gh, evpub, req, http.StatusBadRequest)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPostPauseAndResume(t *testing.T) {
+	evpub, chpub := GetTestPublishers()
+	gh, _ := newGameHandler(
+		nil, *chpub, game.Config{TimeControl: 1 * time.Minute}, fakePlayers(),
+		game.RealClock)
+
+	for _, c := range []*http.Cookie{fakeWhiteCookie(), fakeBlackCookie()} {
+		req, _ := http.NewRequest("POST", "/pause", nil)
+		req.AddCookie(c)
+		err := handleReqCheckEventStream(gh, evpub, req, http.StatusOK)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if status := gh.gm.GetClientView().Status; status != "PAUSED" {
+		t.Fatalf("expected the game to be paused, got %s", status)
+	}
+
+	// Resuming on a schedule can only be agreed on before pausing.
+	body := strings.NewReader(`{"resumeAt": "2000-01-01T00:00:00Z"}`)
+	req, _ := http.NewRequest("POST", "/pause", body)
+	req.AddCookie(fakeWhiteCookie())
+	err := handleReqCheckEventStream(gh, evpub, req, http.StatusBadRequest)
+	if err != nil {
+		t.Error(err)
+	}
+
+	for _, c := range []*http.Cookie{fakeBlackCookie(), fakeWhiteCookie()} {
+		req, _ := http.NewRequest("POST", "/resume", nil)
+		req.AddCookie(c)
+		err := handleReqCheckEventStream(gh, evpub, req, http.StatusOK)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if status := gh.gm.GetClientView().Status; status != "ONGOING" {
+		t.Errorf("expected the game to be resumed, got %s", status)
+	}
+}
+
+func TestPostDrawOffer(t *testing.T) {
+	evpub, chpub := GetTestPublishers()
+	gh, _ := newGameHandler(
+		nil, *chpub, game.Config{TimeControl: 1 * time.Minute}, fakePlayers(),
+		game.RealClock)
+
+	for _, tc := range []struct {
+		path   string
+		cookie *http.Cookie
+		status int
+	}{
+		{"/draw-offer", fakeWhiteCookie(), http.StatusOK},
+		{"/draw-offer/accept", fakeWhiteCookie(), http.StatusBadRequest},
+		{"/draw-offer/decline", fakeBlackCookie(), http.StatusOK},
+		{"/draw-offer/accept", fakeBlackCookie(), http.StatusBadRequest},
+		{"/draw-offer", fakeBlackCookie(), http.StatusOK},
+		{"/draw-offer/accept", fakeWhiteCookie(), http.StatusOK},
+	} {
+		req, _ := http.NewRequest("POST", tc.path, nil)
+		req.AddCookie(tc.cookie)
+		err := handleReqCheckEventStream(gh, evpub, req, tc.status)
+		if err != nil {
+			t.Errorf("%s: %s", tc.path, err)
+		}
+	}
+	view := gh.gm.GetClientView()
+	if view.Status != "DRAW" || view.DrawReason != "AGREEMENT" {
+		t.Errorf("expected a draw by agreement, got %s (%s)", view.Status,
+			view.DrawReason)
+	}
+}
+
+func TestPostDrawClaim(t *testing.T) {
+	evpub, chpub := GetTestPublishers()
+	config := game.Config{
+		TimeControl:        1 * time.Minute,
+		NoCaptureDrawMoves: 20,
+		NoCaptureDrawClaim: true,
+	}
+	gh, _ := newGameHandler(nil, *chpub, config, fakePlayers(), game.RealClock)
+
+	req, _ := http.NewRequest("POST", "/draw-claim", nil)
+	req.AddCookie(fakeWhiteCookie())
+	err := handleReqCheckEventStream(gh, evpub, req, http.StatusBadRequest)
+	if err != nil {
+		t.Error(err)
+	}
+	if gh.gm.GetClientView().Status != "ONGOING" {
+		t.Error("expected a claim before any moves to be refused")
+	}
+}
+
+func TestPostStaleMove(t *testing.T) {
+	evpub, chpub := GetTestPublishers()
+	gh, _ := newGameHandler(
+		nil, *chpub, game.Config{TimeControl: time.Minute}, fakePlayers(),
+		game.RealClock)
+
+	// Black's move was made before white's landed.
+	for _, m := range []struct {
+		body   string
+		cookie *http.Cookie
+		status int
+	}{
+		{`{"x": 0, "y": 0, "d": "DOWN", "expectedPly": 0}`, fakeWhiteCookie(),
+			http.StatusOK},
+		{`{"x": 6, "y": 0, "d": "DOWN", "expectedPly": 0}`, fakeBlackCookie(),
+			http.StatusConflict},
+	} {
+		req, _ := http.NewRequest("POST", "/move", strings.NewReader(m.body))
+		req.AddCookie(m.cookie)
+		err := handleReqCheckEventStream(gh, evpub, req, m.status)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestPremoves(t *testing.T) {
+	evpub, chpub := GetTestPublishers()
+	gh, _ := newGameHandler(
+		nil, *chpub, game.Config{TimeControl: time.Minute}, fakePlayers(),
+		game.RealClock)
+	for _, m := range []struct {
+		move   game.Move
+		cookie *http.Cookie
+	}{
+		{game.Move{X: 0, Y: 0, D: game.DirDown}, fakeWhiteCookie()},
+		{game.Move{X: 6, Y: 0, D: game.DirDown}, fakeBlackCookie()},
+	} {
+		if err := gh.gm.TryMove(m.move, m.cookie); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Queueing a premove doesn't change anything anybody else can see.
+	b, _ := json.Marshal(game.Move{X: 5, Y: 0, D: game.DirLeft})
+	req, _ := http.NewRequest("POST", "/premove", bytes.NewReader(b))
+	req.AddCookie(fakeBlackCookie())
+	rr := httptest.NewRecorder()
+	gh.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected code %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/premove", nil)
+	req.AddCookie(fakeBlackCookie())
+	rr = httptest.NewRecorder()
+	gh.ServeHTTP(rr, req)
+	var premoves struct {
+		Moves []game.Move `json:"moves"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&premoves); err != nil {
+		t.Fatal(err)
+	}
+	if len(premoves.Moves) != 1 {
+		t.Errorf("expected one queued premove, got %+v", premoves)
+	}
+
+	b, _ = json.Marshal(game.Move{X: 1, Y: 0, D: game.DirRight})
+	req, _ = http.NewRequest("POST", "/move", bytes.NewReader(b))
+	req.AddCookie(fakeWhiteCookie())
+	err := handleReqCheckEventStream(gh, evpub, req, http.StatusOK)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(gh.gm.GetClientView().History) != 5 {
+		t.Error("expected black's premove to be played")
+	}
+
+	// It's white's turn, so their premove is just played.
+	b, _ = json.Marshal(game.Move{X: 2, Y: 0, D: game.DirLeft})
+	req, _ = http.NewRequest("POST", "/premove", bytes.NewReader(b))
+	req.AddCookie(fakeWhiteCookie())
+	err = handleReqCheckEventStream(gh, evpub, req, http.StatusOK)
+	if err != nil {
+		t.Error(err)
+	}
+
+	req, _ = http.NewRequest("POST", "/premove/clear", nil)
+	rr = httptest.NewRecorder()
+	gh.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected code %d, got %d", http.StatusUnauthorized, rr.Code)
+	}
+}
+
+func TestConditionalMoves(t *testing.T) {
+	evpub, chpub := GetTestPublishers()
+	gh, _ := newGameHandler(
+		nil, *chpub, game.Config{DaysPerMove: 3}, fakePlayers(),
+		game.RealClock)
+	saves := 0
+	gh.onSave = func() { saves++ }
+	for _, m := range []struct {
+		move   game.Move
+		cookie *http.Cookie
+	}{
+		{game.Move{X: 0, Y: 0, D: game.DirDown}, fakeWhiteCookie()},
+		{game.Move{X: 6, Y: 0, D: game.DirDown}, fakeBlackCookie()},
+	} {
+		if err := gh.gm.TryMove(m.move, m.cookie); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send := func(method string, body string, c *http.Cookie) int {
+		req, _ := http.NewRequest(method, "/conditional-moves",
+			strings.NewReader(body))
+		if c != nil {
+			req.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		gh.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	tree := `[{"if": {"x": 1, "y": 0, "d": "RIGHT"},
+	           "reply": {"x": 5, "y": 0, "d": "LEFT"}}]`
+	if code := send("PUT", tree, fakeBlackCookie()); code != http.StatusOK {
+		t.Fatalf("expected code %d, got %d", http.StatusOK, code)
+	}
+	if saves != 1 {
+		t.Errorf("expected the conditional moves to be saved, got %d saves",
+			saves)
+	}
+	if code := send("PUT", tree, nil); code != http.StatusUnauthorized {
+		t.Errorf("expected code %d, got %d", http.StatusUnauthorized, code)
+	}
+
+	// White's move is answered straight away, in the same update.
+	b, _ := json.Marshal(game.Move{X: 1, Y: 0, D: game.DirRight})
+	req, _ := http.NewRequest("POST", "/move", bytes.NewReader(b))
+	req.AddCookie(fakeWhiteCookie())
+	err := handleReqCheckEventStream(gh, evpub, req, http.StatusOK)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(gh.gm.GetClientView().History) != 5 {
+		t.Error("expected black's conditional move to be played")
+	}
+
+	req, _ = http.NewRequest("GET", "/conditional-moves", nil)
+	req.AddCookie(fakeBlackCookie())
+	rr := httptest.NewRecorder()
+	gh.ServeHTTP(rr, req)
+	if body := strings.TrimSpace(rr.Body.String()); body != "[]" {
+		t.Errorf("expected no conditional moves left, got %s", body)
+	}
+	saves = 0
+	if code := send("DELETE", "", fakeBlackCookie()); code != http.StatusOK {
+		t.Errorf("expected code %d, got %d", http.StatusOK, code)
+	}
+	if saves != 1 {
+		t.Errorf("expected the deletion to be saved, got %d saves", saves)
+	}
+}
+
+func TestPostPingAndAbandonmentClaim(t *testing.T) {
+	evpub, chpub := GetTestPublishers()
+	clock := game.NewFakeClock(time.Now())
+	gh, _ := newGameHandler(
+		nil, *chpub, game.Config{TimeControl: time.Hour}, fakePlayers(), clock)
+	for _, m := range []struct {
+		move   game.Move
+		cookie *http.Cookie
+	}{
+		{game.Move{X: 0, Y: 0, D: game.DirDown}, fakeWhiteCookie()},
+		{game.Move{X: 6, Y: 0, D: game.DirDown}, fakeBlackCookie()},
+	} {
+		if err := gh.gm.TryMove(m.move, m.cookie); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	claim := func(status int) {
+		body := strings.NewReader(`{"claim": "WIN"}`)
+		req, _ := http.NewRequest("POST", "/abandonment-claim", body)
+		req.AddCookie(fakeWhiteCookie())
+		err := handleReqCheckEventStream(gh, evpub, req, status)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	claim(http.StatusBadRequest)
+
+	// Black never pings, so white's ping after the grace period tells
+	// everybody black is gone.
+	clock.Advance(time.Minute)
+	req, _ := http.NewRequest("POST", "/ping", nil)
+	req.AddCookie(fakeWhiteCookie())
+	err := handleReqCheckEventStream(gh, evpub, req, http.StatusOK)
+	if err != nil {
+		t.Error(err)
+	}
+	if gh.gm.GetClientView().ColorToPlayer["BLACK"].Connected {
+		t.Error("expected black to be disconnected")
+	}
+
+	claim(http.StatusOK)
+	view := gh.gm.GetClientView()
+	if view.Status != "WHITE_WON" || view.Termination != "ABANDONMENT" {
+		t.Errorf("expected white to win by abandonment, got %s (%s)",
+			view.Status, view.Termination)
+	}
+}
+
+func TestPostTakebackRequest(t *testing.T) {
+	evpub, chpub := GetTestPublishers()
+	config := game.Config{TimeControl: 1 * time.Minute, AllowTakebacks: true}
+	gh, _ := newGameHandler(nil, *chpub, config, fakePlayers(), game.RealClock)
+
+	for _, m := range []struct {
+		move   game.Move
+		cookie *http.Cookie
+	}{
+		{game.Move{X: 0, Y: 0, D: game.DirDown}, fakeWhiteCookie()},
+		{game.Move{X: 6, Y: 0, D: game.DirDown}, fakeBlackCookie()},
+		{game.Move{X: 1, Y: 0, D: game.DirRight}, fakeWhiteCookie()},
+	} {
+		if err := gh.gm.TryMove(m.move, m.cookie); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		path   string
+		cookie *http.Cookie
+		status int
+	}{
+		{"/takeback-request/accept", fakeBlackCookie(), http.StatusBadRequest},
+		{"/takeback-request", fakeBlackCookie(), http.StatusBadRequest},
+		{"/takeback-request", fakeWhiteCookie(), http.StatusOK},
+		{"/takeback-request/accept", fakeWhiteCookie(), http.StatusBadRequest},
+		{"/takeback-request/decline", fakeBlackCookie(), http.StatusOK},
+		{"/takeback-request", fakeWhiteCookie(), http.StatusBadRequest},
+	} {
+		req, _ := http.NewRequest("POST", tc.path, nil)
+		req.AddCookie(tc.cookie)
+		err := handleReqCheckEventStream(gh, evpub, req, tc.status)
+		if err != nil {
+			t.Errorf("%s: %s", tc.path, err)
+		}
+	}
+	if len(gh.gm.GetClientView().History) != 4 {
+		t.Error("expected a declined takeback not to take back any moves")
+	}
+
+	move := game.Move{X: 5, Y: 0, D: game.DirLeft}
+	if err := gh.gm.TryMove(move, fakeBlackCookie()); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		path   string
+		cookie *http.Cookie
+	}{
+		{"/takeback-request", fakeWhiteCookie()},
+		{"/takeback-request/accept", fakeBlackCookie()},
+	} {
+		req, _ := http.NewRequest("POST", tc.path, nil)
+		req.AddCookie(tc.cookie)
+		err := handleReqCheckEventStream(gh, evpub, req, http.StatusOK)
+		if err != nil {
+			t.Errorf("%s: %s", tc.path, err)
+		}
+	}
+	if len(gh.gm.GetClientView().History) != 3 {
+		t.Error("expected white's move and black's reply to be taken back")
+	}
+}
+
 func TestPostRematchOffer(t *testing.T) {
   evpub, chpub := GetTestPublishers()
 	gh, _ := newGameHandler(
-		nil, *chpub, game.Config{TimeControl: 1 * time.Minute}, fakeWhiteCookie(),
-		fakeBlackCookie())
+		nil, *chpub, game.Config{TimeControl: 1 * time.Minute}, fakePlayers(),
+		game.RealClock)
 
   // end the game
 	req, err := http.NewRequest("POST", "/resignation", nil)
@@ -294,11 +795,55 @@ func TestPostRematchOffer(t *testing.T) {
 	}
 }
 
+func TestPostRematchProposal(t *testing.T) {
+	evpub, chpub := GetTestPublishers()
+	gh, _ := newGameHandler(
+		nil, *chpub, game.Config{TimeControl: 1 * time.Minute}, fakePlayers(),
+		game.RealClock)
+
+	req, _ := http.NewRequest("POST", "/resignation", nil)
+	req.AddCookie(fakeWhiteCookie())
+	err := handleReqCheckEventStream(gh, evpub, req, http.StatusOK)
+	if err != nil {
+		t.Error(err)
+	}
+
+	body := `{"config":{"timeControlNs":180000000000},"keepColors":true}`
+	req, _ = http.NewRequest("POST", "/rematch-offer", strings.NewReader(body))
+	req.AddCookie(fakeWhiteCookie())
+	err = handleReqCheckEventStream(gh, evpub, req, http.StatusOK)
+	if err != nil {
+		t.Error(err)
+	}
+
+	req, _ = http.NewRequest("POST", "/rematch-offer/decline", nil)
+	req.AddCookie(fakeBlackCookie())
+	err = handleReqCheckEventStream(gh, evpub, req, http.StatusOK)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Nothing is left to accept once black has declined.
+	req, _ = http.NewRequest("POST", "/rematch-offer/accept", nil)
+	req.AddCookie(fakeBlackCookie())
+	err = handleReqCheckEventStream(gh, evpub, req, http.StatusBadRequest)
+	if err != nil {
+		t.Error(err)
+	}
+
+	req, _ = http.NewRequest("POST", "/rematch-offer", strings.NewReader("{"))
+	req.AddCookie(fakeBlackCookie())
+	err = handleReqCheckEventStream(gh, evpub, req, http.StatusBadRequest)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
 func TestPostRematchOfferNoCookie(t *testing.T) {
   evpub, chpub := GetTestPublishers()
 	gh, _ := newGameHandler(
-		nil, *chpub, game.Config{TimeControl: 1 * time.Minute}, fakeWhiteCookie(),
-		fakeBlackCookie())
+		nil, *chpub, game.Config{TimeControl: 1 * time.Minute}, fakePlayers(),
+		game.RealClock)
 
 	req, err := http.NewRequest("POST", "/resignation", nil)
 	req.AddCookie(fakeWhiteCookie())
@@ -14,7 +14,15 @@ import (
 )
 
 type createGameFnT func(
-	deleteChallengeFn, game.Config, *http.Cookie, *http.Cookie) (*url.URL, error)
+	deleteChallengeFn, game.Config, []*http.Cookie) (*url.URL, error)
+
+// Called whenever it becomes a player's turn in a correspondence game, e.g. to
+// send them an email.
+type YourMoveHook func(gamePath *url.URL, playerID string)
+
+// Finished correspondence games are kept around for longer, since players
+// only check in every so often.
+const correspondenceRetention = 7 * 24 * time.Hour
 
 type gameRouter struct {
 	router     *httprouter.Router
@@ -23,20 +31,29 @@ type gameRouter struct {
 	urlBase    *url.URL
 	mutex      sync.RWMutex
   evpub      evtpub.EventPublisher
+	// Both optional; only used for correspondence games.
+	store      *gameStore
+	onYourMove YourMoveHook
+	clock      game.Clock
 }
 
-func newGameRouter(urlBase *url.URL, evpub evtpub.EventPublisher) *gameRouter {
+func newGameRouter(
+	urlBase *url.URL, evpub evtpub.EventPublisher,
+	clock game.Clock) *gameRouter {
 	gr := gameRouter{
 		router:  httprouter.New(),
 		games:   make(map[string]*gameHandler),
 		pathGen: newNonCryptoStringGen(),
 		urlBase:  urlBase,
     evpub: evpub,
+		clock:   clock,
 	}
 
 	gr.router.GET("/:id", gr.forwardToHandler)
 	gr.router.GET("/:id/*etc", gr.forwardToHandler)
 	gr.router.POST("/:id/*etc", gr.forwardToHandler)
+	gr.router.PUT("/:id/*etc", gr.forwardToHandler)
+	gr.router.DELETE("/:id/*etc", gr.forwardToHandler)
 
 	return &gr
 }
@@ -73,7 +90,7 @@ func (gr *gameRouter) forwardToHandler(
 
 func (gr *gameRouter) addGame(
 	deleteChallengeCb deleteChallengeFn, config game.Config,
-	cookie1, cookie2 *http.Cookie) (*url.URL, error) {
+	players []*http.Cookie) (*url.URL, error) {
 
 	gr.mutex.Lock()
 	defer gr.mutex.Unlock()
@@ -82,9 +99,14 @@ func (gr *gameRouter) addGame(
 		return nil, errors.New("Too many games in play; try again later.")
 	}
 
-	// Randomize who plays white
-	if mrand.Intn(2) == 0 {
-		cookie1, cookie2 = cookie2, cookie1
+	// Randomize who plays white (and who sits where in team games). Handicaps
+	// are given to a color, so in handicap games the challenge's creator
+	// always plays white.
+	players = append([]*http.Cookie(nil), players...)
+	if config.Handicap == nil {
+		mrand.Shuffle(len(players), func(i, j int) {
+			players[i], players[j] = players[j], players[i]
+		})
 	}
 
 	id := gr.pathGen.newString(8)
@@ -94,20 +116,78 @@ func (gr *gameRouter) addGame(
     return nil, err
   }
 	game, err :=
-    newGameHandler(deleteChallengeCb, *chpub, config, cookie1, cookie2)
+    newGameHandler(deleteChallengeCb, *chpub, config, players, gr.clock)
 	if err != nil {
 		return nil, err
 	}
 	gr.games[id] = game
+	gr.watchCorrespondenceGame(id, fullPath, game)
 
 	log.Print("Created game " + id + ".")
 
 	return fullPath, nil
 }
 
+// Hooks a correspondence game up to the store and the "your move" hook.
+func (gr *gameRouter) watchCorrespondenceGame(
+	id string, fullPath *url.URL, gh *gameHandler) {
+	if !gh.isCorrespondence() {
+		return
+	}
+	if gr.store != nil {
+		gh.onSave = func() {
+			if err := gr.store.save(id, gh.gm.Save()); err != nil {
+				log.Print("Couldn't save game " + id + ": " + err.Error())
+			}
+		}
+		gh.onSave()
+	}
+	if gr.onYourMove != nil {
+		gh.onYourMove = func(playerID string) {
+			gr.onYourMove(fullPath, playerID)
+		}
+		gh.notifyYourMove()
+	}
+}
+
+// Starts storing correspondence games in dir and brings back the ones stored
+// there already.
+func (gr *gameRouter) persistCorrespondenceGames(dir string) error {
+	store, err := newGameStore(dir)
+	if err != nil {
+		return err
+	}
+	saved, err := store.loadAll()
+	if err != nil {
+		return err
+	}
+
+	gr.mutex.Lock()
+	defer gr.mutex.Unlock()
+	gr.store = store
+	restored := 0
+	for id, s := range saved {
+		fullPath := gr.urlBase.JoinPath(id)
+		chpub, err := gr.evpub.NewChannelPublisher(fullPath.String())
+		if err != nil {
+			return err
+		}
+		gh, err := newRestoredGameHandler(nil, *chpub, s, gr.clock)
+		if err != nil {
+			log.Print("Couldn't restore game " + id + ": " + err.Error())
+			continue
+		}
+		gr.games[id] = gh
+		gr.watchCorrespondenceGame(id, fullPath, gh)
+		restored++
+	}
+	log.Printf("Restored %d correspondence games.", restored)
+	return nil
+}
+
 func (gr *gameRouter) PeriodicallyDeleteGamesOlderThan(d time.Duration) {
 	for {
-		time.Sleep(d)
+		gr.clock.Sleep(d)
 		gr.deleteGamesOlderThan(d)
 	}
 }
@@ -122,7 +202,11 @@ func (gr *gameRouter) deleteGamesOlderThan(d time.Duration) {
 		if actual == nil {
 			continue
 		}
-		if *actual > d {
+		retention := d
+		if game.isCorrespondence() && retention < correspondenceRetention {
+			retention = correspondenceRetention
+		}
+		if *actual > retention {
 			gr.deleteGame(id)
 			count++
 		}
@@ -136,4 +220,9 @@ func (gr *gameRouter) deleteGamesOlderThan(d time.Duration) {
 func (gr *gameRouter) deleteGame(id string) {
   gr.games[id].TearDown()
 	delete(gr.games, id)
+	if gr.store != nil {
+		if err := gr.store.remove(id); err != nil {
+			log.Print("Couldn't remove saved game " + id + ": " + err.Error())
+		}
+	}
 }
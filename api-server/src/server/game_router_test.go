@@ -1,9 +1,12 @@
 package server
 
 import (
+	"encoding/json"
 	"game"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
   "net/url"
@@ -14,7 +17,7 @@ func TestNewGameRouter(t *testing.T) {
 	var _ http.Handler = (*gameRouter)(nil)
 
   urlBase, _ := url.Parse("/")
-	gr := newGameRouter(urlBase, evtpub.NewMockEventPublisher())
+	gr := newGameRouter(urlBase, evtpub.NewMockEventPublisher(), game.RealClock)
 	if gr == nil {
 		t.Error("gameRouter was nil")
 	}
@@ -22,11 +25,11 @@ func TestNewGameRouter(t *testing.T) {
 
 func TestAddGame(t *testing.T) {
   urlBase, _ := url.Parse("/")
-	gr := newGameRouter(urlBase, evtpub.NewMockEventPublisher())
+	gr := newGameRouter(urlBase, evtpub.NewMockEventPublisher(), game.RealClock)
 
 	_, err := gr.addGame(
 		func() {}, game.Config{TimeControl: 1 * time.Minute},
-		fakeWhiteCookie(), fakeBlackCookie())
+		fakePlayers())
 	if err != nil {
 		t.Error(err)
 	}
@@ -36,14 +39,37 @@ func TestAddGame(t *testing.T) {
 	}
 }
 
+func TestAddHandicapGameSeatsCreatorAsWhite(t *testing.T) {
+  urlBase, _ := url.Parse("/")
+	gr := newGameRouter(urlBase, evtpub.NewMockEventPublisher(), game.RealClock)
+
+	var handicap game.Handicap
+	err := json.Unmarshal(
+		[]byte(`{"receiver": "BLACK", "scoreBonus": 1}`), &handicap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := game.Config{TimeControl: 1 * time.Minute, Handicap: &handicap}
+	for i := 0; i < 10; i++ {
+		path, err := gr.addGame(func() {}, config, fakePlayers())
+		if err != nil {
+			t.Fatal(err)
+		}
+		gh := gr.games[path.String()[1:]]
+		if gh.gm.GetWhiteCookie().Name != fakeWhiteCookie().Name {
+			t.Fatal("expected the challenge's creator to play white")
+		}
+	}
+}
+
 func makeRouterWithTestGame() (*gameRouter, error) {
   urlBase, _ := url.Parse("/")
   evpub, chpub := GetTestPublishers()
-	gr := newGameRouter(urlBase, evpub)
+	gr := newGameRouter(urlBase, evpub, game.RealClock)
 
 	game, err := newGameHandler(
 		func() {}, *chpub, game.Config{TimeControl: 1 * time.Minute},
-    fakeWhiteCookie(), fakeBlackCookie())
+    fakePlayers(), game.RealClock)
 	if err != nil {
 		return nil, err
 	}
@@ -71,23 +97,27 @@ func TestHandleGameRequestForwarding(t *testing.T) {
 func TestDeleteGamesOlderThan(t *testing.T) {
   evpub := evtpub.NewMockEventPublisher()
   urlBase, _ := url.Parse("/")
-	gr := newGameRouter(urlBase, evpub)
+	clock := game.NewFakeClock(time.Now())
+	gr := newGameRouter(urlBase, evpub, clock)
 
-	tTooOld := time.Now().Add(-1 * time.Hour)
+	tTooOld := clock.Now().Add(-1 * time.Hour)
   chpubTooOld, _ := evpub.NewChannelPublisher("too/old")
 	gr.games["too old"] = &gameHandler{
 		completionTime: &tTooOld,
+		clock:          clock,
     channelPub: *chpubTooOld,
 	}
-	tNotTooOld := time.Now().Add(-1 * time.Minute)
+	tNotTooOld := clock.Now().Add(-1 * time.Minute)
   chpubNotTooOld, _ := evpub.NewChannelPublisher("not/too/old")
 	gr.games["not too old"] = &gameHandler{
 		completionTime: &tNotTooOld,
+		clock:          clock,
     channelPub: *chpubNotTooOld,
 	}
   chpubIncomplete, _ := evpub.NewChannelPublisher("incomplete")
 	gr.games["incomplete"] = &gameHandler{
 		completionTime: nil,
+		clock:          clock,
     channelPub: *chpubIncomplete,
 	}
 
@@ -131,12 +161,12 @@ func TestLongLivedRequestDoesntBlockMutex(t *testing.T) {
 
 func TestGameNumberLimit(t *testing.T) {
   urlBase, _ := url.Parse("/")
-	gr := newGameRouter(urlBase, evtpub.NewMockEventPublisher())
+	gr := newGameRouter(urlBase, evtpub.NewMockEventPublisher(), game.RealClock)
 
   for i := 0; i < 100; i++ {
     _, err := gr.addGame(
       func() {}, game.Config{TimeControl: 1 * time.Minute},
-      fakeWhiteCookie(), fakeBlackCookie())
+      fakePlayers())
     if err != nil {
       t.Error(err)
     }
@@ -144,7 +174,7 @@ func TestGameNumberLimit(t *testing.T) {
 
   empty, err := gr.addGame(
     func() {}, game.Config{TimeControl: 1 * time.Minute},
-    fakeWhiteCookie(), fakeBlackCookie())
+    fakePlayers())
   if err == nil {
     t.Error("expected 101st game add to fail")
   }
@@ -156,3 +186,55 @@ func TestGameNumberLimit(t *testing.T) {
 		t.Error("expected number of games to equal 100")
 	}
 }
+
+func TestPersistCorrespondenceGames(t *testing.T) {
+	dir := t.TempDir()
+	urlBase, _ := url.Parse("/")
+	gr := newGameRouter(urlBase, evtpub.NewMockEventPublisher(), game.RealClock)
+	if err := gr.persistCorrespondenceGames(dir); err != nil {
+		t.Fatal(err)
+	}
+	var notified []string
+	gr.onYourMove = func(gamePath *url.URL, playerID string) {
+		notified = append(notified, playerID)
+	}
+
+	// Live games aren't saved.
+	_, err := gr.addGame(
+		func() {}, game.Config{TimeControl: time.Minute}, fakePlayers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := gr.addGame(
+		func() {}, game.Config{DaysPerMove: 1}, fakePlayers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := path.String()[1:]
+	defer gr.games[id].TearDown()
+	if len(notified) != 1 {
+		t.Errorf("expected one notification, got %v", notified)
+	}
+
+	restarted := newGameRouter(urlBase, evtpub.NewMockEventPublisher(),
+		game.RealClock)
+	if err := restarted.persistCorrespondenceGames(dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(restarted.games) != 1 {
+		t.Fatalf("expected 1 restored game, got %d", len(restarted.games))
+	}
+	gh, ok := restarted.games[id]
+	if !ok {
+		t.Fatal("expected the game to keep its ID")
+	}
+	defer gh.TearDown()
+	if gh.gm.GetWhiteCookie().Value != gr.games[id].gm.GetWhiteCookie().Value {
+		t.Error("expected the players to be restored")
+	}
+
+	restarted.deleteGame(id)
+	if _, err := os.Stat(filepath.Join(dir, id+".json")); err == nil {
+		t.Error("expected the saved game to be removed")
+	}
+}
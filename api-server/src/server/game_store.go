@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"game"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Keeps correspondence games on disk (one JSON file per game) so that they
+// survive server restarts.
+type gameStore struct {
+	dir   string
+	mutex sync.Mutex
+}
+
+func newGameStore(dir string) (*gameStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &gameStore{dir: dir}, nil
+}
+
+func (st *gameStore) path(id string) string {
+	return filepath.Join(st.dir, id+".json")
+}
+
+func (st *gameStore) save(id string, saved game.SavedGame) error {
+	b, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	// Write to a temporary file first so a crash can't leave a half written
+	// game behind.
+	tmp := st.path(id) + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, st.path(id))
+}
+
+func (st *gameStore) remove(id string) error {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	err := os.Remove(st.path(id))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Returns every stored game by ID.
+func (st *gameStore) loadAll() (map[string]game.SavedGame, error) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	entries, err := os.ReadDir(st.dir)
+	if err != nil {
+		return nil, err
+	}
+	games := make(map[string]game.SavedGame)
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(st.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var saved game.SavedGame
+		if err := json.Unmarshal(b, &saved); err != nil {
+			return nil, err
+		}
+		games[id] = saved
+	}
+	return games, nil
+}
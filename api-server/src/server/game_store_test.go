@@ -0,0 +1,50 @@
+package server
+
+import (
+	"game"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGameStoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	st, err := newGameStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := game.SavedGame{
+		Config: game.Config{DaysPerMove: 3},
+		Status: "ONGOING",
+	}
+	if err := st.save("abc", saved); err != nil {
+		t.Fatal(err)
+	}
+	// Anything else in the directory is ignored.
+	err = os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hi"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	games, err := st.loadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 1 || games["abc"].Config.DaysPerMove != 3 {
+		t.Errorf("unexpected games %v", games)
+	}
+
+	if err := st.remove("abc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.remove("abc"); err != nil {
+		t.Error("expected removing a missing game to succeed")
+	}
+	games, err = st.loadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 0 {
+		t.Errorf("expected no games, got %v", games)
+	}
+}
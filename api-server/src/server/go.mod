@@ -7,6 +7,7 @@ replace game => ../game
 replace evtpub => ./event-publisher
 
 require (
+	evtpub v0.0.0-00010101000000-000000000000
 	game v0.0.0-00010101000000-000000000000
 	github.com/antoniovleonti/sse v0.0.0-20230904230022-1b089e02c02c
 	github.com/julienschmidt/httprouter v1.3.0
@@ -14,7 +15,6 @@ require (
 )
 
 require (
-	evtpub v0.0.0-00010101000000-000000000000 // indirect
 	golang.org/x/net v0.14.0 // indirect
 	gopkg.in/cenkalti/backoff.v1 v1.1.0 // indirect
 )
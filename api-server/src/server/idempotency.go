@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// How many responses each game remembers.
+const maxIdempotentResponses = 256
+
+// Remembers the responses to POSTs sent with an Idempotency-Key header, so
+// that retries get the original response back rather than being handled
+// again. Keys are scoped to the player (going by their cookie) and the path.
+type idempotencyCache struct {
+	mutex     sync.Mutex
+	responses map[string]*idempotentResponse
+	// Keys, oldest first, for forgetting responses once there are too many.
+	order []string
+}
+
+type idempotentResponse struct {
+	// Closed once the original request has been handled, or has given up.
+	done chan struct{}
+	// False if the original request never got to finish, e.g. because its
+	// handler panicked, in which case there's nothing to replay.
+	finished bool
+	// Of the original request's body, to catch keys reused for another
+	// request.
+	bodyHash [sha256.Size]byte
+	status   int
+	header   http.Header
+	body     []byte
+}
+
+// Passes writes through while keeping a copy of them.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (ic *idempotencyCache) serve(
+	next http.Handler, w http.ResponseWriter, r *http.Request, key string) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Could not read request: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	scope := r.URL.Path + " " + key
+	if c := r.Cookies(); len(c) > 0 {
+		scope = c[0].Name + "=" + c[0].Value + " " + scope
+	}
+
+	ic.mutex.Lock()
+	if ic.responses == nil {
+		ic.responses = make(map[string]*idempotentResponse)
+	}
+	if original, ok := ic.responses[scope]; ok {
+		ic.mutex.Unlock()
+		<-original.done
+		if !original.finished {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			ic.serve(next, w, r, key)
+			return
+		}
+		if original.bodyHash != sha256.Sum256(body) {
+			http.Error(w, "Idempotency-Key was used for a different request.",
+				http.StatusUnprocessableEntity)
+			return
+		}
+		for k, v := range original.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(original.status)
+		w.Write(original.body)
+		return
+	}
+	response := &idempotentResponse{
+		done:     make(chan struct{}),
+		bodyHash: sha256.Sum256(body),
+	}
+	ic.responses[scope] = response
+	ic.order = append(ic.order, scope)
+	if len(ic.order) > maxIdempotentResponses {
+		delete(ic.responses, ic.order[0])
+		ic.order = ic.order[1:]
+	}
+	ic.mutex.Unlock()
+	defer func() {
+		if !response.finished {
+			ic.forget(scope, response)
+		}
+		close(response.done)
+	}()
+
+	rec := recordingWriter{ResponseWriter: w, status: http.StatusOK}
+	next.ServeHTTP(&rec, r)
+	response.status = rec.status
+	response.header = w.Header().Clone()
+	response.body = rec.body.Bytes()
+	response.finished = true
+}
+
+// Drops a response that never finished, so that retries are handled afresh.
+func (ic *idempotencyCache) forget(
+	scope string, response *idempotentResponse) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	if ic.responses[scope] != response {
+		return
+	}
+	delete(ic.responses, scope)
+	for i, s := range ic.order {
+		if s == scope {
+			ic.order = append(ic.order[:i], ic.order[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"game"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIdempotentMoves(t *testing.T) {
+	evpub, chpub := GetTestPublishers()
+	gh, _ := newGameHandler(
+		nil, *chpub, game.Config{TimeControl: time.Minute}, fakePlayers(),
+		game.NewFakeClock(time.Now()))
+
+	post := func(m game.Move, c *http.Cookie, key string) int {
+		b, _ := json.Marshal(m)
+		req, _ := http.NewRequest("POST", "/move", bytes.NewReader(b))
+		req.AddCookie(c)
+		req.Header.Set("Idempotency-Key", key)
+		rr := httptest.NewRecorder()
+		gh.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	pushes := func() int {
+		return len(evpub.Channels[testChannelPath].Pushes)
+	}
+
+	white := game.Move{X: 0, Y: 0, D: game.DirDown}
+	if code := post(white, fakeWhiteCookie(), "1"); code != http.StatusOK {
+		t.Fatalf("expected code %d, got %d", http.StatusOK, code)
+	}
+	before := pushes()
+	if code := post(white, fakeWhiteCookie(), "1"); code != http.StatusOK {
+		t.Errorf("expected the retry to succeed too, got %d", code)
+	}
+	if pushes() != before || len(gh.gm.GetClientView().History) != 2 {
+		t.Error("expected the retry not to be handled again")
+	}
+
+	other := game.Move{X: 6, Y: 0, D: game.DirDown}
+	code := post(other, fakeWhiteCookie(), "1")
+	if code != http.StatusUnprocessableEntity {
+		t.Errorf("expected a reused key to be refused, got %d", code)
+	}
+	// Keys are per player.
+	if code := post(other, fakeBlackCookie(), "1"); code != http.StatusOK {
+		t.Errorf("expected black's move to be played, got %d", code)
+	}
+
+	// Failures are remembered as well.
+	bad := game.Move{X: 3, Y: 3, D: game.DirDown}
+	for i := 0; i < 2; i++ {
+		code := post(bad, fakeWhiteCookie(), "2")
+		if code != http.StatusBadRequest {
+			t.Errorf("expected code %d, got %d", http.StatusBadRequest, code)
+		}
+	}
+}
+
+func TestIdempotentPanicIsForgotten(t *testing.T) {
+	var ic idempotencyCache
+	calls := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			panic("handler failed")
+		}
+		w.Write([]byte("success"))
+	})
+	serve := func() (rr *httptest.ResponseRecorder, panicked bool) {
+		defer func() { panicked = recover() != nil }()
+		req, _ := http.NewRequest("POST", "/move", bytes.NewReader(nil))
+		req.AddCookie(fakeWhiteCookie())
+		rr = httptest.NewRecorder()
+		ic.serve(handler, rr, req, "1")
+		return rr, false
+	}
+
+	if _, panicked := serve(); !panicked {
+		t.Fatal("expected the handler to panic")
+	}
+	rr, _ := serve()
+	if calls != 2 || rr.Body.String() != "success" {
+		t.Errorf("expected the retry to be handled afresh, got %q after %d calls",
+			rr.Body.String(), calls)
+	}
+	if len(ic.responses) != 1 || len(ic.order) != 1 {
+		t.Errorf("expected only the retry to be remembered")
+	}
+}
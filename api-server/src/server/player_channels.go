@@ -0,0 +1,114 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"evtpub"
+	"game"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"sync"
+)
+
+// Each player gets their own event channel for state pushes, since players
+// don't all see the same game (e.g. in hidden information variants). Channels
+// are named by an unguessable ID handed out only to the player, because
+// subscribing needs nothing but the channel's path.
+type playerChannels struct {
+	mutex sync.Mutex
+	// Keyed by the player's cookie.
+	channels map[string]*playerChannel
+}
+
+type playerChannel struct {
+	cookie *http.Cookie
+	path   string
+	pub    *evtpub.ChannelPublisher
+}
+
+// Where to subscribe to a player's pushes, relative to the game's path.
+type eventChannel struct {
+	Path string `json:"path"`
+}
+
+func newChannelID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// The player's channel, created the first time they ask for it.
+func (pc *playerChannels) channelFor(
+	parent *evtpub.ChannelPublisher, c *http.Cookie) (string, error) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	key := c.Name + "=" + c.Value
+	if channel, ok := pc.channels[key]; ok {
+		return channel.path, nil
+	}
+	path := "players/" + newChannelID()
+	pub, err := parent.NewSubchannel(path)
+	if err != nil {
+		return "", err
+	}
+	if pc.channels == nil {
+		pc.channels = make(map[string]*playerChannel)
+	}
+	pc.channels[key] = &playerChannel{cookie: c, path: path, pub: pub}
+	return path, nil
+}
+
+// Pushes each player the game as they're allowed to see it.
+func (pc *playerChannels) push(gm *game.GameManager) error {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	for _, channel := range pc.channels {
+		b, err := json.Marshal(gm.GetClientViewFor(channel.cookie))
+		if err != nil {
+			return err
+		}
+		if err := channel.pub.Push("state-push", string(b)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pc *playerChannels) deleteAll() {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	for _, channel := range pc.channels {
+		channel.pub.Delete()
+	}
+	pc.channels = nil
+}
+
+// Only players have a channel of their own; spectators subscribe to the
+// game's.
+func (gh *gameHandler) getEventChannel(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	c := r.Cookies()
+	if len(c) == 0 {
+		http.Error(w, "No cookies provided.", http.StatusUnauthorized)
+		return
+	}
+	if !gh.gm.IsPlayer(c[0]) {
+		http.Error(w, "Only players have their own event channel.",
+			http.StatusNotFound)
+		return
+	}
+	path, err := gh.playerChannels.channelFor(&gh.channelPub, c[0])
+	if err != nil {
+		http.Error(w, "Could not open event channel: "+err.Error(),
+			http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(eventChannel{Path: path})
+}
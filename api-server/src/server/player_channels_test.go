@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"game"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlayerChannelsDark(t *testing.T) {
+	evpub, chpub := GetTestPublishers()
+	gh, err := newGameHandler(
+		func() {}, *chpub,
+		game.Config{TimeControl: time.Minute, Variant: game.VariantDark},
+		fakePlayers(), game.NewFakeClock(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getChannel := func(c *http.Cookie, expectedStatus int) string {
+		req, _ := http.NewRequest("GET", "/event-channel", nil)
+		if c != nil {
+			req.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		gh.ServeHTTP(rr, req)
+		if rr.Code != expectedStatus {
+			t.Fatalf("expected code %d, got %d", expectedStatus, rr.Code)
+		}
+		var channel eventChannel
+		json.NewDecoder(rr.Body).Decode(&channel)
+		return channel.Path
+	}
+	white := getChannel(fakeWhiteCookie(), http.StatusOK)
+	black := getChannel(fakeBlackCookie(), http.StatusOK)
+	if white == "" || white == black {
+		t.Fatalf("expected separate channels, got %q and %q", white, black)
+	}
+	if again := getChannel(fakeWhiteCookie(), http.StatusOK); again != white {
+		t.Errorf("expected white to keep channel %q, got %q", white, again)
+	}
+	getChannel(&http.Cookie{Name: "spectator", Value: "x"},
+		http.StatusNotFound)
+
+	err = gh.gm.TryMove(game.Move{X: 0, Y: 0, D: game.DirDown},
+		fakeWhiteCookie())
+	if err != nil {
+		t.Fatal(err)
+	}
+	gh.publishUpdate()
+
+	lastPush := func(path string) string {
+		stream, ok := evpub.Channels[testChannelPath+"/"+path]
+		if !ok || len(stream.Pushes) == 0 {
+			t.Fatalf("expected a push to %s", path)
+		}
+		return stream.Pushes[len(stream.Pushes)-1].Data
+	}
+	// Each player only sees their own corners.
+	if push := lastPush(white); strings.Contains(push, `"B"`) {
+		t.Errorf("white was pushed black's marbles: %s", push)
+	}
+	if push := lastPush(black); strings.Contains(push, `"W"`) {
+		t.Errorf("black was pushed white's marbles: %s", push)
+	}
+
+	gh.TearDown()
+	if !evpub.Channels[testChannelPath+"/"+white].Deleted {
+		t.Error("expected the player channels to be deleted")
+	}
+}
@@ -3,6 +3,8 @@ package server
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/json"
+	"game"
 	"github.com/julienschmidt/httprouter"
 	"net/http"
 	"net/url"
@@ -16,9 +18,15 @@ type rootRouter struct {
 	gameRtr      *gameRouter
 	nameGen      *nonCryptoStringGen
   evPub     evtpub.EventPublisher
+	clock        game.Clock
 }
 
 func NewRootRouter(evPub evtpub.EventPublisher) *rootRouter {
+	return newRootRouter(evPub, game.RealClock)
+}
+
+// Tests pass a game.FakeClock.
+func newRootRouter(evPub evtpub.EventPublisher, clock game.Clock) *rootRouter {
   gameRtrURLBase, err := url.Parse("/games/")
   if err != nil {
     panic(err)
@@ -27,7 +35,8 @@ func NewRootRouter(evPub evtpub.EventPublisher) *rootRouter {
 		router:  httprouter.New(),
 		nameGen: newNonCryptoStringGen(),
     evPub:   evPub,
-		gameRtr: newGameRouter(gameRtrURLBase, evPub),
+		gameRtr: newGameRouter(gameRtrURLBase, evPub, clock),
+		clock:   clock,
 	}
 
   challengeRtrURLBase, err := url.Parse("/challenges/")
@@ -35,24 +44,40 @@ func NewRootRouter(evPub evtpub.EventPublisher) *rootRouter {
     panic(err)
   }
   rr.challengeRtr =
-    newChallengeRouter(challengeRtrURLBase, rr.gameRtr.addGame, evPub)
+    newChallengeRouter(
+      challengeRtrURLBase, rr.gameRtr.addGame, evPub, clock)
 
 	rr.router.GET("/games", rr.fwdToGameRouter)
 	rr.router.POST("/games", rr.fwdToGameRouter)
 	rr.router.GET("/games/*etc", rr.fwdToGameRouter)
 	rr.router.POST("/games/*etc", rr.fwdToGameRouter)
+	rr.router.PUT("/games/*etc", rr.fwdToGameRouter)
+	rr.router.DELETE("/games/*etc", rr.fwdToGameRouter)
 
 	rr.router.GET("/challenges", rr.fwdToChallengeRouter)
 	rr.router.POST("/challenges", rr.fwdToChallengeRouter)
 	rr.router.GET("/challenges/*etc", rr.fwdToChallengeRouter)
 	rr.router.POST("/challenges/*etc", rr.fwdToChallengeRouter)
 
+	rr.router.GET("/time", rr.getTime)
+
 	go rr.challengeRtr.PeriodicallyDeleteOldChallenges(10 * time.Minute)
 	go rr.gameRtr.PeriodicallyDeleteGamesOlderThan(10 * time.Minute)
 
 	return &rr
 }
 
+// Keeps correspondence games in dir so that they survive restarts, and brings
+// back the ones already there. Should be called before serving any requests.
+func (rr *rootRouter) PersistCorrespondenceGames(dir string) error {
+	return rr.gameRtr.persistCorrespondenceGames(dir)
+}
+
+// Should be called before serving any requests.
+func (rr *rootRouter) SetYourMoveHook(hook YourMoveHook) {
+	rr.gameRtr.onYourMove = hook
+}
+
 func (rr *rootRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// If request has come in with no cookie, set cookie in the response.
 	if len(r.Cookies()) == 0 {
@@ -67,6 +92,28 @@ func (rr *rootRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	rr.router.ServeHTTP(w, r)
 }
 
+// For clients to estimate how far off their clock is from the server's, NTP
+// style: offset = serverTimeMs - (sent + received) / 2.
+type serverTime struct {
+	ServerTime   time.Time `json:"serverTime"`
+	ServerTimeMs int64     `json:"serverTimeMs"`
+}
+
+func (rr *rootRouter) getTime(
+	w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	now := rr.clock.Now()
+	b, err := json.Marshal(serverTime{
+		ServerTime:   now,
+		ServerTimeMs: now.UnixMilli(),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(b)
+}
+
 func (rr *rootRouter) fwdToGameRouter(
 	w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	etc := httprouter.CleanPath(p.ByName("etc"))
@@ -37,6 +37,30 @@ func TestServeHTTPSetsCookie(t *testing.T) {
 	}
 }
 
+func TestGetTime(t *testing.T) {
+	clock := game.NewFakeClock(time.UnixMilli(1700000000000))
+	rtr := newRootRouter(evtpub.NewMockEventPublisher(), clock)
+
+	req, err := http.NewRequest("GET", "/time", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := httptest.NewRecorder()
+	rtr.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected code %d, got %d", http.StatusOK, resp.Code)
+	}
+	var st serverTime
+	if err := json.Unmarshal(resp.Body.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.ServerTimeMs != 1700000000000 || !st.ServerTime.Equal(clock.Now()) {
+		t.Errorf("expected the clock's time, got %d (%s)", st.ServerTimeMs,
+			st.ServerTime)
+	}
+}
+
 // test fwdToChallengeRouter
 func TestGetChallengesMapFromRoot(t *testing.T) {
 	rtr := NewRootRouter(evtpub.NewMockEventPublisher())
@@ -159,6 +183,45 @@ func TestFlowFromChallengeToPlay(t *testing.T) {
 	}
 }
 
+func TestConditionalMovesThroughRoot(t *testing.T) {
+	rtr := NewRootRouter(evtpub.NewMockEventPublisher())
+	gameURL, err := rtr.gameRtr.addGame(
+		func() {}, game.Config{DaysPerMove: 3}, fakePlayers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	gamePath := gameURL.String()
+	gameID := strings.Split(gamePath, "/")[2]
+	gm := rtr.gameRtr.games[gameID].gm
+	white, black := gm.GetWhiteCookie(), gm.GetBlackCookie()
+	for _, m := range []struct {
+		move   game.Move
+		cookie *http.Cookie
+	}{
+		{game.Move{X: 0, Y: 0, D: game.DirDown}, white},
+		{game.Move{X: 6, Y: 0, D: game.DirDown}, black},
+	} {
+		if err := gm.TryMove(m.move, m.cookie); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Black is waiting on white, so black gets to set conditional moves.
+	tree := `[{"if": {"x": 1, "y": 0, "d": "RIGHT"},
+	           "reply": {"x": 5, "y": 0, "d": "LEFT"}}]`
+	for _, method := range []string{"PUT", "DELETE"} {
+		req, _ := http.NewRequest(
+			method, gamePath+"/conditional-moves", strings.NewReader(tree))
+		req.AddCookie(black)
+		resp := httptest.NewRecorder()
+		rtr.ServeHTTP(resp, req)
+		if resp.Code != http.StatusOK {
+			t.Errorf("%s: expected status %d, got %d: %s", method,
+				http.StatusOK, resp.Code, resp.Body.String())
+		}
+	}
+}
+
 func TestDeleteOldChallengesAndDeleteChallengeCbRace(t *testing.T) {
 	rtr := NewRootRouter(evtpub.NewMockEventPublisher())
   path := addAcceptedGame(rtr, t)
@@ -1,12 +1,37 @@
 "use strict";
 
 class BoardDisplay {
+  boardInner_;
   marbleLayer_;
   inputLayer_;
-
-  constructor(marbleLayer, inputLayer) {
+  size_;
+  isHex_;
+  // Whether moves picked on the board are premoves, to be played once it's
+  // our turn.
+  premove_;
+  // Round trip time of our last move, in ms.
+  static lastRttMs = null;
+  // The ply and position hash of the latest state, sent along with moves so
+  // that the server can refuse stale ones.
+  static position = null;
+
+  constructor(boardInner, marbleLayer, inputLayer) {
+    this.boardInner_ = boardInner;
     this.marbleLayer_ = marbleLayer;
     this.inputLayer_ = inputLayer;
+    this.size_ = null;
+    this.isHex_ = false;
+    this.premove_ = false;
+  }
+
+  setVariant(variant) {
+    const isHex = (variant == "HEX");
+    if (isHex == this.isHex_) {
+      return;
+    }
+    this.isHex_ = isHex;
+    this.size_ = null; // force the background to be redrawn
+    this.boardInner_.classList.toggle('board-hex', isHex);
   }
 
   clearMarbles() {
@@ -22,11 +47,74 @@ class BoardDisplay {
     }
   }
 
-  update(board, validMoves, isYourTurn) {
-    const moves = BoardDisplay.createMoveMap(isYourTurn ? validMoves : []);
+  update(board, validMoves, isYourTurn, premoveColor) {
+    this.resize(board);
+    this.premove_ = !isYourTurn && premoveColor != null;
+    const moves = BoardDisplay.createMoveMap(
+        isYourTurn ? validMoves :
+            this.premove_ ? this.premoveCandidates(board, premoveColor) : [],
+        board.length);
     this.renderNoSelection(board, moves);
   }
 
+  // We can't tell what will be legal once it's our turn, so every step of
+  // every one of our marbles is offered and the server sorts them out.
+  premoveCandidates(board, color) {
+    const marble = color.charAt(0);
+    const directions = this.isHex_ ?
+        ["UP_LEFT", "UP_RIGHT", "LEFT", "RIGHT", "DOWN_LEFT", "DOWN_RIGHT"] :
+        ["UP", "DOWN", "LEFT", "RIGHT"];
+    const candidates = [];
+    for (let y = 0; y < board.length; y++) {
+      for (let x = 0; x < board[y].length; x++) {
+        if (board[y][x] != marble) {
+          continue;
+        }
+        for (const d of directions) {
+          const dxdy = BoardDisplay.directionStrToDxDy(d);
+          const nx = x + dxdy.x;
+          const ny = y + dxdy.y;
+          if (ny >= 0 && ny < board.length && nx >= 0 &&
+              nx < board[ny].length && board[ny][nx] != "#") {
+            candidates.push({ x: x, y: y, d: d, marblesMoved: 1 });
+          }
+        }
+      }
+    }
+    return candidates;
+  }
+
+  // The board background (grid lines & holes) depends only on the board size
+  // and shape, so it is only redrawn when those change. Void spaces ("#") are
+  // never part of the board, so the first board seen is good enough.
+  resize(board) {
+    const size = board.length;
+    if (size == this.size_) {
+      return;
+    }
+    this.size_ = size;
+    this.boardInner_.style.setProperty('--board-size', size);
+
+    for (const [id, cls, count] of [
+        ['board-lines-vertical', 'board--bg-line-vertical', size],
+        ['board-lines-horizontal', 'board--bg-line-horizontal', size],
+        ['board-holes', 'board--bg-dot', size * size]]) {
+      const layer = document.getElementById(id);
+      while (layer.lastChild) {
+        layer.removeChild(layer.lastChild);
+      }
+      for (let i = 0; i < count; i++) {
+        const el = document.createElement('div');
+        el.classList.add(cls);
+        if (count == size * size &&
+            board[Math.floor(i / size)][i % size] == "#") {
+          el.classList.add('board--bg-dot-void');
+        }
+        layer.appendChild(el);
+      }
+    }
+  }
+
   static createWhiteMarble() {
     const marble = document.createElement("div");
     marble.classList.add('marble-base');
@@ -48,6 +136,20 @@ class BoardDisplay {
     return marble;
   }
 
+  static createYellowMarble() {
+    const marble = document.createElement("div");
+    marble.classList.add('marble-base');
+    marble.classList.add('marble-yellow');
+    return marble;
+  }
+
+  static createGreenMarble() {
+    const marble = document.createElement("div");
+    marble.classList.add('marble-base');
+    marble.classList.add('marble-green');
+    return marble;
+  }
+
   static createNullMarble() {
     const marble = document.createElement("div");
     marble.classList.add('marble-base');
@@ -65,6 +167,18 @@ class BoardDisplay {
         return BoardDisplay.createWhiteMarble();
       case "B":
         return BoardDisplay.createBlackMarble();
+      case "Y":
+        return BoardDisplay.createYellowMarble();
+      case "G":
+        return BoardDisplay.createGreenMarble();
+      case "?":
+        const hidden = BoardDisplay.createNullMarble();
+        hidden.classList.add('marble-hidden');
+        return hidden;
+      case "#":
+        const marble = BoardDisplay.createNullMarble();
+        marble.classList.add('marble-void');
+        return marble;
       default:
         return null;
     }
@@ -76,11 +190,11 @@ class BoardDisplay {
     return el;
   }
 
-  static createMoveMap(arr) {
+  static createMoveMap(arr, size) {
     const movesMap = [];
-    for (let y = 0; y < 7; y++) {
+    for (let y = 0; y < size; y++) {
       movesMap.push([]);
-      for (let x = 0; x < 7; x++) {
+      for (let x = 0; x < size; x++) {
         movesMap[y].push([]);
       }
     }
@@ -93,12 +207,12 @@ class BoardDisplay {
     return movesMap;
   }
 
-  static postMove(move) {
+  static postPremove(move) {
     const urlParts = window.location.href.split("/");
     const gameID = urlParts[urlParts.length-1];
 
-    console.log("sending move: ", move);
-    fetch('/api/games/' + gameID + '/move',
+    console.log("sending premove: ", move);
+    fetch('/api/games/' + gameID + '/premove',
           { method: 'POST', body: JSON.stringify(move) })
         .then(response => {
           if (!response.ok) {
@@ -106,9 +220,48 @@ class BoardDisplay {
               console.log(`${response.status} ${txt}`);
             });
           }
+          document.dispatchEvent(new Event('premoves-changed'));
         });
   }
 
+  static postMove(move) {
+    const urlParts = window.location.href.split("/");
+    const gameID = urlParts[urlParts.length-1];
+
+    // The server refunds some of the time our moves spend in transit, going
+    // by the round trip time of our last move.
+    if (BoardDisplay.lastRttMs != null) {
+      move.lagNs = Math.round(BoardDisplay.lastRttMs * 1e6);
+    }
+    if (BoardDisplay.position != null) {
+      move.expectedPly = BoardDisplay.position.ply;
+      move.expectedPositionHash = BoardDisplay.position.hash;
+    }
+    console.log("sending move: ", move);
+    // Retries carry the same key, so the server won't play the move twice.
+    const key = Date.now() + "-" + Math.random().toString(36).slice(2);
+    const send = (retries) => {
+      const sent = performance.now();
+      fetch('/api/games/' + gameID + '/move',
+            { method: 'POST', body: JSON.stringify(move),
+              headers: { 'Idempotency-Key': key } })
+          .then(response => {
+            BoardDisplay.lastRttMs = performance.now() - sent;
+            if (!response.ok) {
+              response.text().then(txt => {
+                console.log(`${response.status} ${txt}`);
+              });
+            }
+          }, error => {
+            console.log(error);
+            if (retries > 0) {
+              send(retries - 1);
+            }
+          });
+    };
+    send(2);
+  }
+
   static directionStrToDxDy(s) {
     switch (s) {
       case "UP":
@@ -119,6 +272,15 @@ class BoardDisplay {
         return { x: -1, y: 0 };
       case "RIGHT":
         return { x: 1, y: 0 };
+      // Hex boards use axial coordinates.
+      case "UP_LEFT":
+        return { x: 0, y: -1 };
+      case "UP_RIGHT":
+        return { x: 1, y: -1 };
+      case "DOWN_LEFT":
+        return { x: -1, y: 1 };
+      case "DOWN_RIGHT":
+        return { x: 0, y: 1 };
       default:
         throw new Error("Invalid direction " + s + "!");
     }
@@ -153,7 +315,7 @@ class BoardDisplay {
     let tmp = ' ';
     let x = selection.x;
     let y = selection.y;
-    for (; y < 7 && y >= 0 && x < 7 && y >= 0;) {
+    for (; y < board.length && y >= 0 && x < board[y].length && x >= 0;) {
       [ board[y][x], tmp ] = [ tmp, board[y][x] ];
       moved[y][x] = true;
       if (marblesMoved == move.marblesMoved) {
@@ -241,13 +403,19 @@ class BoardDisplay {
       for (let diff = 0; diff < move.marblesMoved; diff++) {
         x += d.x;
         y += d.y;
-        if (x < 0 || x >= 7 || y < 0 || y >= 7) {
+        if (y < 0 || y >= board.length || x < 0 || x >= board[y].length) {
           break;
         }
         isPreviewListener[y][x] = true;
         inputs[y][x].classList.add('input-selectable');
         inputs[y][x].addEventListener('click', (e) => {
-          BoardDisplay.postMove({ X: selection.x, Y: selection.y, D: move.d });
+          const picked = { X: selection.x, Y: selection.y, D: move.d };
+          if (this_.premove_) {
+            BoardDisplay.postPremove(picked);
+            this_.renderNoSelection(board, validMoves);
+          } else {
+            BoardDisplay.postMove(picked);
+          }
         });
         inputs[y][x].addEventListener('mouseover', (e) => {
           this_.renderMarblesWithPreview(board, selection, move);
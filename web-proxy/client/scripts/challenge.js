@@ -14,7 +14,7 @@ function getChallenge() {
         if (challenge == null) {
           return;
         }
-        let createdByMe = (getMyID() == challenge.creatorID);
+        let createdByMe = challenge.playerIDs.includes(getMyID());
         document.getElementById("not-found").hidden = true;
         document.getElementById("found").hidden = createdByMe;
         document.getElementById("waiting").hidden = !createdByMe;
@@ -30,10 +30,8 @@ function updateChallengeDisplay(challenge, timeControlSpans) {
     while (span.lastChild) {
       span.removeChild(span.lastChild);
     }
-    let timeNs = challenge.config.timeControlNs;
-
-    console.log(timeNs);
-    let timeTxt = document.createTextNode(ChallengeBrowser.formatNs(timeNs));
+    let timeTxt = document.createTextNode(
+        ChallengeBrowser.formatConfig(challenge.config));
     span.appendChild(timeTxt);
   }
 }
@@ -51,6 +49,10 @@ document.getElementById('accept-button').addEventListener("click", e => {
 const eventSource =
     new EventSource(getAPIBase() + "/event-source", { withCredentials: true, });
 
+eventSource.addEventListener("challenge-updated", function(e) {
+  getChallenge();
+});
+
 eventSource.addEventListener("game-created", function(e) {
   window.location.href = e.data;
 });
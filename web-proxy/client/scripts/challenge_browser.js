@@ -45,8 +45,8 @@ class ChallengeBrowser {
     tr.appendChild(player);
 
     const td = document.createElement("td");
-    const ns = challenge.config.timeControlNs;
-    const timeTxt = document.createTextNode(ChallengeBrowser.formatNs(ns));
+    const timeTxt = document.createTextNode(
+        ChallengeBrowser.formatConfig(challenge.config));
     tr.appendChild(td);
     td.appendChild(timeTxt);
 
@@ -62,6 +62,13 @@ class ChallengeBrowser {
     return tr;
   }
 
+  static formatConfig(config) {
+    if (config.daysPerMove > 0) {
+      return config.daysPerMove + "d / move";
+    }
+    return ChallengeBrowser.formatNs(config.timeControlNs);
+  }
+
   static formatNs(ns) {
     const nsPerSec = 1e9;
     const nsPerMin = 6e10;
@@ -22,12 +22,12 @@ class ClockDisplay {
     }
   }
 
-  update(timeNs, timeControl, deadline) {
+  update(timeNs, timeControl, deadline, maxNs=Infinity) {
     this.reset()
     if (deadline != null) {
       this.interval_ = setInterval(
           ClockDisplay.tickClock, 10, this.clock_, Date.parse(deadline),
-          timeControl);
+          timeControl, maxNs);
     } else {
       ClockDisplay.writeClock(this.clock_, timeNs, timeControl);
     }
@@ -44,9 +44,9 @@ class ClockDisplay {
     }
   }
 
-  static tickClock(clock, deadline, timeControl) {
+  static tickClock(clock, deadline, timeControl, maxNs) {
     const durationMs = new Date(deadline - new Date());
-    const durationNs = durationMs * 1e6;
+    const durationNs = Math.min(durationMs * 1e6, maxNs);
     ClockDisplay.writeClock(
         clock, Math.max(durationNs, 0), timeControl, true);
   }
@@ -55,6 +55,14 @@ class ClockDisplay {
     const nsPerSec = 1e9;
     const nsPerMin = 6e10;
     const nsPerHour = 36e11;
+    const nsPerDay = 864e11;
+
+    // Correspondence clocks
+    if (ns >= nsPerDay) {
+      const days = Math.floor(ns / nsPerDay);
+      const hours = Math.floor((ns - days * nsPerDay) / nsPerHour);
+      return days + "d " + hours + "h";
+    }
 
     const hours = Math.floor(ns / nsPerHour);
     const minutes = Math.floor((ns - hours * nsPerHour) / nsPerMin);
@@ -1,7 +1,8 @@
 "use strict";
 
 const boardDisplay =
-    new BoardDisplay(document.getElementById("board-marble-layer"),
+    new BoardDisplay(document.getElementById("board-inner"),
+                     document.getElementById("board-marble-layer"),
                      document.getElementById("board-input-layer"));
 const statusDisplay = new StatusDisplay(document.getElementById("status"));
 const topPlayer = {
@@ -32,6 +33,8 @@ const historyManager =
     new HistoryManager(boardDisplay, document.getElementById('move-history'),
                        document.getElementById('move-history-wrapper'));
 
+syncServerClock();
+
 function getStateAndUpdate() {
   fetch(getAPIBase() + '/state')
       .then(response => {
@@ -44,6 +47,54 @@ function getStateAndUpdate() {
       });
 }
 
+function describeClock(config) {
+  if (config.daysPerMove > 0) {
+    return config.daysPerMove + " days per move" +
+        (config.vacationDays > 0 ?
+            ", " + config.vacationDays + " vacation days" : "");
+  }
+  let base = config.timeControlNs / 6e10 + " min";
+  const period = config.periodTimeNs / 1e9 + "s";
+  if (config.overtime == "BYO_YOMI") {
+    base += ", byo-yomi " + config.periods + " x " + period;
+  } else if (config.overtime == "CANADIAN") {
+    base += ", Canadian " + config.periodMoves + " moves in " + period;
+  }
+  if (config.clock == "SUDDEN_DEATH") {
+    return base;
+  }
+  const increment = config.incrementNs / 1e9 + "s";
+  switch (config.clock) {
+    case "FISCHER":
+      return base + " + " + increment;
+    case "BRONSTEIN":
+      return base + ", " + increment + " Bronstein delay";
+    case "SIMPLE_DELAY":
+      return base + ", " + increment + " delay";
+  }
+  return base;
+}
+
+function describeHandicap(handicap) {
+  if (handicap == null) {
+    return "";
+  }
+  const parts = [];
+  if (handicap.scoreBonus > 0) {
+    parts.push("+" + handicap.scoreBonus + " reds");
+  }
+  if (handicap.removedMarbles > 0) {
+    parts.push(handicap.removedMarbles + " opposing marbles removed");
+  }
+  if (handicap.extraTimeNs > 0) {
+    parts.push("+" + handicap.extraTimeNs / 6e10 + " min");
+  }
+  if (handicap.firstMove) {
+    parts.push("moves first");
+  }
+  return ", handicap for " + handicap.receiver + ": " + parts.join(", ");
+}
+
 function update(state) {
   if (state == null) {
     return
@@ -54,18 +105,282 @@ function update(state) {
   document.getElementById("game-dash").hidden = false;
 
   const gameOngoing = state.status == 'ONGOING';
+  const gamePaused = state.status == 'PAUSED';
+
+  document.getElementById("resign-button").hidden = !gameOngoing && !gamePaused;
+  document.getElementById("rematch-button").hidden =
+      gameOngoing || gamePaused || (state.match != null && state.match.over);
+  document.getElementById("pause-button").hidden =
+      !gameOngoing || getMyID() == null;
+  document.getElementById("resume-button").hidden =
+      !gamePaused || getMyID() == null;
+
+  // Draws can be offered by either team, and answered by the other one.
+  const me = state.idToPlayer[getMyID()];
+  const drawOffer = state.drawOffer;
+  const drawOfferer =
+      drawOffer.offeredBy ? state.colorToPlayer[drawOffer.offeredBy] : null;
+  const offerPending = gameOngoing && me != null && drawOfferer != null;
+  const theirOffer = offerPending && drawOfferer.team != me.team;
+  document.getElementById("draw-offer-button").hidden =
+      !gameOngoing || me == null || offerPending ||
+      drawOffer.offersLeft[me.team] <= 0;
+  if (me != null) {
+    document.getElementById("draw-offers-left").textContent =
+        drawOffer.offersLeft[me.team];
+  }
+  document.getElementById("draw-accept-button").hidden = !theirOffer;
+  document.getElementById("draw-decline-button").hidden = !theirOffer;
+  const noCaptureLimit = state.config.noCaptureDrawMoves;
+  document.getElementById("draw-claim-button").hidden =
+      !gameOngoing || me == null || !state.config.noCaptureDrawClaim ||
+      state.movesSinceCapture < noCaptureLimit;
+
+  // Likewise takebacks, if the game allows them.
+  const requester = state.takebackRequestedBy ?
+      state.colorToPlayer[state.takebackRequestedBy] : null;
+  const theirRequest =
+      gameOngoing && me != null && requester != null &&
+      requester.team != me.team;
+  document.getElementById("takeback-request-button").hidden =
+      !gameOngoing || me == null || !state.config.allowTakebacks ||
+      requester != null;
+  document.getElementById("takeback-accept-button").hidden = !theirRequest;
+  document.getElementById("takeback-decline-button").hidden = !theirRequest;
 
-  document.getElementById("resign-button").hidden = !gameOngoing;
-  document.getElementById("rematch-button").hidden = gameOngoing;
+  // Rematch proposals; the rematch button accepts the open one.
+  const rematch = state.rematch;
+  const canRematch = !gameOngoing && !gamePaused && me != null &&
+      !(state.match != null && state.match.over);
+  document.getElementById("rematch-keep-colors-button").hidden =
+      !canRematch || state.match != null;
+  document.getElementById("rematch-decline-button").hidden =
+      !canRematch || rematch == null || !rematch.proposedBy ||
+      rematch.proposedBy == me.color;
+  const rematchProposal = document.getElementById("rematch-proposal");
+  rematchProposal.hidden = rematch == null;
+  rematchProposal.textContent = describeRematch(rematch);
 
   const lastSnapshot = state.history[state.history.length-1];
-  statusDisplay.update(state.status);
+  statusDisplay.update(describeStatus(state));
+  boardDisplay.setVariant(state.config.variant);
+  document.getElementById("variant").textContent =
+      state.config.variant +
+      (state.config.seed ? " (seed " + state.config.seed + ")" : "") +
+      describeHandicap(state.config.handicap) + ", " +
+      describeClock(state.config) +
+      (noCaptureLimit > 0 ?
+          ", " + state.movesSinceCapture + "/" + noCaptureLimit +
+              " moves without a capture" : "");
+  // The server's deadlines are in terms of its clock, which ours may be off
+  // from.
+  for (const player of [...Object.values(state.idToPlayer),
+                        ...Object.values(state.colorToPlayer)]) {
+    if (player.deadline != null) {
+      player.deadline = localDeadline(state.serverTime, player.remainingMs);
+    }
+  }
+  if (state.firstMoveRemainingMs != null) {
+    state.firstMoveDeadline =
+        localDeadline(state.serverTime, state.firstMoveRemainingMs);
+  }
   playerDisplayManager.update(
       state.idToPlayer, state.colorToPlayer,
       state.status == "ONGOING" ? lastSnapshot.whoseTurn : null,
       state.timeControl, state.firstMoveDeadline, state.status);
 
-  historyManager.update(state.history, state.validMoves, state.idToPlayer);
+  BoardDisplay.position = { ply: state.ply, hash: state.positionHash };
+  historyManager.update(
+      state.history, state.validMoves, state.idToPlayer, gameOngoing);
+  if (me != null && (gameOngoing || !premovesText.hidden)) {
+    refreshPremoves();
+  }
+  const conditional = me != null && gameOngoing && state.config.daysPerMove > 0;
+  document.getElementById("conditional-moves").hidden = !conditional;
+  if (conditional) {
+    refreshConditionalMoves();
+  }
+
+  document.getElementById("match-info").hidden = state.match == null;
+  if (state.match != null) {
+    document.getElementById("match").textContent = describeMatch(state.match);
+  }
+
+  // Games can be aborted until every player has made their first move.
+  const plies = state.history.length - 1;
+  const numPlayers = Object.keys(state.colorToPlayer).length;
+  document.getElementById("abort-button").hidden =
+      !gameOngoing || getMyID() == null || plies >= numPlayers;
+
+  // Once every opponent has been gone for a while, the game can be claimed
+  // rather than waiting out their clock.
+  amPlayer = me != null;
+  const abandoned =
+      gameOngoing && me != null && state.config.daysPerMove == 0 &&
+      Object.values(state.colorToPlayer).every(
+          p => p.team == me.team || !p.connected);
+  document.getElementById("claim-win-button").hidden =
+      !abandoned || plies < numPlayers;
+  document.getElementById("claim-draw-button").hidden =
+      !abandoned || plies < numPlayers;
+  document.getElementById("claim-abort-button").hidden =
+      !abandoned || plies >= maxAbandonedAbortMoves;
+}
+
+function describeMatch(match) {
+  const scores = Object.entries(match.scores)
+      .map(([id, points]) => id + " " + points)
+      .join(", ");
+  const played = match.results.filter(r => r.status != "ABORTED").length;
+  return (match.bestOf ? "best of " : "") + match.games + " games, " +
+      (match.over ? "finished" : "game " + (played + 1)) + ": " + scores;
+}
+
+// Only we get to see our premoves, so they're fetched rather than pushed.
+const premovesText = document.getElementById("premoves");
+function refreshPremoves() {
+  fetch(getAPIBase() + '/premove')
+      .then(response => response.ok ? response.json() : null)
+      .then(premoves => {
+        const describe = moves =>
+            moves.map(m => "(" + m.x + "," + m.y + ") " + m.d).join(", ");
+        const parts = [];
+        if (premoves != null && premoves.moves.length > 0) {
+          parts.push("Premoves: " + describe(premoves.moves));
+        }
+        if (premoves != null && premoves.discarded) {
+          parts.push("Discarded premoves " + describe(premoves.discarded) +
+              " (" + premoves.discardReason + ")");
+        }
+        premovesText.textContent = parts.join("; ");
+        premovesText.hidden = parts.length == 0;
+        document.getElementById("premove-clear-button").hidden =
+            premoves == null || premoves.moves.length == 0;
+      });
+}
+
+document.addEventListener('premoves-changed', refreshPremoves);
+
+document.getElementById('premove-clear-button').addEventListener(
+    'click', () => {
+  fetch(getAPIBase() + '/premove/clear', { method: 'POST', body: null })
+      .then(response => {
+        if (!response.ok) {
+          response.text().then(txt => {
+            console.log(`${response.status} ${txt}`);
+          });
+        }
+        refreshPremoves();
+      });
+});
+
+// Conditional moves are private too. Edits in progress are left alone.
+const conditionalInput = document.getElementById("conditional-moves-input");
+function refreshConditionalMoves() {
+  if (document.activeElement == conditionalInput) {
+    return;
+  }
+  fetch(getAPIBase() + '/conditional-moves')
+      .then(response => response.ok ? response.json() : null)
+      .then(moves => {
+        if (moves != null) {
+          conditionalInput.value =
+              moves.length > 0 ? JSON.stringify(moves, null, 1) : "";
+        }
+      });
+}
+
+for (const [id, method] of [['conditional-moves-save', 'PUT'],
+                            ['conditional-moves-delete', 'DELETE']]) {
+  document.getElementById(id + '-button').addEventListener('click', () => {
+    const body = method == 'PUT' ? (conditionalInput.value || "[]") : null;
+    fetch(getAPIBase() + '/conditional-moves',
+          { method: method, body: body })
+        .then(response => {
+          const error = document.getElementById("conditional-moves-error");
+          error.textContent = "";
+          if (!response.ok) {
+            response.text().then(txt => {
+              console.log(`${response.status} ${txt}`);
+              error.textContent = txt;
+            });
+          }
+          refreshConditionalMoves();
+        });
+  });
+}
+
+function describeRematch(rematch) {
+  if (rematch == null) {
+    return "";
+  }
+  if (!rematch.proposedBy) {
+    return rematch.declinedBy + " declined the rematch";
+  }
+  const offer = rematch.offer;
+  return rematch.proposedBy + " proposes a rematch" +
+      (offer.config ?
+          " (" + offer.config.variant + describeHandicap(offer.config.handicap) +
+              ", " + describeClock(offer.config) + ")" : "") +
+      (offer.keepColors ? " with the same colors" : "");
+}
+
+function describeStatus(state) {
+  const pause = state.pause;
+  if (state.status == 'PAUSED') {
+    return "PAUSED" +
+        (pause.resumeAt ?
+            " (resumes " + new Date(pause.resumeAt).toLocaleString() + ")" :
+            " (until " + new Date(pause.adjournDeadline).toLocaleString() +
+                " at the latest)") +
+        (pause.offeredBy ? ", " + pause.offeredBy + " offers to resume" : "");
+  }
+  if (state.status == 'ONGOING' && pause.offeredBy) {
+    return "ONGOING, " + pause.offeredBy + " offers to pause";
+  }
+  if (state.status == 'ONGOING' && state.drawOffer.offeredBy) {
+    return "ONGOING, " + state.drawOffer.offeredBy + " offers a draw";
+  }
+  if (state.status == 'ONGOING' && state.takebackRequestedBy) {
+    return "ONGOING, " + state.takebackRequestedBy + " asks for a takeback";
+  }
+  switch (state.drawReason) {
+    case "REPETITION":
+      return "DRAW (threefold repetition)";
+    case "AGREEMENT":
+      return "DRAW (by agreement)";
+    case "ADJUDICATED":
+      return "DRAW (adjourned for too long, scores level)";
+    case "NO_CAPTURE":
+      return "DRAW (" + state.config.noCaptureDrawMoves +
+          " moves without a capture)";
+    case "ABANDONED":
+      return "DRAW (claimed after the opponent left)";
+  }
+  switch (state.abortReason) {
+    case "NO_FIRST_MOVE":
+      return state.status + " (" + state.abortedBy + " didn't move)";
+    case "REQUESTED":
+      return state.status + " (by " + state.abortedBy + ")";
+    case "ADJOURNED":
+      return state.status + " (adjourned for too long)";
+    case "ABANDONED":
+      return state.status + " (by " + state.abortedBy +
+          " after the opponent left)";
+  }
+  const howWon = {
+    CAPTURES: "captures",
+    ENTRAPMENT: "entrapment",
+    RESIGNATION: "resignation",
+    TIMEOUT: "on time",
+    ADJUDICATION: "adjudicated",
+    ABANDONMENT: "opponent left",
+  }[state.termination];
+  if (howWon) {
+    return state.status + " (" + howWon + ", move " + state.terminationMove +
+        ")";
+  }
+  return state.status;
 }
 
 document.getElementById('resign-button').addEventListener('click', () => {
@@ -80,6 +395,85 @@ document.getElementById('resign-button').addEventListener('click', () => {
       });
 });
 
+document.getElementById('abort-button').addEventListener('click', () => {
+  fetch(getAPIBase() + '/abort',
+        { method: 'POST', body: null })
+      .then(response => {
+        if (!response.ok) {
+          response.text().then(txt => {
+            console.log(`${response.status} ${txt}`);
+          });
+        }
+      });
+});
+
+for (const [id, path] of [['draw-offer', '/draw-offer'],
+                          ['draw-accept', '/draw-offer/accept'],
+                          ['draw-decline', '/draw-offer/decline'],
+                          ['draw-claim', '/draw-claim'],
+                          ['takeback-request', '/takeback-request'],
+                          ['takeback-accept', '/takeback-request/accept'],
+                          ['takeback-decline', '/takeback-request/decline']]) {
+  document.getElementById(id + '-button').addEventListener('click', () => {
+    fetch(getAPIBase() + path,
+          { method: 'POST', body: null })
+        .then(response => {
+          if (!response.ok) {
+            response.text().then(txt => {
+              console.log(`${response.status} ${txt}`);
+            });
+          }
+        });
+  });
+}
+
+// Lets the server know we're still here; see the claim buttons.
+const maxAbandonedAbortMoves = 10;
+let amPlayer = false;
+setInterval(() => {
+  if (!amPlayer) {
+    return;
+  }
+  fetch(getAPIBase() + '/ping', { method: 'POST', body: null })
+      .then(response => {
+        if (!response.ok) {
+          response.text().then(txt => {
+            console.log(`${response.status} ${txt}`);
+          });
+        }
+      });
+}, 10000);
+
+for (const [id, claim] of [['claim-win', 'WIN'],
+                           ['claim-draw', 'DRAW'],
+                           ['claim-abort', 'ABORT']]) {
+  document.getElementById(id + '-button').addEventListener('click', () => {
+    fetch(getAPIBase() + '/abandonment-claim',
+          { method: 'POST', body: JSON.stringify({ claim: claim }) })
+        .then(response => {
+          if (!response.ok) {
+            response.text().then(txt => {
+              console.log(`${response.status} ${txt}`);
+            });
+          }
+        });
+  });
+}
+
+for (const action of ['pause', 'resume']) {
+  document.getElementById(action + '-button').addEventListener('click', () => {
+    fetch(getAPIBase() + '/' + action,
+          { method: 'POST', body: null })
+        .then(response => {
+          if (!response.ok) {
+            response.text().then(txt => {
+              console.log(`${response.status} ${txt}`);
+            });
+          }
+        });
+  });
+}
+
 document.getElementById('rematch-button').addEventListener('click', () => {
   fetch(getAPIBase() + '/rematch-offer',
         { method: 'POST', body: null })
@@ -92,6 +486,23 @@ document.getElementById('rematch-button').addEventListener('click', () => {
       });
 });
 
+for (const [id, path, body] of [
+         ['rematch-keep-colors', '/rematch-offer',
+          JSON.stringify({ keepColors: true })],
+         ['rematch-decline', '/rematch-offer/decline', null]]) {
+  document.getElementById(id + '-button').addEventListener('click', () => {
+    fetch(getAPIBase() + path,
+          { method: 'POST', body: body })
+        .then(response => {
+          if (!response.ok) {
+            response.text().then(txt => {
+              console.log(`${response.status} ${txt}`);
+            });
+          }
+        });
+  });
+}
+
 document.getElementById('move-history-prev').addEventListener('click', () => {
   historyManager.prev();
 });
@@ -108,16 +519,28 @@ document.getElementById('move-history-last').addEventListener('click', () => {
   historyManager.last();
 });
 
-// Listen for updates.
-const eventSource =
-    new EventSource(getAPIBase() + "/event-source", { withCredentials: true, });
+// Listen for updates. Players get their own view of the game pushed to their
+// own channel; everyone else listens in on the game's.
+function listenForUpdates(channelPath) {
+  const eventSource = new EventSource(
+      getAPIBase() + channelPath + "/event-source", { withCredentials: true, });
 
-eventSource.addEventListener('state-push', function(e) {
-  update(JSON.parse(e.data));
-});
+  eventSource.addEventListener('state-push', function(e) {
+    update(JSON.parse(e.data));
+  });
 
-eventSource.onerror = function(e) {
-  console.log(e);
-};
+  eventSource.onerror = function(e) {
+    console.log(e);
+  };
+
+  getStateAndUpdate();
+}
 
-getStateAndUpdate();
+fetch(getAPIBase() + '/event-channel')
+    .then(response => response.ok ? response.json() : null)
+    .then(channel => {
+      listenForUpdates(channel == null ? "" : "/" + channel.path);
+    })
+    .catch(() => {
+      listenForUpdates("");
+    });
@@ -10,6 +10,8 @@ class HistoryManager {
   validMoves_; // (for current snapshot)
   myID_;
   isYourTurn_;
+  // Our color while the game is ongoing, for picking premoves.
+  premoveColor_;
 
   constructor(boardDisplay, moveOl, scrollWrapper) {
     this.boardDisplay_ = boardDisplay;
@@ -22,6 +24,7 @@ class HistoryManager {
 
     this.myID_ = getMyID();
     this.isYourTurn_ = false;
+    this.premoveColor_ = null;
   }
 
   clearMoveOl() {
@@ -30,7 +33,7 @@ class HistoryManager {
     }
   }
 
-  update(history, validMoves, idToPlayer) {
+  update(history, validMoves, idToPlayer, ongoing) {
     this.history_ = history;
     this.validMoves_ = validMoves;
 
@@ -40,6 +43,8 @@ class HistoryManager {
         (idToPlayer[this.myID_] != null) &&
         (idToPlayer[this.myID_].color ==
              this.history_[this.history_.length-1].whoseTurn));
+    this.premoveColor_ = (ongoing && idToPlayer[this.myID_] != null) ?
+        idToPlayer[this.myID_].color : null;
 
     this.last();
   }
@@ -54,11 +59,15 @@ class HistoryManager {
       const li = document.createElement("li");
       const a = document.createElement('a');
 
-      if (move == null) {
+      if (i == 0) {
         a.appendChild(document.createTextNode('start'));
+      } else if (move == null) {
+        // Hidden from us (the other side's move in a DARK game).
+        a.appendChild(document.createTextNode('?'));
       } else {
+        const size = history[i].board.length;
         a.appendChild(document.createTextNode(
-            "" + "ABCDEFG"[move.x] + (7 - move.y) + " " + move.d));
+            "" + "ABCDEFGHIJK"[move.x] + (size - move.y) + " " + move.d));
       }
 
       if (i == this.currentSnapshotIdx_) {
@@ -101,12 +110,13 @@ class HistoryManager {
   }
 
   render() {
-    const canMove = this.isYourTurn_ &&
-                    (this.currentSnapshotIdx_ == this.history_.length-1);
+    const isLatest = this.currentSnapshotIdx_ == this.history_.length-1;
+    const canMove = this.isYourTurn_ && isLatest;
 
     console.log(this.history_[this.currentSnapshotIdx_].board);
     this.boardDisplay_.update(this.history_[this.currentSnapshotIdx_].board,
-                              this.validMoves_, canMove);
+                              this.validMoves_, canMove,
+                              isLatest ? this.premoveColor_ : null);
 
     this.updateMoveOl(this.history_, this.currentSnapshotIdx_);
   }
@@ -25,8 +25,57 @@ const createRoomForm = document.getElementById("new-challenge-form");
 createRoomForm.addEventListener("submit", e => {
   e.preventDefault();
   let formRaw = Object.fromEntries(new FormData(createRoomForm));
+  // Either a TFEN string or a JSON position object. The board size comes from
+  // the position.
+  let startPosition = formRaw.startPosition.trim();
+  if (startPosition.startsWith("{")) {
+    startPosition = JSON.parse(startPosition);
+  }
+  // Players without one get the server's default.
+  let firstMoveTimeouts = {};
+  if (formRaw.whiteFirstMoveSec) {
+    firstMoveTimeouts.WHITE = formRaw.whiteFirstMoveSec * 1e9;
+  }
+  if (formRaw.blackFirstMoveSec) {
+    firstMoveTimeouts.BLACK = formRaw.blackFirstMoveSec * 1e9;
+  }
+  // Correspondence games have no time control of their own.
+  const daysPerMove = parseInt(formRaw.daysPerMove);
   let data = JSON.stringify({
-    timeControlNs: formRaw.initialTimeMin * 6e10
+    timeControlNs: daysPerMove > 0 ? 0 : formRaw.initialTimeMin * 6e10,
+    daysPerMove: daysPerMove,
+    vacationDays: daysPerMove > 0 ? parseInt(formRaw.vacationDays) : 0,
+    clock: formRaw.clock,
+    incrementNs:
+        formRaw.clock == "SUDDEN_DEATH" ? 0 : formRaw.incrementSec * 1e9,
+    overtime: formRaw.overtime,
+    periods: formRaw.overtime == "BYO_YOMI" ? parseInt(formRaw.periods) : 0,
+    periodMoves:
+        formRaw.overtime == "CANADIAN" ? parseInt(formRaw.periodMoves) : 0,
+    periodTimeNs: formRaw.overtime == "NONE" ? 0 : formRaw.periodSec * 1e9,
+    boardSize: startPosition ? 0 : parseInt(formRaw.boardSize),
+    variant: formRaw.variant,
+    seed: formRaw.seed ? parseInt(formRaw.seed) : 0,
+    startPosition: startPosition ? startPosition : undefined,
+    firstMoveTimeoutsNs: firstMoveTimeouts,
+    maxAdjournmentNs: formRaw.maxAdjournmentHours ?
+        formRaw.maxAdjournmentHours * 36e11 : 0,
+    match: !formRaw.matchGames ? undefined : {
+      games: parseInt(formRaw.matchGames),
+      bestOf: formRaw.matchBestOf == "on",
+    },
+    allowTakebacks: formRaw.allowTakebacks == "on",
+    noCaptureDrawMoves: formRaw.noCaptureDrawMoves ?
+        parseInt(formRaw.noCaptureDrawMoves) : 0,
+    noCaptureDrawClaim: formRaw.noCaptureDrawMoves ?
+        formRaw.noCaptureDrawClaim == "on" : false,
+    handicap: !formRaw.handicapReceiver ? undefined : {
+      receiver: formRaw.handicapReceiver,
+      scoreBonus: parseInt(formRaw.handicapScore),
+      removedMarbles: parseInt(formRaw.handicapRemoved),
+      extraTimeNs: formRaw.handicapTimeMin * 6e10,
+      firstMove: formRaw.handicapFirstMove == "on",
+    },
   });
   fetch('/api/challenges', { method: 'POST', body: data, redirect: 'follow' })
       .then(response => {
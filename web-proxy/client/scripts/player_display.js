@@ -31,9 +31,25 @@ class PlayerDisplay {
     if (isTheirFirstMove) {
       info.deadline = firstMoveDeadline;
     }
-    this.clock_display_.update(info.timeNs, timeControl, info.deadline);
+    // With a simple delay the deadline is further away than the time the
+    // player has left, since the clock doesn't run during the delay.
+    this.clock_display_.update(
+        info.timeNs, timeControl, info.deadline,
+        isTheirFirstMove ? Infinity : info.timeNs);
     this.score_.appendChild(document.createTextNode(info.score));
-    this.color_.appendChild(document.createTextNode(info.color));
+    let color = info.color;
+    if (info.inOvertime && info.periodsLeft > 0) {
+      color += " (overtime, " + info.periodsLeft + " periods left)";
+    } else if (info.inOvertime && info.movesLeft > 0) {
+      color += " (overtime, " + info.movesLeft + " moves to go)";
+    }
+    if (info.vacationDays > 0) {
+      color += " (" + info.vacationDays + " vacation days)";
+    }
+    if (!info.connected) {
+      color += " (disconnected)";
+    }
+    this.color_.appendChild(document.createTextNode(color));
     this.active_.hidden = !isTheirTurn;
     if (this.you_ != null) {
       this.you_.hidden = !isMe;
@@ -43,8 +43,9 @@ class PlayerDisplayManager {
           rematchCounterVal++;
         }
       }
-      this.resignButton_.hidden = status != 'ONGOING';
-      this.rematchButton_.hidden = status == 'ONGOING';
+      const over = status != 'ONGOING' && status != 'PAUSED';
+      this.resignButton_.hidden = over;
+      this.rematchButton_.hidden = !over;
       while (this.rematchCounter_.lastChild) {
         this.rematchCounter_.removeChild(this.rematchCounter_.lastChild);
       }
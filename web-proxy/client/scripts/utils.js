@@ -14,3 +14,37 @@ function getMyID() {
   let nameval = cookies[0].split("=");
   return nameval[0].trim()
 }
+
+// How far ahead of the server's clock ours is, in ms.
+let serverClockOffsetMs = 0;
+
+// Estimates serverClockOffsetMs NTP style. The sample with the shortest round
+// trip is kept, since it's the one least thrown off by network jitter.
+function syncServerClock(samples=5) {
+  let bestRttMs = Infinity;
+  const sample = left => {
+    if (left == 0) {
+      return;
+    }
+    const sent = Date.now();
+    fetch('/api/time', { cache: 'no-store' })
+        .then(response => response.json())
+        .then(t => {
+          const received = Date.now();
+          if (received - sent < bestRttMs) {
+            bestRttMs = received - sent;
+            serverClockOffsetMs = (sent + received) / 2 - t.serverTimeMs;
+          }
+          sample(left - 1);
+        })
+        .catch(err => console.log(err));
+  };
+  sample(samples);
+}
+
+// Turns the time left on a clock as of serverTime into a deadline on our own
+// clock, as an ISO string.
+function localDeadline(serverTime, remainingMs) {
+  return new Date(Date.parse(serverTime) + remainingMs + serverClockOffsetMs)
+      .toISOString();
+}